@@ -1,16 +1,53 @@
 package main
 
 import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	_ "github.com/anrid/codecoach/docs" // docs is generated by Swag CLI, must be imported.
 	"github.com/anrid/codecoach/internal/config"
+	acc_c "github.com/anrid/codecoach/internal/controller/account"
+	apikey_c "github.com/anrid/codecoach/internal/controller/apikey"
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	grading_c "github.com/anrid/codecoach/internal/controller/grading"
+	identity_c "github.com/anrid/codecoach/internal/controller/identity"
+	job_c "github.com/anrid/codecoach/internal/controller/job"
 	oauth_c "github.com/anrid/codecoach/internal/controller/oauth"
+	scorecard_c "github.com/anrid/codecoach/internal/controller/scorecard"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
 	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
 	"github.com/anrid/codecoach/internal/pg"
 	account_d "github.com/anrid/codecoach/internal/pg/dao/account"
+	apikey_d "github.com/anrid/codecoach/internal/pg/dao/apikey"
+	audit_d "github.com/anrid/codecoach/internal/pg/dao/audit"
+	challenge_d "github.com/anrid/codecoach/internal/pg/dao/challenge"
+	fingerprint_d "github.com/anrid/codecoach/internal/pg/dao/fingerprint"
+	job_d "github.com/anrid/codecoach/internal/pg/dao/job"
+	passwordreset_d "github.com/anrid/codecoach/internal/pg/dao/passwordreset"
+	scorecard_d "github.com/anrid/codecoach/internal/pg/dao/scorecard"
+	session_d "github.com/anrid/codecoach/internal/pg/dao/session"
+	task_d "github.com/anrid/codecoach/internal/pg/dao/task"
 	user_d "github.com/anrid/codecoach/internal/pg/dao/user"
+	"github.com/anrid/codecoach/internal/pkg/github"
 	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/anrid/codecoach/internal/pkg/mailer"
+	"github.com/anrid/codecoach/internal/pkg/sandbox"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	acc_uc "github.com/anrid/codecoach/internal/usecase/account"
+	apikey_uc "github.com/anrid/codecoach/internal/usecase/apikey"
+	challenge_uc "github.com/anrid/codecoach/internal/usecase/challenge"
 	github_oauth "github.com/anrid/codecoach/internal/usecase/github"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	scorecard_uc "github.com/anrid/codecoach/internal/usecase/scorecard"
+	task_uc "github.com/anrid/codecoach/internal/usecase/task"
 	user_uc "github.com/anrid/codecoach/internal/usecase/user"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/anrid/codecoach/internal/worker"
 	"github.com/spf13/pflag"
 	echoSwagger "github.com/swaggo/echo-swagger"
 	"go.uber.org/zap"
@@ -62,25 +99,116 @@ func main() {
 	// Setup DAOs.
 	accountDAO := account_d.New(db)
 	userDAO := user_d.New(db)
+	taskDAO := task_d.New(db)
+	challengeDAO := challenge_d.New(db)
+	jobDAO := job_d.New(db)
+	fingerprintDAO := fingerprint_d.New(db)
+	scorecardDAO := scorecard_d.New(db)
+	auditDAO := audit_d.New(db)
+	sessionDAO := session_d.New(db)
+	apiKeyDAO := apikey_d.New(db)
+	passwordResetDAO := passwordreset_d.New(db)
+
+	// Send emails through the mail server if there is one, otherwise
+	// only log them.
+	var mail mailer.Mailer = mailer.NewLog()
+	if c.SMTPHost != "" {
+		mail, err = mailer.NewSMTP(c.SMTPHost, c.SMTPPort, c.SMTPUsername, c.SMTPPassword, c.MailFrom)
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	// Setup use cases.
 	oauthUC := github_oauth.New(c)
-	userUC := user_uc.New(c, accountDAO, userDAO)
+	jobUC := job_uc.New(c, jobDAO)
+	userUC := user_uc.New(c, accountDAO, userDAO, sessionDAO, passwordResetDAO, jobUC, mail)
+	accUC := acc_uc.New(accountDAO, auditDAO)
+	apiKeyUC := apikey_uc.New(c, apiKeyDAO, auditDAO)
+	taskUC := task_uc.New(taskDAO)
+	identityUC := identity_uc.New(accountDAO, userDAO, auditDAO)
+	challengeUC := challenge_uc.New(challengeDAO, taskDAO, userDAO, jobUC, identityUC, mail)
+	gh := github.New(c.GithubAccessToken)
+	store := storage.NewLocal(c.StorageDir)
+	workspaceUC := workspace_uc.New(c, challengeDAO, userDAO, jobUC, gh, store)
+	gradingUC := grading_uc.New(c, challengeDAO, fingerprintDAO, identityUC, gh, store, sandbox.New(sandbox.Limits{
+		Timeout:   c.SandboxTimeout,
+		CPUTime:   c.SandboxCPUTime,
+		MemoryMB:  c.SandboxMemoryMB,
+		Processes: c.SandboxProcesses,
+		Toolchain: c.SandboxToolchain,
+	}))
+	scorecardUC := scorecard_uc.New(challengeDAO, taskDAO, scorecardDAO)
+
+	// Setup background job workers.
+	pool := worker.New(jobDAO, worker.Options{
+		Concurrency:  c.WorkerConcurrency,
+		PollInterval: c.WorkerPollInterval,
+		LockFor:      c.JobLockFor,
+		BackoffBase:  c.JobBackoffBase,
+		BackoffMax:   c.JobBackoffMax,
+	})
+	pool.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, workspaceUC.ProvisionRepo)
+	pool.Register(domain.JobTypeArchiveRepo, func() domain.JobPayload { return new(domain.ArchiveRepoPayload) }, workspaceUC.ArchiveRepo)
+	pool.Register(domain.JobTypeDeleteRepo, func() domain.JobPayload { return new(domain.DeleteRepoPayload) }, workspaceUC.DeleteRepo)
+	pool.Register(domain.JobTypeCollectReview, func() domain.JobPayload { return new(domain.CollectReviewPayload) }, workspaceUC.CollectReview)
+	pool.Register(domain.JobTypeRunTests, func() domain.JobPayload { return new(domain.RunTestsPayload) }, gradingUC.RunTests)
+	pool.Register(domain.JobTypeCheckSimilarity, func() domain.JobPayload { return new(domain.CheckSimilarityPayload) }, gradingUC.CheckSimilarity)
+	pool.Register(domain.JobTypeAnalyzeTimeline, func() domain.JobPayload { return new(domain.AnalyzeTimelinePayload) }, gradingUC.AnalyzeTimeline)
+	pool.Register(domain.JobTypeAnalyzeRefactor, func() domain.JobPayload { return new(domain.AnalyzeRefactorPayload) }, gradingUC.AnalyzeRefactor)
+	pool.Register(domain.JobTypeNotifyChallengeExpired, func() domain.JobPayload { return new(domain.ChallengeExpiredPayload) }, challengeUC.NotifyExpired)
+	pool.Register(domain.JobTypeSendPasswordReset, func() domain.JobPayload { return new(domain.SendPasswordResetPayload) }, userUC.SendPasswordReset)
+	pool.Start()
+
+	worker.RunSweeper("challenge expiry", c.SweepInterval, challengeUC.ExpireOverdue)
+	worker.RunSweeper("challenge jobs", c.SweepInterval, challengeUC.EnqueuePendingJobs)
 
 	// Setup HTTP server.
-	serv := httpserver.New(userDAO)
+	serv := httpserver.New(userDAO, sessionDAO, apiKeyDAO, c.TokenSecret)
 
 	// Setup controllers.
 	userCtrl := user_c.New(userUC)
+	accCtrl := acc_c.New(accUC)
+	apiKeyCtrl := apikey_c.New(apiKeyUC)
 	oauthCtrl := oauth_c.New(oauthUC, userUC)
+	taskCtrl := task_c.New(taskUC)
+	challengeCtrl := challenge_c.New(challengeUC)
+	gradingCtrl := grading_c.New(gradingUC)
+	scorecardCtrl := scorecard_c.New(scorecardUC)
+	identityCtrl := identity_c.New(identityUC)
+	jobCtrl := job_c.New(jobUC)
 
 	// Setup routes.
 	userCtrl.SetupRoutes(serv)
+	if c.SMTPHost != "" {
+		userCtrl.SetupPasswordResetRoutes(serv)
+	} else {
+		zap.S().Warnw("password reset is disabled until a mail server is configured")
+	}
+	accCtrl.SetupRoutes(serv)
+	apiKeyCtrl.SetupRoutes(serv)
 	oauthCtrl.SetupRoutes(serv)
+	taskCtrl.SetupRoutes(serv)
+	challengeCtrl.SetupRoutes(serv)
+	gradingCtrl.SetupRoutes(serv)
+	scorecardCtrl.SetupRoutes(serv)
+	identityCtrl.SetupRoutes(serv)
+	jobCtrl.SetupRoutes(serv)
 
 	// Setup Swagger docs.
 	serv.Echo.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Start server.
-	serv.Start(c.Host)
+	go serv.Start(c.Host)
+
+	// Wait for SIGINT or SIGTERM, then stop taking requests and let
+	// running jobs finish.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	zap.S().Infow("shutting down")
+
+	serv.Shutdown(10 * time.Second)
+	pool.Stop()
 }
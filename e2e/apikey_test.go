@@ -0,0 +1,231 @@
+package e2e
+
+import (
+	"context"
+	"strings"
+
+	acc_c "github.com/anrid/codecoach/internal/controller/account"
+	apikey_c "github.com/anrid/codecoach/internal/controller/apikey"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAPIKeys ...
+func (su *ts) TestAPIKeys() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, admin1, admin1Token := su.signup("API Keys Inc")
+
+	keysURL := su.url("/api/v1/accounts/%s/api-keys", a1.ID)
+	usersURL := su.url("/api/v1/accounts/%s/users", a1.ID)
+
+	// FAIL: Invalid scope.
+	{
+		req := apikey_c.PostAPIKeyRequest{
+			Name:   "ATS sync",
+			Scopes: domain.Scopes{"users:delete_everything"},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", keysURL, admin1Token, &req, &res)
+
+		r.Contains(res.Error, "could not create api key")
+	}
+
+	// POST /api-keys
+	var key *domain.APIKey
+	var secret string
+	{
+		req := apikey_c.PostAPIKeyRequest{
+			Name:   "ATS sync",
+			Scopes: domain.Scopes{domain.ScopeUsersRead, domain.ScopeUsersWrite},
+		}
+		res := apikey_c.PostAPIKeyResponse{}
+
+		_, _ = httpclient.CallWithToken("POST", keysURL, admin1Token, &req, &res)
+
+		r.NotNil(res.APIKey)
+		r.True(strings.HasPrefix(res.Key, res.APIKey.Prefix))
+		r.True(strings.HasPrefix(res.Key, domain.APIKeyPrefix))
+		r.Equal(admin1.ID, res.APIKey.CreatedBy)
+		r.Nil(res.APIKey.LastUsedAt)
+
+		key = res.APIKey
+		secret = res.Key
+	}
+
+	// POST /users with the API key, e.g. creating a candidate from an ATS.
+	{
+		req := user_c.PostUserRequest{
+			GivenName:  "Candi",
+			FamilyName: "Date",
+			Email:      email("candidate"),
+			Password:   password,
+			Role:       domain.RoleCandidate,
+		}
+		res := domain.User{}
+
+		_, _ = httpclient.CallWithToken("POST", usersURL, secret, &req, &res)
+
+		r.NotEmpty(res.ID)
+		r.Equal(domain.RoleCandidate, res.Role)
+
+		list := user_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", usersURL, secret, nil, &list)
+
+		r.Equal(2, list.Total)
+	}
+
+	// FAIL: Keys can't take over the admin's account or make new admins.
+	{
+		req := user_c.PatchUserRequest{Password: "hijacked123"}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s/users/%s", a1.ID, admin1.ID), secret, &req, &res)
+
+		r.Equal("could not update user", res.Error)
+
+		req = user_c.PatchUserRequest{Email: email("hijacked")}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s/users/%s", a1.ID, admin1.ID), secret, &req, &res)
+
+		r.Equal("could not update user", res.Error)
+
+		preq := user_c.PostUserRequest{
+			GivenName:  "Evil",
+			FamilyName: "Admin",
+			Email:      email("evil"),
+			Password:   password,
+			Role:       domain.RoleAdmin,
+		}
+
+		_, _ = httpclient.CallWithToken("POST", usersURL, secret, &preq, &res)
+
+		r.Equal("could not create user", res.Error)
+
+		// The admin's password still works.
+		su.login(a1, admin1)
+	}
+
+	// FAIL: The key doesn't have the tasks:read scope.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/tasks", a1.ID), secret, nil, &res)
+
+		r.Equal("api key is missing scope tasks:read", res.Error)
+
+		req := task_c.PostTaskRequest{
+			Name: "Build a rate limiter",
+			Type: domain.TaskTypeCoding,
+		}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", a1.ID), secret, &req, &res)
+
+		r.Equal("api key is missing scope tasks:write", res.Error)
+	}
+
+	// FAIL: API keys can't be used on unscoped routes, e.g. to manage
+	// API keys or sessions.
+	{
+		req := apikey_c.PostAPIKeyRequest{
+			Name:   "Escalate",
+			Scopes: domain.AllScopes,
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", keysURL, secret, &req, &res)
+
+		r.Equal("api keys cannot be used here", res.Error)
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/sessions", a1.ID), secret, nil, &res)
+
+		r.Equal("api keys cannot be used here", res.Error)
+	}
+
+	// FAIL: Only admins can create API keys.
+	{
+		hm := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+			GivenName:  "Hiring",
+			FamilyName: "Manager",
+			Role:       domain.RoleHiringManager,
+		})
+		hmToken := su.login(a1, hm)
+
+		req := apikey_c.PostAPIKeyRequest{
+			Name:   "Mine",
+			Scopes: domain.Scopes{domain.ScopeUsersRead},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", keysURL, hmToken, &req, &res)
+
+		r.Contains(res.Error, "could not create api key")
+	}
+
+	// GET /api-keys
+	{
+		res := apikey_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", keysURL, admin1Token, nil, &res)
+
+		r.Len(res.APIKeys, 1)
+		r.Equal(key.ID, res.APIKeys[0].ID)
+		r.Equal(key.Prefix, res.APIKeys[0].Prefix)
+		r.NotNil(res.APIKeys[0].LastUsedAt)
+	}
+
+	// DELETE /api-keys/{id}
+	{
+		res := apikey_c.DeleteAPIKeyResponse{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/api-keys/%s", a1.ID, key.ID), admin1Token, nil, &res)
+
+		r.Equal(key.ID, res.ID)
+
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", usersURL, secret, nil, &eres)
+
+		r.Contains(eres.Error, "api key invalid")
+	}
+
+	// Creating and revoking keys is audit-logged.
+	{
+		res := acc_c.GetAuditLogResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/audit-log", a1.ID), admin1Token, nil, &res)
+
+		r.Len(res.Entries, 2)
+		r.Equal(domain.AuditActionRevokeAPIKey, res.Entries[0].Action)
+		r.Equal(domain.AuditActionCreateAPIKey, res.Entries[1].Action)
+		r.Equal(key.ID, res.Entries[1].TargetID)
+	}
+
+	// FAIL: Keys stop working once their creator is no longer an admin.
+	{
+		req := apikey_c.PostAPIKeyRequest{
+			Name:   "Demoted",
+			Scopes: domain.Scopes{domain.ScopeUsersRead},
+		}
+		res := apikey_c.PostAPIKeyResponse{}
+
+		_, _ = httpclient.CallWithToken("POST", keysURL, admin1Token, &req, &res)
+
+		r.NotEmpty(res.Key)
+
+		_, err := su.u.Update(context.Background(), a1.ID, admin1.ID, []domain.Field{{Name: "role", Value: domain.RoleHiringManager}})
+		r.NoError(err)
+
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", usersURL, res.Key, nil, &eres)
+
+		r.Contains(eres.Error, "api key invalid")
+	}
+}
@@ -0,0 +1,245 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	acc_c "github.com/anrid/codecoach/internal/controller/account"
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	grading_c "github.com/anrid/codecoach/internal/controller/grading"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBlindReview ...
+func (su *ts) TestBlindReview() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-starter-1a2b3c4", map[string][]byte{
+			"main.go": []byte("package main\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/starter"] = b
+	}
+
+	// Signup
+	a1, _, admin1Token := su.signup("Blind Inc")
+
+	// POST /users and POST /login (hiring manager and candidate with
+	// Github login)
+	var cand1 *domain.User
+	tokens := make(map[domain.Role]string)
+	for _, role := range []domain.Role{domain.RoleHiringManager, domain.RoleCandidate} {
+		req := user_c.PostUserRequest{
+			GivenName:  "Some",
+			FamilyName: string(role),
+			Role:       role,
+		}
+		if role == domain.RoleCandidate {
+			req.GithubLogin = "blind-cand"
+		}
+		u := su.createUser(a1, admin1Token, req)
+
+		if role == domain.RoleCandidate {
+			cand1 = u
+		}
+
+		tokens[role] = su.login(a1, u)
+	}
+	hmToken := tokens[domain.RoleHiringManager]
+	pseudonym := domain.NewPseudonym(a1.ID, cand1.ID)
+
+	// POST /tasks
+	task1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name:           "Blind task",
+		Type:           domain.TaskTypeCoding,
+		GithubRepoName: "acme/starter",
+	})
+
+	// POST /challenges
+	ch1 := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+		ForUserID: cand1.ID,
+		TaskIDs:   []domain.ID{task1.ID},
+	})
+
+	p := su.newPool()
+
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Start()
+	defer p.Stop()
+
+	// Serve diffs from the fake Github API.
+	diffURL := func(path string, args ...interface{}) string {
+		return "http://localhost:10096" + fmt.Sprintf(path, args...)
+	}
+	{
+		serv := httpserver.New(su.u, su.se, su.k, su.cfg.TokenSecret)
+		grading_c.New(grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, &fakeSandbox{})).SetupRoutes(serv)
+
+		go func() {
+			_ = serv.Echo.Start(":10096")
+		}()
+		defer func() {
+			_ = serv.Echo.Shutdown(context.Background())
+		}()
+
+		r.Eventually(func() bool {
+			_, err := httpclient.Call("GET", diffURL("/"), nil, nil)
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(a1, ch1, tokens[domain.RoleCandidate])
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(a1, ch1, tokens[domain.RoleCandidate])
+
+	// Candidates always see their own identity.
+	r.Contains(repo.Name, "blind-cand")
+
+	// The candidate signs their work.
+	gh.mux.Lock()
+	gh.repos[repo.Name]["main.go"] = []byte("// Written by Blind-Cand <" + cand1.Email + ">\npackage main\n")
+	gh.mux.Unlock()
+
+	// Without blind review, hiring managers see the candidate's identity.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), hmToken, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.Contains(res.Details.Progress[0].Repo.Name, "blind-cand")
+	}
+
+	// FAIL: Only admins turn on blind review.
+	{
+		on := true
+		req := acc_c.PatchAccountRequest{BlindReview: &on}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s", a1.ID), hmToken, &req, &res)
+
+		r.Contains(res.Error, "could not update account")
+	}
+
+	// PATCH /accounts/{id} (turn on blind review)
+	{
+		on := true
+		req := acc_c.PatchAccountRequest{BlindReview: &on}
+		res := domain.Account{}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s", a1.ID), admin1Token, &req, &res)
+
+		r.True(res.Settings.BlindReview)
+	}
+
+	// GET /challenges/{id} (hiring manager)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), hmToken, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.NotContains(res.Details.Progress[0].Repo.Name, "blind-cand")
+		r.Contains(res.Details.Progress[0].Repo.Name, pseudonym.Login())
+		r.NotContains(res.Details.Progress[0].Repo.URL, "blind-cand")
+	}
+
+	// GET /challenges (hiring manager)
+	{
+		res := challenge_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges", a1.ID), hmToken, nil, &res)
+
+		r.Len(res.Challenges, 1)
+		r.NotContains(res.Challenges[0].Details.Progress[0].Repo.Name, "blind-cand")
+	}
+
+	// GET /challenges/{id}/tasks/{task_id}/diff (hiring manager)
+	{
+		res := domain.SubmissionDiff{}
+
+		_, _ = httpclient.CallWithToken("GET", diffURL("/api/v1/accounts/%s/challenges/%s/tasks/%s/diff", a1.ID, ch1.ID, task1.ID), hmToken, nil, &res)
+
+		r.Len(res.Files, 1)
+		r.NotContains(res.Files[0].Patch, "Blind-Cand")
+		r.NotContains(res.Files[0].Patch, cand1.Email)
+		r.Contains(res.Files[0].Patch, "Written by "+pseudonym.Login()+" <"+pseudonym.Email()+">")
+	}
+
+	// Admins always see the candidate's identity.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), admin1Token, nil, &res)
+
+		r.Contains(res.Details.Progress[0].Repo.Name, "blind-cand")
+	}
+
+	// FAIL: Only admins reveal identities.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/users/%s/reveal", a1.ID, cand1.ID), hmToken, nil, &res)
+
+		r.Contains(res.Error, "could not reveal identity")
+	}
+
+	// POST /users/{id}/reveal (admin)
+	{
+		res := domain.User{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/users/%s/reveal", a1.ID, cand1.ID), admin1Token, nil, &res)
+
+		r.Equal(cand1.Email, res.Email)
+	}
+
+	// GET /challenges/{id} (hiring manager, after reveal)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), hmToken, nil, &res)
+
+		r.Contains(res.Details.Progress[0].Repo.Name, "blind-cand")
+	}
+
+	// GET /audit-log (admin)
+	{
+		res := acc_c.GetAuditLogResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/audit-log", a1.ID), admin1Token, nil, &res)
+
+		r.Len(res.Entries, 2)
+		r.Equal(domain.AuditActionRevealIdentity, res.Entries[0].Action)
+		r.Equal(cand1.ID, res.Entries[0].TargetID)
+		r.Equal(domain.AuditActionSetBlindReview, res.Entries[1].Action)
+		r.Equal("true", res.Entries[1].Details)
+	}
+
+	// FAIL: Hiring managers can't see the audit log.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/audit-log", a1.ID), hmToken, nil, &res)
+
+		r.Contains(res.Error, "could not get audit log")
+	}
+}
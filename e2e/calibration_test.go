@@ -0,0 +1,156 @@
+package e2e
+
+import (
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	scorecard_c "github.com/anrid/codecoach/internal/controller/scorecard"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCalibration ...
+func (su *ts) TestCalibration() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, admin1, admin1Token := su.signup("Calibration Inc")
+
+	// POST /users and POST /login (two hiring managers and a candidate)
+	users := make(map[string]*domain.User)
+	tokens := make(map[string]string)
+	for _, name := range []string{"harsh", "lenient", "candidate"} {
+		role := domain.RoleHiringManager
+		if name == "candidate" {
+			role = domain.RoleCandidate
+		}
+
+		users[name] = su.createUser(a1, admin1Token, user_c.PostUserRequest{
+			GivenName:  "Some",
+			FamilyName: name,
+			Role:       role,
+		})
+		tokens[name] = su.login(a1, users[name])
+	}
+
+	// POST /tasks and PUT /tasks/{id}/rubric
+	task1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name: "Build a rate limiter",
+		Type: domain.TaskTypeCoding,
+	})
+	{
+		req := task_c.PutRubricRequest{
+			Criteria: []task_c.RubricCriterion{
+				{Name: "Correctness", Weight: 2},
+				{Name: "Style", Weight: 1},
+			},
+		}
+		res := domain.Task{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/tasks/%s/rubric", a1.ID, task1.ID), admin1Token, &req, &res)
+
+		r.NotNil(res.Details.Rubric)
+	}
+
+	// POST /challenges (three of them)
+	var challenges []*domain.Challenge
+	for i := 0; i < 3; i++ {
+		challenges = append(challenges, su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+			ForUserID: users["candidate"].ID,
+			TaskIDs:   []domain.ID{task1.ID},
+		}))
+	}
+
+	score := func(token string, ch *domain.Challenge, correctness, style int) {
+		req := scorecard_c.PutScorecardRequest{
+			Scores: []scorecard_c.CriterionScore{
+				{Criterion: "Correctness", Score: correctness},
+				{Criterion: "Style", Score: style},
+			},
+		}
+		res := domain.Scorecard{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch.ID, task1.ID), token, &req, &res)
+
+		r.NotEmpty(res.ReviewerID)
+	}
+
+	// The harsh reviewer scores the first challenge.
+	score(tokens["harsh"], challenges[0], 2, 1)
+
+	// GET /challenges/{id}/scorecards (lenient reviewer, before scoring)
+	{
+		res := domain.ChallengeScorecards{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/scorecards", a1.ID, challenges[0].ID), tokens["lenient"], nil, &res)
+
+		// Scoring is blind until the reviewer has scored the task.
+		r.Len(res.Tasks, 1)
+		r.True(res.Tasks[0].Blind)
+		r.Equal(1, res.Tasks[0].Reviewers)
+		r.Zero(res.Tasks[0].Overall)
+		r.Empty(res.Scorecards)
+	}
+
+	score(tokens["lenient"], challenges[0], 4, 5)
+
+	// GET /challenges/{id}/scorecards (lenient reviewer, after scoring)
+	{
+		res := domain.ChallengeScorecards{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/scorecards", a1.ID, challenges[0].ID), tokens["lenient"], nil, &res)
+
+		r.Len(res.Tasks, 1)
+		r.False(res.Tasks[0].Blind)
+		r.Equal(2, res.Tasks[0].Reviewers)
+		r.Len(res.Scorecards, 2)
+		r.InDelta(3.0, res.Tasks[0].Overall, 0.0001)
+
+		r.Len(res.Tasks[0].Criteria, 2)
+		r.Equal("Correctness", res.Tasks[0].Criteria[0].Criterion)
+		r.InDelta(3.0, res.Tasks[0].Criteria[0].Mean, 0.0001)
+		r.Equal(2, res.Tasks[0].Criteria[0].Min)
+		r.Equal(4, res.Tasks[0].Criteria[0].Max)
+	}
+
+	score(tokens["harsh"], challenges[1], 1, 2)
+	score(tokens["lenient"], challenges[1], 3, 4)
+	score(tokens["harsh"], challenges[2], 3, 3)
+	score(tokens["lenient"], challenges[2], 5, 4)
+	score(admin1Token, challenges[2], 4, 4)
+
+	// FAIL: Only admins see the calibration report.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/scorecards/calibration", a1.ID), tokens["harsh"], nil, &res)
+
+		r.Contains(res.Error, "could not get calibration report")
+	}
+
+	// GET /scorecards/calibration
+	{
+		res := domain.CalibrationReport{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/scorecards/calibration", a1.ID), admin1Token, nil, &res)
+
+		r.Equal(7, res.Scorecards)
+		r.Equal(3, res.Samples)
+		r.NotNil(res.Alpha)
+		r.Less(*res.Alpha, 0.5)
+
+		r.Len(res.Criteria, 2)
+		r.Equal("Correctness", res.Criteria[0].Criterion)
+		r.Equal(3, res.Criteria[0].Samples)
+		r.Greater(res.Criteria[0].Variance, 0.5)
+
+		tendencies := make(map[domain.ID]domain.ReviewerTendency)
+		for _, rc := range res.Reviewers {
+			tendencies[rc.ReviewerID] = rc.Tendency
+		}
+		r.Equal(domain.TendencyHarsh, tendencies[users["harsh"].ID])
+		r.Equal(domain.TendencyLenient, tendencies[users["lenient"].ID])
+		r.Equal(domain.TendencyUnknown, tendencies[admin1.ID])
+	}
+}
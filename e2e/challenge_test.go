@@ -0,0 +1,267 @@
+package e2e
+
+import (
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestChallenges ...
+func (su *ts) TestChallenges() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, _, admin1Token := su.signup("Challenge Accepted")
+
+	// POST /users and POST /login (hiring manager)
+	hm1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:  "Ace",
+		FamilyName: "Base",
+		Role:       domain.RoleHiringManager,
+	})
+	hm1Token := su.login(a1, hm1)
+
+	// POST /tasks
+	var t1 *domain.Task
+	{
+		req := task_c.PostTaskRequest{
+			Name:             "Refactor the payment module",
+			Type:             domain.TaskTypeRefactor,
+			GithubRepoName:   "payment-module-starter",
+			TimeLimitMinutes: 90,
+		}
+		res := domain.Task{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", a1.ID), hm1Token, &req, &res)
+
+		r.NotEmpty(res.ID)
+		r.Equal(a1.ID, res.AccountID)
+		r.Equal(req.Type, res.Type)
+		r.Equal(90*time.Minute, res.Details.TimeLimit)
+
+		t1 = &res
+	}
+
+	// POST /tasks (code review task)
+	{
+		req := task_c.PostTaskRequest{
+			Name: "Review the auth pull request",
+			Type: domain.TaskTypeCodeReview,
+		}
+		res := domain.Task{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", a1.ID), admin1Token, &req, &res)
+
+		r.NotEmpty(res.ID)
+	}
+
+	// FAIL: Invalid task type.
+	{
+		req := task_c.PostTaskRequest{
+			Name: "Nope",
+			Type: "interview",
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", a1.ID), admin1Token, &req, &res)
+
+		r.Contains(res.Error, "validation error: type")
+	}
+
+	// PATCH /tasks/{id}
+	{
+		req := task_c.PatchTaskRequest{
+			Name: "updated-" + t1.Name,
+		}
+		res := domain.Task{}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s/tasks/%s", a1.ID, t1.ID), hm1Token, &req, &res)
+
+		r.Equal(req.Name, res.Name)
+		r.Equal(t1.Details.GithubRepoName, res.Details.GithubRepoName)
+		r.NotNil(res.UpdatedAt)
+	}
+
+	// GET /tasks filtered by type.
+	{
+		res := task_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/tasks?type=refactor", a1.ID), hm1Token, nil, &res)
+
+		r.Equal(1, res.Total)
+		r.Equal(t1.ID, res.Tasks[0].ID)
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/tasks?type=refactor,code_review", a1.ID), hm1Token, nil, &res)
+
+		r.Equal(2, res.Total)
+	}
+
+	// POST /users (candidate)
+	cand1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:  "Candy",
+		FamilyName: "Date",
+		Role:       domain.RoleCandidate,
+	})
+
+	// POST /challenges
+	var ch1 *domain.Challenge
+	{
+		req := challenge_c.PostChallengeRequest{
+			ForUserID: cand1.ID,
+			TaskIDs:   []domain.ID{t1.ID},
+		}
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges", a1.ID), hm1Token, &req, &res)
+
+		r.NotEmpty(res.ID)
+		r.Equal(domain.StatusNew, res.Status)
+		r.Equal(hm1.ID, res.CreatedByUserID)
+		r.Len(res.Details.Tasks, 1)
+
+		ch1 = &res
+	}
+
+	// FAIL: Challenges can only be created for candidates.
+	{
+		req := challenge_c.PostChallengeRequest{
+			ForUserID: hm1.ID,
+			TaskIDs:   []domain.ID{t1.ID},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges", a1.ID), admin1Token, &req, &res)
+
+		r.Contains(res.Error, "could not create challenge")
+	}
+
+	// PATCH /challenges/{id} - set deadline.
+	{
+		req := challenge_c.PatchChallengeRequest{
+			ExpiresAt: time.Now().Add(48 * time.Hour),
+		}
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("PATCH", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), hm1Token, &req, &res)
+
+		r.Equal(domain.StatusScheduled, res.Status)
+		r.NotNil(res.ExpiresAt)
+	}
+
+	// GET /challenges filtered by status and candidate.
+	{
+		res := challenge_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges?status=scheduled&for_user_id=%s", a1.ID, cand1.ID), hm1Token, nil, &res)
+
+		r.Equal(1, res.Total)
+		r.Equal(ch1.ID, res.Challenges[0].ID)
+	}
+
+	// POST /challenges/{id}/cancel
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/cancel", a1.ID, ch1.ID), admin1Token, nil, &res)
+
+		r.Equal(domain.StatusCanceled, res.Status)
+		r.Len(res.Details.History, 3)
+	}
+
+	// FAIL: A canceled challenge cannot be canceled again.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/cancel", a1.ID, ch1.ID), admin1Token, nil, &res)
+
+		r.Contains(res.Error, "illegal status transition")
+	}
+
+	// POST /login (candidate)
+	cand1Token := su.login(a1, cand1)
+
+	// POST /challenges (for candidate to start)
+	ch2 := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+		ForUserID: cand1.ID,
+		TaskIDs:   []domain.ID{t1.ID},
+	})
+
+	// GET /me/challenges (candidate)
+	{
+		res := challenge_c.GetListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/me/challenges?status=new", a1.ID), cand1Token, nil, &res)
+
+		r.Equal(1, res.Total)
+		r.Equal(ch2.ID, res.Challenges[0].ID)
+	}
+
+	// FAIL: Candidates cannot cancel challenges.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/cancel", a1.ID, ch2.ID), cand1Token, nil, &res)
+
+		r.Contains(res.Error, "could not cancel challenge")
+	}
+
+	// FAIL: Only the candidate can start their own challenge.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/start", a1.ID, ch2.ID), hm1Token, nil, &res)
+
+		r.Contains(res.Error, "could not start challenge")
+	}
+
+	// POST /challenges/{id}/start (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/start", a1.ID, ch2.ID), cand1Token, nil, &res)
+
+		r.Equal(domain.StatusActive, res.Status)
+		r.NotNil(res.StartedAt)
+		r.Len(res.Details.Progress, 1)
+	}
+
+	// GET /challenges/{id}/progress (candidate)
+	{
+		res := domain.ChallengeProgress{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/progress", a1.ID, ch2.ID), cand1Token, nil, &res)
+
+		r.Len(res.Tasks, 1)
+		r.NotNil(res.Tasks[0].RemainingSeconds)
+		r.True(*res.Tasks[0].RemainingSeconds > 80*60)
+		r.Nil(res.Tasks[0].SubmittedAt)
+	}
+
+	// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", a1.ID, ch2.ID, t1.ID), cand1Token, nil, &res)
+
+		r.Equal(domain.StatusCompleted, res.Status)
+		r.NotNil(res.Details.Progress[0].SubmittedAt)
+	}
+
+	// FAIL: A completed challenge cannot be submitted to or restarted.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", a1.ID, ch2.ID, t1.ID), cand1Token, nil, &res)
+
+		r.Contains(res.Error, "could not submit task")
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/start", a1.ID, ch2.ID), cand1Token, nil, &res)
+
+		r.Contains(res.Error, "illegal status transition")
+	}
+}
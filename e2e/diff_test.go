@@ -0,0 +1,147 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	grading_c "github.com/anrid/codecoach/internal/controller/grading"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubmissionDiff ...
+func (su *ts) TestSubmissionDiff() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-starter-1a2b3c4", map[string][]byte{
+			"main.go":   []byte("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"),
+			"README.md": []byte("# Starter\n"),
+			"logo.png":  {0x89, 'P', 'N', 'G', 0, 0, 0, 0},
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/starter"] = b
+	}
+
+	// Signup, POST /users, POST /tasks, POST /challenges and POST /login
+	f := su.setupChallenge("Diff Inc", task_c.PostTaskRequest{
+		Name:           "Say goodbye",
+		Type:           domain.TaskTypeCoding,
+		GithubRepoName: "acme/starter",
+	}, nil)
+
+	p := su.newPool()
+
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Start()
+	defer p.Stop()
+
+	// Serve diffs from the fake Github API.
+	diffURL := func(path string, args ...interface{}) string {
+		return "http://localhost:10097" + fmt.Sprintf(path, args...)
+	}
+	{
+		serv := httpserver.New(su.u, su.se, su.k, su.cfg.TokenSecret)
+		grading_c.New(grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, &fakeSandbox{})).SetupRoutes(serv)
+
+		go func() {
+			_ = serv.Echo.Start(":10097")
+		}()
+		defer func() {
+			_ = serv.Echo.Shutdown(context.Background())
+		}()
+
+		r.Eventually(func() bool {
+			_, err := httpclient.Call("GET", diffURL("/"), nil, nil)
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(f.Account, f.Challenge, f.CandidateToken)
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(f.Account, f.Challenge, f.CandidateToken)
+
+	// The candidate changes, adds and removes files.
+	gh.mux.Lock()
+	gh.repos[repo.Name]["main.go"] = []byte("package main\n\nfunc main() {\n\tprintln(\"goodbye\")\n}\n")
+	gh.repos[repo.Name]["bye.go"] = []byte("package main\n")
+	gh.repos[repo.Name]["logo.png"] = []byte{0x89, 'P', 'N', 'G', 0, 0, 0, 1}
+	delete(gh.repos[repo.Name], "README.md")
+	gh.mux.Unlock()
+
+	// The task's source repo changes after the repo was provisioned.
+	// Diffs still compare against the starter code the candidate got.
+	r.NotEmpty(repo.StarterCommit)
+	{
+		b, err := zipball("acme-starter-5d6e7f8", map[string][]byte{
+			"main.go":   []byte("package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"),
+			"README.md": []byte("# Starter v2\n"),
+		})
+		r.NoError(err)
+
+		gh.mux.Lock()
+		gh.zipballs["acme/starter"] = b
+		gh.mux.Unlock()
+	}
+
+	// FAIL: Candidates cannot see diffs.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", diffURL("/api/v1/accounts/%s/challenges/%s/tasks/%s/diff", f.Account.ID, f.Challenge.ID, f.Task.ID), f.CandidateToken, nil, &res)
+
+		r.Contains(res.Error, "could not get diff")
+	}
+
+	// GET /challenges/{id}/tasks/{task_id}/diff (admin)
+	{
+		res := domain.SubmissionDiff{}
+
+		_, _ = httpclient.CallWithToken("GET", diffURL("/api/v1/accounts/%s/challenges/%s/tasks/%s/diff", f.Account.ID, f.Challenge.ID, f.Task.ID), f.AdminToken, nil, &res)
+
+		r.Equal(fmt.Sprintf("%040d", 3), res.Commit)
+		r.Equal(1, res.Added)
+		r.Equal(1, res.Removed)
+		r.Equal(2, res.Modified)
+		r.Equal(3, res.Additions)
+		r.Equal(2, res.Deletions)
+		r.False(res.Truncated)
+
+		r.Len(res.Files, 4)
+
+		r.Equal("README.md", res.Files[0].Path)
+		r.Equal(domain.FileDiffStatusRemoved, res.Files[0].Status)
+		r.Equal("@@ -1 +0,0 @@\n-# Starter\n", res.Files[0].Patch)
+
+		r.Equal("bye.go", res.Files[1].Path)
+		r.Equal(domain.FileDiffStatusAdded, res.Files[1].Status)
+		r.Equal(1, res.Files[1].Additions)
+
+		r.Equal("logo.png", res.Files[2].Path)
+		r.Equal(domain.FileDiffStatusModified, res.Files[2].Status)
+		r.True(res.Files[2].Binary)
+		r.Empty(res.Files[2].Patch)
+
+		r.Equal("main.go", res.Files[3].Path)
+		r.Equal(domain.FileDiffStatusModified, res.Files[3].Status)
+		r.Equal(1, res.Files[3].Additions)
+		r.Equal(1, res.Files[3].Deletions)
+		r.Contains(res.Files[3].Patch, "-\tprintln(\"hello\")\n+\tprintln(\"goodbye\")\n")
+	}
+}
@@ -9,14 +9,36 @@ import (
 
 	"github.com/anrid/codecoach/internal/config"
 	acc_c "github.com/anrid/codecoach/internal/controller/account"
+	apikey_c "github.com/anrid/codecoach/internal/controller/apikey"
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	identity_c "github.com/anrid/codecoach/internal/controller/identity"
+	job_c "github.com/anrid/codecoach/internal/controller/job"
+	scorecard_c "github.com/anrid/codecoach/internal/controller/scorecard"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
 	user_c "github.com/anrid/codecoach/internal/controller/user"
 	"github.com/anrid/codecoach/internal/domain"
 	"github.com/anrid/codecoach/internal/pg"
 	account_dao "github.com/anrid/codecoach/internal/pg/dao/account"
+	apikey_dao "github.com/anrid/codecoach/internal/pg/dao/apikey"
+	audit_dao "github.com/anrid/codecoach/internal/pg/dao/audit"
+	challenge_dao "github.com/anrid/codecoach/internal/pg/dao/challenge"
+	fingerprint_dao "github.com/anrid/codecoach/internal/pg/dao/fingerprint"
+	job_dao "github.com/anrid/codecoach/internal/pg/dao/job"
+	passwordreset_dao "github.com/anrid/codecoach/internal/pg/dao/passwordreset"
+	scorecard_dao "github.com/anrid/codecoach/internal/pg/dao/scorecard"
+	session_dao "github.com/anrid/codecoach/internal/pg/dao/session"
+	task_dao "github.com/anrid/codecoach/internal/pg/dao/task"
 	user_dao "github.com/anrid/codecoach/internal/pg/dao/user"
 	"github.com/anrid/codecoach/internal/pkg/httpclient"
 	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/anrid/codecoach/internal/pkg/mailer"
 	acc_uc "github.com/anrid/codecoach/internal/usecase/account"
+	apikey_uc "github.com/anrid/codecoach/internal/usecase/apikey"
+	challenge_uc "github.com/anrid/codecoach/internal/usecase/challenge"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	scorecard_uc "github.com/anrid/codecoach/internal/usecase/scorecard"
+	task_uc "github.com/anrid/codecoach/internal/usecase/task"
 	user_uc "github.com/anrid/codecoach/internal/usecase/user"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/require"
@@ -27,12 +49,25 @@ import (
 // ts ...
 type ts struct {
 	suite.Suite
+	cfg                 *config.Config
 	db                  *sqlx.DB
 	a                   *account_dao.DAO
 	u                   *user_dao.DAO
+	t                   *task_dao.DAO
+	c                   *challenge_dao.DAO
+	j                   *job_dao.DAO
+	f                   *fingerprint_dao.DAO
+	s                   *scorecard_dao.DAO
+	au                  *audit_dao.DAO
+	se                  *session_dao.DAO
+	k                   *apikey_dao.DAO
+	pr                  *passwordreset_dao.DAO
+	mails               *recordingMailer
 	serv                *httpserver.HTTPServer
+	featureServ         *httpserver.HTTPServer
 	logger              *zap.Logger
 	APIURL              string
+	FeatureAPIURL       string
 	TestTokenExpiration bool
 }
 
@@ -56,6 +91,13 @@ func (su *ts) SetupSuite() {
 	// Override database schema for testing.
 	c.DBName = "codecoach_test"
 
+	// Use a second server with long-lived tokens for feature tests
+	// that take longer than the token expiration test allows.
+	fc := *c
+	fc.Host = ":10098"
+	su.FeatureAPIURL = "localhost:10098"
+	su.cfg = &fc
+
 	// Override token expires at.
 	c.TokenExpires = 1 * time.Second
 	su.TestTokenExpiration = true
@@ -65,28 +107,68 @@ func (su *ts) SetupSuite() {
 	// Setup DAOs.
 	su.a = account_dao.New(su.db)
 	su.u = user_dao.New(su.db)
+	su.t = task_dao.New(su.db)
+	su.c = challenge_dao.New(su.db)
+	su.j = job_dao.New(su.db)
+	su.f = fingerprint_dao.New(su.db)
+	su.s = scorecard_dao.New(su.db)
+	su.au = audit_dao.New(su.db)
+	su.se = session_dao.New(su.db)
+	su.k = apikey_dao.New(su.db)
+	su.pr = passwordreset_dao.New(su.db)
+
+	// Record emails so tests can follow links in them.
+	su.mails = &recordingMailer{}
+
+	su.serv = su.startServer(c)
+	su.featureServ = su.startServer(&fc)
 
+	zap.S().Infow("setup complete")
+}
+
+// startServer sets up use cases, controllers and routes and starts
+// a new HTTP server.
+func (su *ts) startServer(c *config.Config) *httpserver.HTTPServer {
 	// Setup use cases.
-	userUC := user_uc.New(c, su.a, su.u)
-	accUC := acc_uc.New(su.a)
+	jobUC := job_uc.New(c, su.j)
+	userUC := user_uc.New(c, su.a, su.u, su.se, su.pr, jobUC, su.mails)
+	accUC := acc_uc.New(su.a, su.au)
+	apiKeyUC := apikey_uc.New(c, su.k, su.au)
+	taskUC := task_uc.New(su.t)
+	identityUC := identity_uc.New(su.a, su.u, su.au)
+	challengeUC := challenge_uc.New(su.c, su.t, su.u, jobUC, identityUC, mailer.NewLog())
+	scorecardUC := scorecard_uc.New(su.c, su.t, su.s)
 
 	// Setup HTTP server.
-	su.serv = httpserver.New(su.u)
+	serv := httpserver.New(su.u, su.se, su.k, c.TokenSecret)
 
 	// Setup controller.
 	userC := user_c.New(userUC)
 	accC := acc_c.New(accUC)
+	apiKeyC := apikey_c.New(apiKeyUC)
+	taskC := task_c.New(taskUC)
+	challengeC := challenge_c.New(challengeUC)
+	jobC := job_c.New(jobUC)
+	scorecardC := scorecard_c.New(scorecardUC)
+	identityC := identity_c.New(identityUC)
 
 	// Setup routes.
-	userC.SetupRoutes(su.serv)
-	accC.SetupRoutes(su.serv)
+	userC.SetupRoutes(serv)
+	userC.SetupPasswordResetRoutes(serv)
+	accC.SetupRoutes(serv)
+	apiKeyC.SetupRoutes(serv)
+	taskC.SetupRoutes(serv)
+	challengeC.SetupRoutes(serv)
+	jobC.SetupRoutes(serv)
+	scorecardC.SetupRoutes(serv)
+	identityC.SetupRoutes(serv)
 
 	// Start server.
 	go func() {
-		_ = su.serv.Echo.Start(c.Host)
+		_ = serv.Echo.Start(c.Host)
 	}()
 
-	zap.S().Infow("setup complete")
+	return serv
 }
 
 func (su *ts) TearDownSuite() {
@@ -94,6 +176,7 @@ func (su *ts) TearDownSuite() {
 
 	su.db.Close()
 	_ = su.serv.Echo.Shutdown(context.Background())
+	_ = su.featureServ.Echo.Shutdown(context.Background())
 	_ = su.logger.Sync()
 
 	zap.S().Infow("tear down complete")
@@ -139,7 +222,6 @@ func (su *ts) TestAll() {
 		r.False(res.Account.CreatedAt.IsZero())
 		r.False(res.User.CreatedAt.IsZero())
 		r.Empty(res.User.PasswordHash)
-		r.Empty(res.User.Token)
 
 		a1 = res.Account
 		admin1 = res.User
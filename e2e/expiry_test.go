@@ -0,0 +1,178 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/mailer"
+	challenge_uc "github.com/anrid/codecoach/internal/usecase/challenge"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingMailer keeps all sent messages in memory.
+type recordingMailer struct {
+	mux  sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.sent = append(m.sent, msg)
+
+	return nil
+}
+
+func (m *recordingMailer) sentTo(email string) []mailer.Message {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var ms []mailer.Message
+	for _, msg := range m.sent {
+		if msg.To == email {
+			ms = append(ms, msg)
+		}
+	}
+	return ms
+}
+
+// failingJobs is a job queue that's down.
+type failingJobs struct {
+	domain.JobUseCases
+}
+
+func (failingJobs) Enqueue(ctx context.Context, a domain.EnqueueJobArgs) (*domain.Job, error) {
+	return nil, errors.New("job queue is down")
+}
+
+// TestChallengeExpiry ...
+func (su *ts) TestChallengeExpiry() {
+	r := require.New(su.T())
+
+	ctx := context.Background()
+
+	// Signup
+	a1, admin1, admin1Token := su.signup("Times Up")
+
+	// POST /users (candidate)
+	cand1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:  "Cand",
+		FamilyName: "Idate",
+		Role:       domain.RoleCandidate,
+	})
+
+	// POST /login (candidate)
+	cand1Token := su.login(a1, cand1)
+
+	// POST /tasks
+	t1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name: "Beat the clock",
+		Type: domain.TaskTypeCoding,
+	})
+
+	// POST /challenges (one never started, one started, one not overdue)
+	soon := time.Now().Add(2 * time.Second)
+	later := time.Now().Add(time.Hour)
+
+	var chs []*domain.Challenge
+	for _, expiresAt := range []time.Time{soon, soon, later} {
+		expiresAt := expiresAt
+		ch := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+			ForUserID: cand1.ID,
+			TaskIDs:   []domain.ID{t1.ID},
+			ExpiresAt: &expiresAt,
+		})
+
+		r.Equal(domain.StatusScheduled, ch.Status)
+
+		chs = append(chs, ch)
+	}
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(a1, chs[1], cand1Token)
+
+	time.Sleep(time.Until(soon) + 100*time.Millisecond)
+
+	m := new(recordingMailer)
+	uc := challenge_uc.New(su.c, su.t, su.u, job_uc.New(su.cfg, su.j), identity_uc.New(su.a, su.u, su.au), m)
+
+	// Sweep from several servers at once, while the job queue is down.
+	down := challenge_uc.New(su.c, su.t, su.u, failingJobs{}, identity_uc.New(su.a, su.u, su.au), m)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = down.ExpireOverdue(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		r.NoError(err)
+	}
+
+	for i, status := range []domain.Status{domain.StatusExpired, domain.StatusExpired, domain.StatusScheduled} {
+		c, err := su.c.Get(ctx, a1.ID, chs[i].ID)
+		r.NoError(err)
+		r.Equal(status, c.Status)
+	}
+
+	// The notifications are kept with the expired challenges until the
+	// job queue is back up.
+	for _, ch := range chs[:2] {
+		c, err := su.c.Get(ctx, a1.ID, ch.ID)
+		r.NoError(err)
+
+		var notify int
+		for _, pj := range c.Details.PendingJobs {
+			if pj.Type == domain.JobTypeNotifyChallengeExpired {
+				notify++
+			}
+		}
+		r.Equal(1, notify)
+	}
+
+	_, err := uc.EnqueuePendingJobs(ctx)
+	r.NoError(err)
+
+	for _, ch := range chs[:2] {
+		c, err := su.c.Get(ctx, a1.ID, ch.ID)
+		r.NoError(err)
+		r.Empty(c.Details.PendingJobs)
+	}
+
+	// The creator is notified once per expired challenge.
+	p := su.newPool()
+	p.Register(domain.JobTypeNotifyChallengeExpired, func() domain.JobPayload { return new(domain.ChallengeExpiredPayload) }, uc.NotifyExpired)
+	p.Start()
+
+	r.Eventually(func() bool {
+		return len(m.sentTo(admin1.Email)) >= 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	p.Stop()
+
+	ms := m.sentTo(admin1.Email)
+	r.Len(ms, 2)
+	r.Contains(ms[0].Subject, "Cand Idate")
+
+	var bodies []string
+	for _, msg := range ms {
+		bodies = append(bodies, msg.Body)
+	}
+	r.Contains(strings.Join(bodies, "\n"), "never started")
+	r.Contains(strings.Join(bodies, "\n"), "submitted 0 of 1 tasks")
+}
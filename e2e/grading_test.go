@@ -0,0 +1,197 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/sandbox"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSandbox pretends to run `go test -json` and passes TestSolved if
+// the code under test has a `solution.go` file. It also pretends to run
+// `go vet` and reports a problem in `main.go` if it calls Printf with
+// the wrong argument type.
+type fakeSandbox struct {
+	mux      sync.Mutex
+	commands []string
+}
+
+var _ grading_uc.Sandbox = &fakeSandbox{}
+
+func (s *fakeSandbox) Run(ctx context.Context, dir, _, command string) (*sandbox.Result, error) {
+	s.mux.Lock()
+	s.commands = append(s.commands, command)
+	s.mux.Unlock()
+
+	if command == "go vet ./..." {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+		if err != nil || !strings.Contains(string(b), `Printf("%d", "`) {
+			return &sandbox.Result{Duration: time.Second}, nil
+		}
+		out := "# acme\n./main.go:4:2: fmt.Printf format %d has arg \"x\" of wrong type string\n"
+		return &sandbox.Result{Stderr: []byte(out), ExitCode: 1, Duration: time.Second}, nil
+	}
+
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "main.go")); err != nil {
+		return nil, err
+	}
+
+	solved := "fail"
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "solution.go")); err == nil {
+		solved = "pass"
+	}
+
+	out := strings.Join([]string{
+		`go: downloading nothing`,
+		`{"Action":"run","Package":"acme","Test":"TestStarter"}`,
+		`{"Action":"output","Package":"acme","Test":"TestStarter","Output":"=== RUN   TestStarter\n"}`,
+		`{"Action":"pass","Package":"acme","Test":"TestStarter","Elapsed":0.01}`,
+		`{"Action":"run","Package":"acme","Test":"TestSolved"}`,
+		`{"Action":"output","Package":"acme","Test":"TestSolved","Output":"    main_test.go:9: not solved\n"}`,
+		`{"Action":"` + solved + `","Package":"acme","Test":"TestSolved","Elapsed":0.02}`,
+		`{"Action":"` + solved + `","Package":"acme","Elapsed":0.03}`,
+	}, "\n")
+
+	exitCode := 1
+	if solved == "pass" {
+		exitCode = 0
+	}
+
+	return &sandbox.Result{Stdout: []byte(out), ExitCode: exitCode, Duration: time.Second}, nil
+}
+
+// TestGrading ...
+func (su *ts) TestGrading() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-starter-1a2b3c4", map[string][]byte{
+			"main.go": []byte("package acme\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/starter"] = b
+	}
+
+	// Signup, POST /users, POST /tasks, POST /challenges and POST /login
+	f := su.setupChallenge("Grading Inc", task_c.PostTaskRequest{
+		Name:           "Solve the puzzle",
+		Type:           domain.TaskTypeCoding,
+		GithubRepoName: "acme/starter",
+		TestCommand:    "go test -json ./...",
+	}, nil)
+	r.Equal("go test -json ./...", f.Task.Details.TestCommand)
+
+	// FAIL: Code review tasks cannot have a test command.
+	{
+		req := task_c.PostTaskRequest{
+			Name:        "Review something",
+			Type:        domain.TaskTypeCodeReview,
+			TestCommand: "go test -json ./...",
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", f.Account.ID), f.AdminToken, &req, &res)
+
+		r.Contains(res.Error, "could not create task")
+	}
+
+	p := su.newPool()
+
+	sb := &fakeSandbox{}
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	guc := grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, sb)
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeRunTests, func() domain.JobPayload { return new(domain.RunTestsPayload) }, guc.RunTests)
+	p.Start()
+	defer p.Stop()
+
+	// POST /challenges/{id}/start (candidate)
+	{
+		res := su.startChallenge(f.Account, f.Challenge, f.CandidateToken)
+
+		// Candidates never see the hidden test command.
+		r.Len(res.Details.Tasks, 1)
+		r.Empty(res.Details.Tasks[0].Details.TestCommand)
+	}
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(f.Account, f.Challenge, f.CandidateToken)
+
+	// The candidate pushes half a solution.
+	gh.mux.Lock()
+	gh.repos[repo.Name]["puzzle.go"] = []byte("package acme\n")
+	gh.mux.Unlock()
+
+	// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", f.Account.ID, f.Challenge.ID, f.Task.ID), f.CandidateToken, nil, &res)
+
+		r.Equal(domain.StatusCompleted, res.Status)
+	}
+
+	// GET /challenges/{id} (admin) until the task has been graded.
+	var grade *domain.TestGrade
+	r.Eventually(func() bool {
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.AdminToken, nil, &res)
+
+		if len(res.Details.Progress) != 1 {
+			return false
+		}
+		grade = res.Details.Progress[0].Grade
+		return grade != nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	sb.mux.Lock()
+	r.Equal([]string{"go test -json ./..."}, sb.commands)
+	sb.mux.Unlock()
+
+	r.Equal(domain.GradeStatusFailed, grade.Status)
+	r.Equal(fmt.Sprintf("%040d", 2), grade.Commit)
+	r.Equal(1, grade.Passed)
+	r.Equal(1, grade.Failed)
+	r.Equal(2, grade.Total)
+	r.Equal(0.5, grade.Score)
+	r.Equal(1, grade.ExitCode)
+	r.Contains(grade.Log, "go: downloading nothing")
+	r.NotContains(grade.Log, "TestStarter")
+
+	r.Len(grade.Tests, 2)
+	r.Equal("TestStarter", grade.Tests[0].Name)
+	r.Equal(domain.TestStatusPass, grade.Tests[0].Status)
+	r.Equal("TestSolved", grade.Tests[1].Name)
+	r.Equal(domain.TestStatusFail, grade.Tests[1].Status)
+	r.Contains(grade.Tests[1].Output, "not solved")
+
+	// Candidates never see their grade.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.CandidateToken, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.Nil(res.Details.Progress[0].Grade)
+		r.Empty(res.Details.Tasks[0].Details.TestCommand)
+	}
+}
@@ -0,0 +1,206 @@
+package e2e
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/worker"
+	"github.com/stretchr/testify/require"
+)
+
+// password is the password of every user the feature tests create.
+const password = "massa123"
+
+// seq makes emails unique across tests.
+var seq int64
+
+// email returns a new, unique email address.
+func email(name string) string {
+	return fmt.Sprintf("%s-%d-%d@example.com", name, time.Now().UnixNano(), atomic.AddInt64(&seq, 1))
+}
+
+// url returns the URL of path on the feature test server. path is
+// formatted with args, if any.
+func (su *ts) url(path string, args ...interface{}) string {
+	if strings.Contains(path, `%`) && len(args) > 0 {
+		path = fmt.Sprintf(path, args...)
+	}
+	return "http://" + su.FeatureAPIURL + path
+}
+
+// signup signs up a new account and returns it with its admin and the
+// admin's token.
+func (su *ts) signup(accountName string) (*domain.Account, *domain.User, string) {
+	r := require.New(su.T())
+
+	req := user_c.SignupRequest{
+		AccountName: fmt.Sprintf("%s %d", accountName, time.Now().UnixNano()),
+		GivenName:   "Massa",
+		FamilyName:  "Mun",
+		Email:       email("admin"),
+		Password:    password,
+	}
+	res := user_c.SignupResponse{}
+
+	_, _ = httpclient.Call("POST", su.url("/api/v1/signup"), &req, &res)
+
+	r.NotEmpty(res.Token)
+
+	return res.Account, res.User, res.Token
+}
+
+// createUser creates a user in an account. The user gets a unique email
+// and the default password, unless req has them.
+func (su *ts) createUser(a *domain.Account, token string, req user_c.PostUserRequest) *domain.User {
+	r := require.New(su.T())
+
+	if req.Email == "" {
+		req.Email = email(string(req.Role))
+	}
+	if req.Password == "" {
+		req.Password = password
+	}
+	res := domain.User{}
+
+	_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/users", a.ID), token, &req, &res)
+
+	r.NotEmpty(res.ID)
+
+	return &res
+}
+
+// login logs a user with the default password in and returns their
+// token.
+func (su *ts) login(a *domain.Account, u *domain.User) string {
+	r := require.New(su.T())
+
+	req := user_c.LoginRequest{
+		AccountCode: a.Code,
+		Email:       u.Email,
+		Password:    password,
+	}
+	res := user_c.LoginResponse{}
+
+	_, _ = httpclient.Call("POST", su.url("/api/v1/login"), &req, &res)
+
+	r.NotEmpty(res.Token)
+
+	return res.Token
+}
+
+// createTask creates a task in an account.
+func (su *ts) createTask(a *domain.Account, token string, req task_c.PostTaskRequest) *domain.Task {
+	r := require.New(su.T())
+
+	res := domain.Task{}
+
+	_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/tasks", a.ID), token, &req, &res)
+
+	r.NotEmpty(res.ID)
+
+	return &res
+}
+
+// createChallenge creates a challenge in an account.
+func (su *ts) createChallenge(a *domain.Account, token string, req challenge_c.PostChallengeRequest) *domain.Challenge {
+	r := require.New(su.T())
+
+	res := domain.Challenge{}
+
+	_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges", a.ID), token, &req, &res)
+
+	r.NotEmpty(res.ID)
+
+	return &res
+}
+
+// startChallenge starts a challenge as its candidate.
+func (su *ts) startChallenge(a *domain.Account, ch *domain.Challenge, token string) *domain.Challenge {
+	r := require.New(su.T())
+
+	res := domain.Challenge{}
+
+	_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/start", a.ID, ch.ID), token, nil, &res)
+
+	r.Equal(domain.StatusActive, res.Status)
+
+	return &res
+}
+
+// waitForRepo gets the progress of a challenge with one task as its
+// candidate until the task's repo is ready, and returns the repo.
+func (su *ts) waitForRepo(a *domain.Account, ch *domain.Challenge, token string) *domain.TaskRepo {
+	r := require.New(su.T())
+
+	var repo *domain.TaskRepo
+	r.Eventually(func() bool {
+		progress := domain.ChallengeProgress{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/progress", a.ID, ch.ID), token, nil, &progress)
+
+		if len(progress.Tasks) != 1 || progress.Tasks[0].Repo == nil {
+			return false
+		}
+		repo = progress.Tasks[0].Repo
+		return repo.Status == domain.RepoStatusReady
+	}, 5*time.Second, 100*time.Millisecond)
+
+	return repo
+}
+
+// newPool returns a worker pool that polls and retries quickly.
+func (su *ts) newPool() *worker.Pool {
+	return worker.New(su.j, worker.Options{
+		Concurrency:  2,
+		PollInterval: 50 * time.Millisecond,
+		LockFor:      time.Minute,
+		BackoffBase:  10 * time.Millisecond,
+		BackoffMax:   50 * time.Millisecond,
+	})
+}
+
+// fixture is a candidate with a Github login who has been given a
+// challenge with one task, which is where most feature tests start.
+type fixture struct {
+	Account        *domain.Account
+	Admin          *domain.User
+	AdminToken     string
+	Candidate      *domain.User
+	CandidateToken string
+	Task           *domain.Task
+	Challenge      *domain.Challenge
+}
+
+// setupChallenge signs up an account, creates a candidate, task and a
+// challenge for it, and logs the candidate in. The challenge doesn't
+// expire unless expiresAt is set.
+func (su *ts) setupChallenge(accountName string, task task_c.PostTaskRequest, expiresAt *time.Time) *fixture {
+	f := new(fixture)
+
+	f.Account, f.Admin, f.AdminToken = su.signup(accountName)
+
+	f.Candidate = su.createUser(f.Account, f.AdminToken, user_c.PostUserRequest{
+		GivenName:   "Cand",
+		FamilyName:  "Idate",
+		Role:        domain.RoleCandidate,
+		GithubLogin: "cand-idate",
+	})
+	f.CandidateToken = su.login(f.Account, f.Candidate)
+
+	f.Task = su.createTask(f.Account, f.AdminToken, task)
+
+	f.Challenge = su.createChallenge(f.Account, f.AdminToken, challenge_c.PostChallengeRequest{
+		ForUserID: f.Candidate.ID,
+		TaskIDs:   []domain.ID{f.Task.ID},
+		ExpiresAt: expiresAt,
+	})
+
+	return f
+}
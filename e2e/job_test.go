@@ -0,0 +1,188 @@
+package e2e
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	job_c "github.com/anrid/codecoach/internal/controller/job"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	"github.com/anrid/codecoach/internal/worker"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// testJobType ...
+const testJobType domain.JobType = "e2e_test"
+
+// testPayload ...
+type testPayload struct {
+	Fail bool `json:"fail"`
+}
+
+// JobType ...
+func (p *testPayload) JobType() domain.JobType {
+	return testJobType
+}
+
+// Validate ...
+func (p *testPayload) Validate() error {
+	return nil
+}
+
+// TestJobs ...
+func (su *ts) TestJobs() {
+	r := require.New(su.T())
+
+	ctx := context.Background()
+	jobUC := job_uc.New(su.cfg, su.j)
+
+	// Signup
+	a1, _, admin1Token := su.signup("Job Done")
+
+	// Run two pools against the same jobs table to ensure each
+	// job is only ever run once per attempt.
+	var runs int64
+	handler := func(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+		atomic.AddInt64(&runs, 1)
+		if pl.(*testPayload).Fail {
+			return errors.New("failed on purpose")
+		}
+		return nil
+	}
+
+	var pools []*worker.Pool
+	for i := 0; i < 2; i++ {
+		p := su.newPool()
+		p.Register(testJobType, func() domain.JobPayload { return new(testPayload) }, handler)
+		p.Start()
+		pools = append(pools, p)
+	}
+
+	var ids []domain.ID
+	for i := 0; i < 5; i++ {
+		j, err := jobUC.Enqueue(ctx, domain.EnqueueJobArgs{
+			Name:    "e2e job",
+			Payload: &testPayload{},
+		})
+		r.NoError(err)
+		r.Equal(su.cfg.JobMaxAttempts, j.MaxAttempts)
+		ids = append(ids, j.ID)
+	}
+
+	failing, err := jobUC.Enqueue(ctx, domain.EnqueueJobArgs{
+		AccountID:   a1.ID,
+		Name:        "e2e failing job",
+		Payload:     &testPayload{Fail: true},
+		MaxAttempts: 3,
+	})
+	r.NoError(err)
+
+	// Jobs scheduled in the future are not run.
+	runAt := time.Now().Add(time.Hour)
+	scheduled, err := jobUC.Enqueue(ctx, domain.EnqueueJobArgs{
+		Name:    "e2e scheduled job",
+		Payload: &testPayload{},
+		RunAt:   &runAt,
+	})
+	r.NoError(err)
+	r.Equal(domain.StatusScheduled, scheduled.Status)
+
+	// Jobs with malformed payloads are never retried.
+	malformed, err := domain.NewJob("e2e malformed job", &testPayload{})
+	r.NoError(err)
+	malformed.Details = domain.JobDetails(`{"fail": "yes please"}`)
+	r.NoError(su.j.Create(ctx, malformed))
+
+	done := func(ids ...domain.ID) func() bool {
+		return func() bool {
+			js, err := su.j.GetAll(ctx, ids)
+			if err != nil {
+				return false
+			}
+			for _, j := range js {
+				if j.Status != domain.StatusCompleted && j.Status != domain.StatusDead {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	r.Eventually(done(append(ids, failing.ID, malformed.ID)...), 5*time.Second, 50*time.Millisecond)
+
+	js, err := su.j.GetAll(ctx, ids)
+	r.NoError(err)
+	for _, j := range js {
+		r.Equal(domain.StatusCompleted, j.Status)
+		r.Equal(1, j.Attempts)
+		r.NotNil(j.CompletedAt)
+		r.Empty(j.LockedBy)
+	}
+
+	// The failing job is retried until it runs out of attempts.
+	f, err := su.j.Get(ctx, failing.ID)
+	r.NoError(err)
+	r.Equal(domain.StatusDead, f.Status)
+	r.Equal(3, f.Attempts)
+	r.Equal("failed on purpose", f.LastError)
+	r.NotNil(f.FailedAt)
+
+	m, err := su.j.Get(ctx, malformed.ID)
+	r.NoError(err)
+	r.Equal(domain.StatusDead, m.Status)
+	r.Equal(1, m.Attempts)
+	r.Contains(m.LastError, "could not decode payload")
+
+	s, err := su.j.Get(ctx, scheduled.ID)
+	r.NoError(err)
+	r.Equal(domain.StatusScheduled, s.Status)
+
+	r.Equal(int64(5+3), atomic.LoadInt64(&runs))
+
+	// GET /jobs/dead
+	{
+		res := job_c.GetDeadListResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/jobs/dead", a1.ID), admin1Token, nil, &res)
+
+		r.Equal(1, res.Total)
+		r.Equal(failing.ID, res.Jobs[0].ID)
+		r.Equal("failed on purpose", res.Jobs[0].LastError)
+	}
+
+	// POST /jobs/:id/retry
+	{
+		res := domain.Job{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/jobs/%s/retry", a1.ID, failing.ID), admin1Token, nil, &res)
+
+		r.Equal(failing.ID, res.ID)
+		r.Equal(domain.StatusNew, res.Status)
+		r.Equal(0, res.Attempts)
+	}
+
+	// Only dead jobs can be retried.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/jobs/%s/retry", a1.ID, failing.ID), admin1Token, nil, &res)
+
+		r.Contains(res.Error, "could not retry job")
+	}
+
+	r.Eventually(done(failing.ID), 5*time.Second, 50*time.Millisecond)
+
+	for _, p := range pools {
+		p.Stop()
+	}
+
+	f, err = su.j.Get(ctx, failing.ID)
+	r.NoError(err)
+	r.Equal(domain.StatusDead, f.Status)
+	r.Equal(3, f.Attempts)
+
+	r.Equal(int64(5+3+3), atomic.LoadInt64(&runs))
+}
@@ -0,0 +1,169 @@
+package e2e
+
+import (
+	"time"
+
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+const legacyMain = `package main
+
+func main() {
+	fmt.Printf("%d", "x")
+}
+
+func Classify(n int) string {
+	if n < 0 {
+		return "negative"
+	}
+	if n == 0 {
+		return "zero"
+	}
+	if n%2 == 0 && n > 100 {
+		return "big even"
+	}
+	if n%2 == 1 && n > 100 {
+		return "big odd"
+	}
+	if n%2 == 0 {
+		return "even"
+	}
+	return "odd"
+}
+`
+
+const refactoredMain = `package main
+
+// main ...
+func main() {
+	fmt.Printf("%s", "x")
+}
+
+// Classify ...
+func Classify(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n == 0:
+		return "zero"
+	}
+	return parity(n)
+}
+
+func parity(n int) string {
+	if n%2 == 0 {
+		return "even"
+	}
+	return "odd"
+}
+`
+
+// TestRefactorMetrics ...
+func (su *ts) TestRefactorMetrics() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-legacy-1a2b3c4", map[string][]byte{
+			"main.go":      []byte(legacyMain),
+			"main_test.go": []byte("package main\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/legacy"] = b
+	}
+
+	// Signup, POST /users, POST /tasks, POST /challenges and POST /login
+	f := su.setupChallenge("Metrics Inc", task_c.PostTaskRequest{
+		Name:           "Clean up the legacy code",
+		Type:           domain.TaskTypeRefactor,
+		GithubRepoName: "acme/legacy",
+	}, nil)
+
+	p := su.newPool()
+
+	sb := &fakeSandbox{}
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	guc := grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, sb)
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeAnalyzeRefactor, func() domain.JobPayload { return new(domain.AnalyzeRefactorPayload) }, guc.AnalyzeRefactor)
+	p.Start()
+	defer p.Stop()
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(f.Account, f.Challenge, f.CandidateToken)
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(f.Account, f.Challenge, f.CandidateToken)
+
+	// The candidate pushes the refactored code.
+	gh.mux.Lock()
+	gh.repos[repo.Name]["main.go"] = []byte(refactoredMain)
+	gh.mux.Unlock()
+
+	// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", f.Account.ID, f.Challenge.ID, f.Task.ID), f.CandidateToken, nil, &res)
+
+		r.Equal(domain.StatusCompleted, res.Status)
+	}
+
+	// GET /challenges/{id} (admin) until the repo has been analyzed.
+	var m *domain.RefactorReport
+	r.Eventually(func() bool {
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.AdminToken, nil, &res)
+
+		if len(res.Details.Progress) != 1 {
+			return false
+		}
+		m = res.Details.Progress[0].Metrics
+		return m != nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	sb.mux.Lock()
+	r.Equal([]string{"go vet ./...", "go vet ./..."}, sb.commands)
+	sb.mux.Unlock()
+
+	// Test files don't count.
+	r.Equal(1, m.Before.Files)
+	r.Equal(2, m.Before.Functions)
+	r.Equal(3, m.After.Functions)
+	r.Equal(1, m.Delta.Functions)
+
+	r.Equal(8, m.Before.MaxComplexity)
+	r.Equal("Classify", m.Before.Hotspots[0].Name)
+	r.Less(m.Delta.AvgComplexity, 0.0)
+	r.Less(m.Delta.MaxComplexity, 0)
+
+	r.Equal(1, m.Before.LintFindings)
+	r.Equal(0, m.After.LintFindings)
+
+	r.NotNil(m.Before.VetFindings)
+	r.Equal(1, *m.Before.VetFindings)
+	r.Equal(0, *m.After.VetFindings)
+	r.Equal(-1, *m.Delta.VetFindings)
+
+	// Candidates never see the metrics.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.CandidateToken, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.Nil(res.Details.Progress[0].Metrics)
+	}
+}
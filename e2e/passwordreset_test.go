@@ -0,0 +1,208 @@
+package e2e
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/token"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	user_uc "github.com/anrid/codecoach/internal/usecase/user"
+	"github.com/stretchr/testify/require"
+)
+
+var resetLinkRe = regexp.MustCompile(`https?://\S+`)
+
+// TestPasswordReset ...
+func (su *ts) TestPasswordReset() {
+	r := require.New(su.T())
+
+	// resetToken returns the token in the last reset link sent to an
+	// email address.
+	resetToken := func(to string) string {
+		ms := su.mails.sentTo(to)
+		r.NotEmpty(ms)
+
+		link := resetLinkRe.FindString(ms[len(ms)-1].Body)
+		r.NotEmpty(link)
+
+		u, err := url.Parse(link)
+		r.NoError(err)
+
+		return u.Query().Get("token")
+	}
+
+	// Signup
+	a1, admin1, accessToken := su.signup("Forgetful Inc")
+
+	// Reset emails are sent by a job.
+	uc := user_uc.New(su.cfg, su.a, su.u, su.se, su.pr, job_uc.New(su.cfg, su.j), su.mails)
+
+	p := su.newPool()
+	p.Register(domain.JobTypeSendPasswordReset, func() domain.JobPayload { return new(domain.SendPasswordResetPayload) }, uc.SendPasswordReset)
+	p.Start()
+	defer p.Stop()
+
+	// countJobs returns the number of password reset jobs enqueued for
+	// the account.
+	countJobs := func(statuses ...domain.Status) int {
+		q := "SELECT count(*) FROM jobs WHERE account_id = $1 AND type = $2"
+		args := []interface{}{a1.ID, domain.JobTypeSendPasswordReset}
+		if len(statuses) > 0 {
+			q += " AND status = $3"
+			args = append(args, statuses[0])
+		}
+
+		var n int
+		err := su.db.Get(&n, q, args...)
+		r.NoError(err)
+		return n
+	}
+
+	// waitForJobs waits until n password reset jobs have completed.
+	waitForJobs := func(n int) {
+		r.Eventually(func() bool {
+			return countJobs(domain.StatusCompleted) == n
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	requestURL := su.url("/api/v1/password-reset")
+	confirmURL := su.url("/api/v1/password-reset/confirm")
+
+	// Unknown emails and accounts get the same answer as known ones,
+	// and no email is sent.
+	var known user_c.PasswordResetResponse
+	{
+		unknown := email("nobody")
+
+		for _, req := range []user_c.RequestPasswordResetRequest{
+			{AccountCode: a1.Code, Email: unknown},
+			{AccountCode: fmt.Sprintf("no-such-account-%d", time.Now().UnixNano()), Email: admin1.Email},
+		} {
+			res := user_c.PasswordResetResponse{}
+
+			_, _ = httpclient.Call("POST", requestURL, &req, &res)
+
+			r.NotEmpty(res.Message)
+			r.Empty(su.mails.sentTo(req.Email))
+
+			known = res
+		}
+
+		// Only the known account gets a job.
+		waitForJobs(1)
+		r.Empty(su.mails.sentTo(unknown))
+	}
+
+	// POST /password-reset
+	var first string
+	{
+		req := user_c.RequestPasswordResetRequest{AccountCode: a1.Code, Email: admin1.Email}
+		res := user_c.PasswordResetResponse{}
+
+		_, _ = httpclient.Call("POST", requestURL, &req, &res)
+
+		r.Equal(known.Message, res.Message)
+
+		waitForJobs(2)
+		r.Len(su.mails.sentTo(admin1.Email), 1)
+
+		first = resetToken(admin1.Email)
+		r.NotEmpty(first)
+
+		// Only the token's hash is stored.
+		var n int
+		err := su.db.Get(&n, "SELECT count(*) FROM password_resets WHERE account_id = $1 AND token_hash = $2", a1.ID, token.Hash(su.cfg.TokenSecret, first))
+		r.NoError(err)
+		r.Equal(1, n)
+	}
+
+	// Asking again replaces the first link.
+	var second string
+	{
+		req := user_c.RequestPasswordResetRequest{AccountCode: a1.Code, Email: admin1.Email}
+		res := user_c.PasswordResetResponse{}
+
+		_, _ = httpclient.Call("POST", requestURL, &req, &res)
+
+		waitForJobs(3)
+		second = resetToken(admin1.Email)
+		r.NotEqual(first, second)
+
+		creq := user_c.ResetPasswordRequest{Token: first, Password: "massa456"}
+		eres := errorResp{}
+
+		_, _ = httpclient.Call("POST", confirmURL, &creq, &eres)
+
+		r.Equal(domain.ErrPasswordResetInvalid.Error(), eres.Error)
+	}
+
+	// POST /password-reset/confirm
+	{
+		req := user_c.ResetPasswordRequest{Token: second, Password: "massa456"}
+		res := user_c.PasswordResetResponse{}
+
+		_, _ = httpclient.Call("POST", confirmURL, &req, &res)
+
+		r.NotEmpty(res.Message)
+
+		// All existing sessions have ended.
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/secret", a1.ID), accessToken, nil, &eres)
+
+		r.Contains(eres.Error, "token invalid")
+	}
+
+	// FAIL: Reset tokens are single use.
+	{
+		req := user_c.ResetPasswordRequest{Token: second, Password: "massa789"}
+		res := errorResp{}
+
+		_, _ = httpclient.Call("POST", confirmURL, &req, &res)
+
+		r.Equal(domain.ErrPasswordResetInvalid.Error(), res.Error)
+	}
+
+	// Only the new password works.
+	{
+		req := user_c.LoginRequest{AccountCode: a1.Code, Email: admin1.Email, Password: password}
+		eres := errorResp{}
+
+		_, _ = httpclient.Call("POST", su.url("/api/v1/login"), &req, &eres)
+
+		r.Contains(eres.Error, "could not perform login")
+
+		req.Password = "massa456"
+		res := user_c.LoginResponse{}
+
+		_, _ = httpclient.Call("POST", su.url("/api/v1/login"), &req, &res)
+
+		r.NotEmpty(res.Token)
+	}
+
+	// Requests over the rate limit get the same answer, but no job is
+	// enqueued and no email is sent.
+	{
+		limit := su.cfg.PasswordResetRateLimit
+		r.True(limit >= 2)
+
+		for i := 0; i < limit-1; i++ {
+			req := user_c.RequestPasswordResetRequest{AccountCode: a1.Code, Email: admin1.Email}
+			res := user_c.PasswordResetResponse{}
+
+			_, _ = httpclient.Call("POST", requestURL, &req, &res)
+
+			r.Equal(known.Message, res.Message)
+		}
+
+		// One request for the unknown email, limit for the known one.
+		waitForJobs(limit + 1)
+		r.Equal(limit+1, countJobs())
+		r.Len(su.mails.sentTo(admin1.Email), limit)
+	}
+}
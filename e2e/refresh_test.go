@@ -0,0 +1,127 @@
+package e2e
+
+import (
+	"fmt"
+	"time"
+
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRefreshTokens ...
+func (su *ts) TestRefreshTokens() {
+	r := require.New(su.T())
+
+	// Signup
+	var a1 *domain.Account
+	var admin1 *domain.User
+	var token, refreshToken string
+	{
+		req := user_c.SignupRequest{
+			AccountName: fmt.Sprintf("Refresh Inc %d", time.Now().UnixNano()),
+			GivenName:   "Massa",
+			FamilyName:  "Mun",
+			Email:       email("admin"),
+			Password:    password,
+		}
+		res := user_c.SignupResponse{}
+
+		_, _ = httpclient.Call("POST", su.url("/api/v1/signup"), &req, &res)
+
+		r.NotEmpty(res.Token)
+		r.NotEmpty(res.RefreshToken)
+		r.NotEqual(res.Token, res.RefreshToken)
+
+		a1 = res.Account
+		admin1 = res.User
+		token = res.Token
+		refreshToken = res.RefreshToken
+	}
+
+	secretURL := su.url("/api/v1/accounts/%s/secret", a1.ID)
+	refreshURL := su.url("/api/v1/token/refresh")
+
+	// FAIL: Unknown refresh token.
+	{
+		req := user_c.RefreshRequest{RefreshToken: "xxx"}
+		res := errorResp{}
+
+		_, _ = httpclient.Call("POST", refreshURL, &req, &res)
+
+		r.Equal("refresh token invalid", res.Error)
+	}
+
+	// POST /token/refresh - rotates both tokens.
+	first := refreshToken
+	{
+		req := user_c.RefreshRequest{RefreshToken: refreshToken}
+		res := user_c.RefreshResponse{}
+
+		_, _ = httpclient.Call("POST", refreshURL, &req, &res)
+
+		r.NotEmpty(res.Token)
+		r.NotEqual(token, res.Token)
+		r.NotEqual(refreshToken, res.RefreshToken)
+		r.True(res.ExpiresAt.After(time.Now()))
+
+		// The old access token no longer works.
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, token, nil, &eres)
+
+		r.Contains(eres.Error, "token invalid")
+
+		token = res.Token
+		refreshToken = res.RefreshToken
+
+		sres := user_c.GetSecretResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, token, nil, &sres)
+
+		r.Equal(admin1.ID, sres.ID)
+	}
+
+	// Refresh once more, still in the same session.
+	{
+		req := user_c.RefreshRequest{RefreshToken: refreshToken}
+		res := user_c.RefreshResponse{}
+
+		_, _ = httpclient.Call("POST", refreshURL, &req, &res)
+
+		r.NotEmpty(res.Token)
+
+		token = res.Token
+		refreshToken = res.RefreshToken
+
+		list := user_c.GetSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/sessions", a1.ID), token, nil, &list)
+
+		r.Len(list.Sessions, 1)
+	}
+
+	// FAIL: Reusing a refresh token revokes the whole session.
+	{
+		req := user_c.RefreshRequest{RefreshToken: first}
+		res := errorResp{}
+
+		_, _ = httpclient.Call("POST", refreshURL, &req, &res)
+
+		r.Equal("refresh token reused", res.Error)
+
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, token, nil, &eres)
+
+		r.Contains(eres.Error, "token invalid")
+
+		req = user_c.RefreshRequest{RefreshToken: refreshToken}
+		res = errorResp{}
+
+		_, _ = httpclient.Call("POST", refreshURL, &req, &res)
+
+		r.Equal("refresh token invalid", res.Error)
+	}
+}
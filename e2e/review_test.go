@@ -0,0 +1,220 @@
+package e2e
+
+import (
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/github"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCodeReviews ...
+func (su *ts) TestCodeReviews() {
+	r := require.New(su.T())
+
+	// Create zipballs of the source repo's default and review branches.
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-review-1a2b3c4", map[string][]byte{
+			"main.go":   []byte("package main\n"),
+			"legacy.go": []byte("package main\n\n// Old stuff.\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/review"] = b
+
+		b, err = zipball("acme-review-5d6e7f8", map[string][]byte{
+			"main.go": []byte("package main\n\nfunc main() { panic(nil) }\n"),
+			"new.go":  []byte("package main\n\nvar x int\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/review@fix-bug"] = b
+	}
+
+	// Signup
+	a1, _, admin1Token := su.signup("Review Inc")
+
+	// POST /users (candidate with Github login)
+	cand1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:   "Cand",
+		FamilyName:  "Idate",
+		Role:        domain.RoleCandidate,
+		GithubLogin: "Cand-Idate",
+	})
+
+	// POST /tasks (code review)
+	task1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name:           "Review the bug fix",
+		Type:           domain.TaskTypeCodeReview,
+		GithubRepoName: "acme/review",
+		ReviewBranch:   "fix-bug",
+		ExpectedFindings: []task_c.ExpectedFinding{
+			{Path: "main.go", StartLine: 3, EndLine: 3, Category: domain.FindingCategoryBug, Weight: 2},
+			{Path: "new.go", StartLine: 3, Category: domain.FindingCategoryStyle},
+		},
+	})
+	r.Equal("fix-bug", task1.Details.ReviewBranch)
+	r.Len(task1.Details.ExpectedFindings, 2)
+
+	// POST /challenges
+	ch1 := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+		ForUserID: cand1.ID,
+		TaskIDs:   []domain.ID{task1.ID},
+	})
+
+	// POST /login (candidate)
+	cand1Token := su.login(a1, cand1)
+
+	p := su.newPool()
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, storage.NewLocal(su.T().TempDir()))
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeCollectReview, func() domain.JobPayload { return new(domain.CollectReviewPayload) }, wuc.CollectReview)
+	p.Start()
+	defer p.Stop()
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(a1, ch1, cand1Token)
+
+	// Candidates never see the expected findings.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), cand1Token, nil, &res)
+
+		r.Len(res.Details.Tasks, 1)
+		r.Empty(res.Details.Tasks[0].Details.ExpectedFindings)
+	}
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(a1, ch1, cand1Token)
+
+	r.Equal(1, repo.PullNumber)
+	r.Contains(repo.PullURL, repo.Name+"/pull/1")
+
+	gh.mux.Lock()
+
+	// The default branch holds the base code and the pull request holds
+	// the changes from the source repo's review branch.
+	r.Equal("package main\n", string(gh.repos[repo.Name]["main.go"]))
+	r.Len(gh.repos[repo.Name], 2)
+
+	review := gh.branches[repo.Name+"@review"]
+	r.Len(review, 2)
+	r.Contains(string(review["main.go"]), "panic(nil)")
+	r.Contains(review, "new.go")
+	r.NotContains(review, "legacy.go")
+	r.Equal("Review the bug fix", gh.pulls[repo.Name].Title)
+
+	// The candidate and someone else review the pull request.
+	gh.reviews[repo.Name] = []github.Review{
+		{ID: 1, State: "COMMENTED", Body: "Drive-by comment", SubmittedAt: "2020-01-01T10:00:00Z"},
+		{ID: 2, State: "CHANGES_REQUESTED", Body: "Don't panic", CommitID: "abc", SubmittedAt: "2020-01-01T11:00:00Z"},
+	}
+	gh.reviews[repo.Name][0].User.Login = "someone-else"
+	gh.reviews[repo.Name][1].User.Login = "cand-idate"
+
+	for i, body := range []string{"Why panic here?", "Unused variable", "Not mine", "Why was legacy.go removed?"} {
+		c := github.ReviewComment{
+			ID:                  int64(i + 1),
+			PullRequestReviewID: 2,
+			Path:                "main.go",
+			Line:                3,
+			Side:                "RIGHT",
+			Body:                body,
+			CreatedAt:           "2020-01-01T10:30:00Z",
+		}
+		c.User.Login = "cand-idate"
+		if body == "Not mine" {
+			c.User.Login = "someone-else"
+		}
+		gh.comments[repo.Name] = append(gh.comments[repo.Name], c)
+	}
+
+	gh.mux.Unlock()
+
+	// FAIL: The review isn't available before it's been collected.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/review", a1.ID, ch1.ID, task1.ID), admin1Token, nil, &res)
+
+		r.Contains(res.Error, "could not get review")
+	}
+
+	// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", a1.ID, ch1.ID, task1.ID), cand1Token, nil, &res)
+
+		r.Equal(domain.StatusCompleted, res.Status)
+	}
+
+	// GET /challenges/{id}/tasks/{task_id}/review (admin) once collected.
+	var cr domain.CodeReview
+	r.Eventually(func() bool {
+		cr = domain.CodeReview{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/review", a1.ID, ch1.ID, task1.ID), admin1Token, nil, &cr)
+
+		return !cr.CollectedAt.IsZero()
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// Only the candidate's review and comments are collected, across
+	// all pages.
+	r.Equal(1, cr.PullNumber)
+	r.Len(cr.Reviews, 1)
+	r.Equal(int64(2), cr.Reviews[0].ID)
+	r.Equal("CHANGES_REQUESTED", cr.Reviews[0].State)
+	r.Equal("Don't panic", cr.Reviews[0].Body)
+	r.NotNil(cr.Reviews[0].SubmittedAt)
+
+	r.Len(cr.Comments, 3)
+	for _, c := range cr.Comments {
+		r.NotEqual("Not mine", c.Body)
+		r.Equal(int64(2), c.ReviewID)
+		r.Equal("main.go", c.Path)
+		r.Equal(3, c.Line)
+		r.False(c.CreatedAt.IsZero())
+	}
+
+	// Comments on the planted bug are matched to it, while the planted
+	// style issue was missed.
+	r.NotNil(cr.Score)
+	r.Len(cr.Score.Findings, 2)
+	r.True(cr.Score.Findings[0].Found)
+	r.Len(cr.Score.Findings[0].CommentIDs, 3)
+	r.False(cr.Score.Findings[1].Found)
+	r.Equal(0.5, cr.Score.Recall)
+	r.Equal(1.0, cr.Score.Precision)
+	r.InDelta(2.0/3.0, cr.Score.Score, 0.0001)
+
+	// Candidates can see their own review but not its score.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), cand1Token, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.NotNil(res.Details.Progress[0].Review)
+		r.Nil(res.Details.Progress[0].Review.Score)
+	}
+
+	// FAIL: Candidates cannot use the review endpoint.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/review", a1.ID, ch1.ID, task1.ID), cand1Token, nil, &res)
+
+		r.Contains(res.Error, "could not get review")
+	}
+}
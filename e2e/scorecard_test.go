@@ -0,0 +1,194 @@
+package e2e
+
+import (
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	scorecard_c "github.com/anrid/codecoach/internal/controller/scorecard"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestScorecards ...
+func (su *ts) TestScorecards() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, _, admin1Token := su.signup("Scorecard Inc")
+
+	// POST /users and POST /login (hiring manager and candidate)
+	users := make(map[domain.Role]*domain.User)
+	tokens := make(map[domain.Role]string)
+	for _, role := range []domain.Role{domain.RoleHiringManager, domain.RoleCandidate} {
+		users[role] = su.createUser(a1, admin1Token, user_c.PostUserRequest{
+			GivenName:  "Some",
+			FamilyName: string(role),
+			Role:       role,
+		})
+		tokens[role] = su.login(a1, users[role])
+	}
+	hmToken := tokens[domain.RoleHiringManager]
+
+	// POST /tasks
+	task1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name: "Refactor the billing module",
+		Type: domain.TaskTypeRefactor,
+	})
+
+	// POST /challenges
+	ch1 := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+		ForUserID: users[domain.RoleCandidate].ID,
+		TaskIDs:   []domain.ID{task1.ID},
+	})
+
+	// FAIL: The task has no rubric yet.
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores: []scorecard_c.CriterionScore{{Criterion: "Readability", Score: 3}},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), hmToken, &req, &res)
+
+		r.Contains(res.Error, "could not save scorecard")
+	}
+
+	rubric := task_c.PutRubricRequest{
+		Criteria: []task_c.RubricCriterion{
+			{
+				Name:   "Readability",
+				Weight: 3,
+				Levels: []string{"Unreadable", "Hard to follow", "Okay", "Clear", "A joy to read"},
+			},
+			{
+				Name:   "Testing",
+				Weight: 1,
+			},
+		},
+	}
+
+	// FAIL: Only admins define rubrics.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/tasks/%s/rubric", a1.ID, task1.ID), hmToken, &rubric, &res)
+
+		r.Contains(res.Error, "could not set rubric")
+	}
+
+	// FAIL: Criteria must have unique names.
+	{
+		req := task_c.PutRubricRequest{
+			Criteria: []task_c.RubricCriterion{{Name: "Testing", Weight: 1}, {Name: "Testing", Weight: 2}},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/tasks/%s/rubric", a1.ID, task1.ID), admin1Token, &req, &res)
+
+		r.Contains(res.Error, "could not set rubric")
+	}
+
+	// PUT /tasks/{id}/rubric
+	{
+		res := domain.Task{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/tasks/%s/rubric", a1.ID, task1.ID), admin1Token, &rubric, &res)
+
+		r.NotNil(res.Details.Rubric)
+		r.Len(res.Details.Rubric.Criteria, 2)
+		r.Equal("A joy to read", res.Details.Rubric.Criteria[0].Levels[4])
+	}
+
+	// FAIL: Every criterion must be scored.
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores: []scorecard_c.CriterionScore{{Criterion: "Readability", Score: 3}},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), hmToken, &req, &res)
+
+		r.Contains(res.Error, "could not save scorecard")
+	}
+
+	// FAIL: Candidates can't score.
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores: []scorecard_c.CriterionScore{{Criterion: "Readability", Score: 5}, {Criterion: "Testing", Score: 5}},
+		}
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), tokens[domain.RoleCandidate], &req, &res)
+
+		r.Contains(res.Error, "could not save scorecard")
+	}
+
+	// PUT /challenges/{id}/tasks/{task_id}/scorecard (hiring manager)
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores:  []scorecard_c.CriterionScore{{Criterion: "Readability", Score: 2}, {Criterion: "Testing", Score: 4}},
+			Comment: "First pass",
+		}
+		res := domain.Scorecard{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), hmToken, &req, &res)
+
+		r.Equal(users[domain.RoleHiringManager].ID, res.ReviewerID)
+		r.InDelta(2.5, res.Details.Overall, 0.0001)
+		r.Empty(res.Details.History)
+	}
+
+	// PUT /challenges/{id}/tasks/{task_id}/scorecard (hiring manager edits)
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores:  []scorecard_c.CriterionScore{{Criterion: "Testing", Score: 4}, {Criterion: "Readability", Score: 4}},
+			Comment: "Second look",
+		}
+		res := domain.Scorecard{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), hmToken, &req, &res)
+
+		r.InDelta(4.0, res.Details.Overall, 0.0001)
+		r.Equal("Second look", res.Details.Comment)
+		r.NotNil(res.UpdatedAt)
+		r.Len(res.Details.History, 1)
+		r.Equal("First pass", res.Details.History[0].Comment)
+		r.InDelta(2.5, res.Details.History[0].Overall, 0.0001)
+	}
+
+	// PUT /challenges/{id}/tasks/{task_id}/scorecard (admin)
+	{
+		req := scorecard_c.PutScorecardRequest{
+			Scores: []scorecard_c.CriterionScore{{Criterion: "Readability", Score: 3}, {Criterion: "Testing", Score: 1}},
+		}
+		res := domain.Scorecard{}
+
+		_, _ = httpclient.CallWithToken("PUT", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/scorecard", a1.ID, ch1.ID, task1.ID), admin1Token, &req, &res)
+
+		r.InDelta(2.5, res.Details.Overall, 0.0001)
+	}
+
+	// GET /challenges/{id}/scorecards
+	{
+		res := domain.ChallengeScorecards{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/scorecards", a1.ID, ch1.ID), hmToken, nil, &res)
+
+		r.Len(res.Scorecards, 2)
+		r.Len(res.Tasks, 1)
+		r.Equal(task1.ID, res.Tasks[0].TaskID)
+		r.Equal(2, res.Tasks[0].Reviewers)
+		r.InDelta(3.25, res.Tasks[0].Overall, 0.0001)
+		r.InDelta(3.25, res.Overall, 0.0001)
+	}
+
+	// FAIL: Candidates can't see scorecards.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/scorecards", a1.ID, ch1.ID), tokens[domain.RoleCandidate], nil, &res)
+
+		r.Contains(res.Error, "could not get scorecards")
+	}
+}
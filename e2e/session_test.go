@@ -0,0 +1,150 @@
+package e2e
+
+import (
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessions ...
+func (su *ts) TestSessions() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, _, admin1Token := su.signup("Sessions Inc")
+
+	// POST /users
+	hm1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:  "Hiring",
+		FamilyName: "Manager",
+		Role:       domain.RoleHiringManager,
+	})
+
+	// Log in on three devices. Each login gets its own token and
+	// doesn't log out the others.
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		tokens = append(tokens, su.login(a1, hm1))
+	}
+	r.NotEqual(tokens[0], tokens[1])
+
+	secretURL := su.url("/api/v1/accounts/%s/secret", a1.ID)
+
+	for _, t := range tokens {
+		res := user_c.GetSecretResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, t, nil, &res)
+
+		r.Equal(hm1.ID, res.ID)
+	}
+
+	// GET /sessions
+	var other domain.ID
+	{
+		res := user_c.GetSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/sessions", a1.ID), tokens[0], nil, &res)
+
+		r.Len(res.Sessions, 3)
+
+		var current int
+		for _, s := range res.Sessions {
+			r.Equal(hm1.ID, s.UserID)
+			r.NotEmpty(s.UserAgent)
+			r.NotEmpty(s.IP)
+			r.Empty(s.Token)
+			if s.Current {
+				current++
+			} else {
+				other = s.ID
+			}
+		}
+		r.Equal(1, current)
+	}
+
+	// DELETE /sessions/{id} - revoke one of the other sessions.
+	{
+		res := user_c.DeleteSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/sessions/%s", a1.ID, other), tokens[0], nil, &res)
+
+		r.Equal(1, res.Revoked)
+
+		list := user_c.GetSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/sessions", a1.ID), tokens[0], nil, &list)
+
+		r.Len(list.Sessions, 2)
+	}
+
+	// DELETE /sessions - revoke all other sessions.
+	{
+		res := user_c.DeleteSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/sessions", a1.ID), tokens[0], nil, &res)
+
+		r.Equal(1, res.Revoked)
+
+		for _, t := range tokens[1:] {
+			eres := errorResp{}
+
+			_, _ = httpclient.CallWithToken("GET", secretURL, t, nil, &eres)
+
+			r.Contains(eres.Error, "token invalid")
+		}
+
+		sres := user_c.GetSecretResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, tokens[0], nil, &sres)
+
+		r.Equal(hm1.ID, sres.ID)
+	}
+
+	// DELETE /sessions/current - log out.
+	{
+		res := user_c.DeleteSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/sessions/current", a1.ID), tokens[0], nil, &res)
+
+		r.Equal(1, res.Revoked)
+
+		eres := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", secretURL, tokens[0], nil, &eres)
+
+		r.Contains(eres.Error, "token invalid")
+	}
+
+	// Log in twice more.
+	tokens = nil
+	for i := 0; i < 2; i++ {
+		tokens = append(tokens, su.login(a1, hm1))
+	}
+
+	// FAIL: Only admins can revoke other users' sessions.
+	{
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/users/%s/sessions", a1.ID, hm1.ID), tokens[0], nil, &res)
+
+		r.Contains(res.Error, "could not revoke")
+	}
+
+	// DELETE /users/{id}/sessions - admin revokes all of a user's sessions.
+	{
+		res := user_c.DeleteSessionsResponse{}
+
+		_, _ = httpclient.CallWithToken("DELETE", su.url("/api/v1/accounts/%s/users/%s/sessions", a1.ID, hm1.ID), admin1Token, nil, &res)
+
+		r.Equal(2, res.Revoked)
+
+		for _, t := range tokens {
+			eres := errorResp{}
+
+			_, _ = httpclient.CallWithToken("GET", secretURL, t, nil, &eres)
+
+			r.Contains(eres.Error, "token invalid")
+		}
+	}
+}
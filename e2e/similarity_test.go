@@ -0,0 +1,205 @@
+package e2e
+
+import (
+	"fmt"
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+const starterSort = `package sorting
+
+// Sort sorts xs in place.
+func Sort(xs []int) {
+	// TODO: Implement.
+}
+`
+
+const solutionSort = `package sorting
+
+// Sort sorts xs in place.
+func Sort(xs []int) {
+	for i := 1; i < len(xs); i++ {
+		for j := i; j > 0 && xs[j] < xs[j-1]; j-- {
+			xs[j], xs[j-1] = xs[j-1], xs[j]
+		}
+	}
+}
+
+func reverse(xs []int) {
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+}
+`
+
+// copiedSort is solutionSort with renamed variables and reformatted.
+const copiedSort = `package sorting
+
+// Sort sorts xs in place.
+func Sort(items []int) {
+	for a := 1; a < len(items); a++ {
+		for b := a; b > 0 && items[b] < items[b-1]; b-- { items[b], items[b-1] = items[b-1], items[b] }
+	}
+}
+
+func flip(items []int) {
+	for a, b := 0, len(items)-1; a < b; a, b = a+1, b-1 { items[a], items[b] = items[b], items[a] }
+}
+`
+
+const otherSort = `package sorting
+
+import "sort"
+
+type ints []int
+
+func (s ints) Len() int           { return len(s) }
+func (s ints) Less(i, j int) bool { return s[i] < s[j] }
+func (s ints) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Sort sorts xs in place.
+func Sort(xs []int) {
+	sort.Sort(ints(xs))
+}
+`
+
+// TestSimilarity ...
+func (su *ts) TestSimilarity() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-sorting-1a2b3c4", map[string][]byte{
+			"sort.go": []byte(starterSort),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/sorting"] = b
+	}
+
+	// Signup
+	a1, _, admin1Token := su.signup("Copycat Inc")
+
+	// POST /tasks
+	task1 := su.createTask(a1, admin1Token, task_c.PostTaskRequest{
+		Name:           "Sort some numbers",
+		Type:           domain.TaskTypeCoding,
+		GithubRepoName: "acme/sorting",
+	})
+
+	// POST /users, POST /challenges and POST /login (three candidates)
+	var cands []*domain.User
+	var chs []*domain.Challenge
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		cand := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+			GivenName:   "Cand",
+			FamilyName:  fmt.Sprintf("Idate %d", i),
+			Role:        domain.RoleCandidate,
+			GithubLogin: fmt.Sprintf("cand-idate-%d", i),
+		})
+		ch := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+			ForUserID: cand.ID,
+			TaskIDs:   []domain.ID{task1.ID},
+		})
+
+		cands = append(cands, cand)
+		chs = append(chs, ch)
+		tokens = append(tokens, su.login(a1, cand))
+	}
+
+	p := su.newPool()
+
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	guc := grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, &fakeSandbox{})
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeCheckSimilarity, func() domain.JobPayload { return new(domain.CheckSimilarityPayload) }, guc.CheckSimilarity)
+	p.Start()
+	defer p.Stop()
+
+	// getChallenge gets a challenge as the admin.
+	getChallenge := func(ch *domain.Challenge) *domain.Challenge {
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch.ID), admin1Token, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+
+		return &res
+	}
+
+	// The first candidate solves the task, the second copies the first
+	// candidate's solution and the third solves it on their own. Each
+	// submission is checked against the ones before it.
+	for i, solution := range []string{solutionSort, copiedSort, otherSort} {
+		// POST /challenges/{id}/start (candidate)
+		su.startChallenge(a1, chs[i], tokens[i])
+
+		// GET /challenges/{id}/progress (candidate) until the repo is ready.
+		repo := su.waitForRepo(a1, chs[i], tokens[i])
+
+		gh.mux.Lock()
+		gh.repos[repo.Name]["sort.go"] = []byte(solution)
+		gh.mux.Unlock()
+
+		// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+		{
+			res := domain.Challenge{}
+
+			_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", a1.ID, chs[i].ID, task1.ID), tokens[i], nil, &res)
+
+			r.Equal(domain.StatusCompleted, res.Status)
+			r.Nil(res.Details.Progress[0].Similarity)
+		}
+
+		// GET /challenges/{id} (admin) until the submission has been checked.
+		r.Eventually(func() bool {
+			return getChallenge(chs[i]).Details.Progress[0].Similarity != nil
+		}, 5*time.Second, 100*time.Millisecond)
+	}
+
+	// The copied solution matches the first one, despite the renames.
+	s2 := getChallenge(chs[1]).Details.Progress[0].Similarity
+	r.Len(s2.Matches, 1)
+	r.Equal(chs[0].ID, s2.Matches[0].ChallengeID)
+	r.Equal(cands[0].ID, s2.Matches[0].UserID)
+	r.Greater(s2.Matches[0].Score, 0.9)
+	r.NotEmpty(s2.Matches[0].Regions)
+	r.Equal("sort.go", s2.Matches[0].Regions[0].File)
+	r.Equal("sort.go", s2.Matches[0].Regions[0].OtherFile)
+
+	// The match is flagged on the first challenge too.
+	s1 := getChallenge(chs[0]).Details.Progress[0].Similarity
+	r.Len(s1.Matches, 1)
+	r.Equal(chs[1].ID, s1.Matches[0].ChallengeID)
+	r.Equal(cands[1].ID, s1.Matches[0].UserID)
+	r.Equal(s2.Matches[0].Score, s1.Matches[0].Score)
+
+	// The independent solution doesn't match anything, even though all
+	// candidates were given the same starter code.
+	s3 := getChallenge(chs[2]).Details.Progress[0].Similarity
+	r.Empty(s3.Matches)
+
+	// Candidates never see similarity reports.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, chs[1].ID), tokens[1], nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.Nil(res.Details.Progress[0].Similarity)
+	}
+}
@@ -0,0 +1,158 @@
+package e2e
+
+import (
+	"time"
+
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/github"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	grading_uc "github.com/anrid/codecoach/internal/usecase/grading"
+	identity_uc "github.com/anrid/codecoach/internal/usecase/identity"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// commit returns a fake Github commit.
+func commit(sha, message, login string, at time.Time) github.Commit {
+	c := github.Commit{SHA: sha}
+	c.Commit.Message = message
+	c.Commit.Author.Name = login
+	c.Commit.Committer.Date = at.UTC().Format(time.RFC3339)
+	return c
+}
+
+// push returns a fake Github push event for commits, the last of which
+// is the new head.
+func push(at time.Time, shas ...string) github.Event {
+	e := github.Event{Type: github.EventTypePush, CreatedAt: at.UTC().Format(time.RFC3339)}
+	e.Payload.Ref = "refs/heads/main"
+	e.Payload.Head = shas[len(shas)-1]
+	for _, sha := range shas {
+		e.Payload.Commits = append(e.Payload.Commits, struct {
+			SHA string `json:"sha"`
+		}{sha})
+	}
+	return e
+}
+
+// TestCommitTimeline ...
+func (su *ts) TestCommitTimeline() {
+	r := require.New(su.T())
+
+	gh := newFakeGithub()
+	{
+		b, err := zipball("acme-starter-1a2b3c4", map[string][]byte{
+			"main.go": []byte("package acme\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/starter"] = b
+	}
+
+	// Signup, POST /users, POST /tasks, POST /challenges (due in three
+	// hours) and POST /login
+	expiresAt := time.Now().Add(3 * time.Hour)
+	f := su.setupChallenge("Timeline Inc", task_c.PostTaskRequest{
+		Name:           "Take your time",
+		Type:           domain.TaskTypeCoding,
+		GithubRepoName: "acme/starter",
+	}, &expiresAt)
+
+	p := su.newPool()
+
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(su.cfg, su.c, su.u, job_uc.New(su.cfg, su.j), gh, store)
+	guc := grading_uc.New(su.cfg, su.c, su.f, identity_uc.New(su.a, su.u, su.au), gh, store, &fakeSandbox{})
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeAnalyzeTimeline, func() domain.JobPayload { return new(domain.AnalyzeTimelinePayload) }, guc.AnalyzeTimeline)
+	p.Start()
+	defer p.Stop()
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(f.Account, f.Challenge, f.CandidateToken)
+
+	// GET /challenges/{id}/progress (candidate) until the repo is ready.
+	repo := su.waitForRepo(f.Account, f.Challenge, f.CandidateToken)
+
+	// The candidate works in three sessions and pushes a last fix after
+	// the deadline, with its commit date set back to before the
+	// deadline. Github lists commits and events newest first, and the
+	// starter code was pushed before the repo was handed over.
+	start := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	gh.mux.Lock()
+	gh.commits[repo.Name] = []github.Commit{
+		commit("e5", "Fix typo", "cand-idate", start.Add(2*time.Hour+30*time.Minute)),
+		commit("d4", "Add tests\n\nAll of them.", "cand-idate", start.Add(2*time.Hour)),
+		commit("c3", "Finish parser", "cand-idate", start.Add(10*time.Minute)),
+		commit("b2", "Start parser", "cand-idate", start),
+		commit("a1", "Add starter code", "codecoach", repo.ProvisionedAt.Add(-time.Second)),
+	}
+	gh.events[repo.Name] = []github.Event{
+		push(start.Add(4*time.Hour), "e5"),
+		push(start.Add(2*time.Hour), "d4"),
+		push(start.Add(10*time.Minute), "c3"),
+		push(start, "b2"),
+		push(repo.ProvisionedAt.Add(-time.Second), "a1"),
+	}
+	gh.mux.Unlock()
+
+	// POST /challenges/{id}/tasks/{task_id}/submit (candidate)
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("POST", su.url("/api/v1/accounts/%s/challenges/%s/tasks/%s/submit", f.Account.ID, f.Challenge.ID, f.Task.ID), f.CandidateToken, nil, &res)
+
+		r.Equal(domain.StatusCompleted, res.Status)
+	}
+
+	// GET /challenges/{id} (admin) until the timeline has been built.
+	var tl *domain.CommitTimeline
+	r.Eventually(func() bool {
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.AdminToken, nil, &res)
+
+		if len(res.Details.Progress) != 1 {
+			return false
+		}
+		tl = res.Details.Progress[0].Timeline
+		return tl != nil
+	}, 5*time.Second, 100*time.Millisecond)
+
+	r.Len(tl.Commits, 4)
+	r.Equal("b2", tl.Commits[0].SHA)
+	r.Equal("Start parser", tl.Commits[0].Message)
+	r.Equal("cand-idate", tl.Commits[0].Author)
+	r.Equal("Add tests", tl.Commits[2].Message)
+	r.Equal("e5", tl.Commits[3].SHA)
+
+	r.NotNil(tl.DeadlineAt)
+	r.WithinDuration(expiresAt, *tl.DeadlineAt, time.Second)
+	r.Equal(1, tl.LateCommits)
+	r.False(tl.Commits[2].Late)
+	r.True(tl.Commits[3].Late)
+	r.True(tl.Commits[3].CommittedAt.Before(*tl.DeadlineAt))
+	r.NotNil(tl.Commits[3].PushedAt)
+	r.True(tl.Commits[3].PushedAt.Equal(start.Add(4 * time.Hour)))
+
+	r.Len(tl.Sessions, 3)
+	r.Equal(2, tl.Sessions[0].Commits)
+	r.True(tl.Sessions[0].StartAt.Equal(start.Add(-domain.SessionLead)))
+	r.True(tl.Sessions[0].EndAt.Equal(start.Add(10 * time.Minute)))
+	r.Equal(10*time.Minute+3*domain.SessionLead, tl.ActiveTime)
+
+	// Candidates never see the timeline.
+	{
+		res := domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", f.Account.ID, f.Challenge.ID), f.CandidateToken, nil, &res)
+
+		r.Len(res.Details.Progress, 1)
+		r.Nil(res.Details.Progress[0].Timeline)
+	}
+}
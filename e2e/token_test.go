@@ -0,0 +1,48 @@
+package e2e
+
+import (
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/token"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTokensHashedAtRest ...
+func (su *ts) TestTokensHashedAtRest() {
+	r := require.New(su.T())
+
+	// Signup
+	a1, admin1, admin1Token := su.signup("Hashed Inc")
+
+	// Only the token's hash is stored.
+	{
+		var hashes []string
+		err := su.db.Select(&hashes, "SELECT token_hash FROM sessions WHERE account_id = $1 AND user_id = $2", a1.ID, admin1.ID)
+		r.NoError(err)
+
+		r.Len(hashes, 1)
+		r.Equal(token.Hash(su.cfg.TokenSecret, admin1Token), hashes[0])
+		r.NotContains(hashes[0], admin1Token)
+	}
+
+	// The token works.
+	{
+		res := user_c.GetSecretResponse{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/secret", a1.ID), admin1Token, nil, &res)
+
+		r.Equal(admin1.ID, res.ID)
+	}
+
+	// FAIL: A hash isn't a token, and invalid tokens aren't echoed
+	// back.
+	{
+		bad := token.Hash(su.cfg.TokenSecret, admin1Token)
+		res := errorResp{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/secret", a1.ID), bad, nil, &res)
+
+		r.Contains(res.Error, "token invalid")
+		r.NotContains(res.Error, bad)
+	}
+}
@@ -0,0 +1,500 @@
+package e2e
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"sync"
+	"time"
+
+	challenge_c "github.com/anrid/codecoach/internal/controller/challenge"
+	task_c "github.com/anrid/codecoach/internal/controller/task"
+	user_c "github.com/anrid/codecoach/internal/controller/user"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/github"
+	"github.com/anrid/codecoach/internal/pkg/httpclient"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	job_uc "github.com/anrid/codecoach/internal/usecase/job"
+	workspace_uc "github.com/anrid/codecoach/internal/usecase/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGithub is an in-memory stand-in for the Github API.
+type fakeGithub struct {
+	mux           sync.Mutex
+	repos         map[string]map[string][]byte
+	collaborators map[string][]string
+	zipballs      map[string][]byte
+	branches      map[string]map[string][]byte
+	pulls         map[string]*github.PullRequest
+	reviews       map[string][]github.Review
+	comments      map[string][]github.ReviewComment
+	commits       map[string][]github.Commit
+	events        map[string][]github.Event
+	failInvites   int
+}
+
+var _ workspace_uc.GithubAPI = &fakeGithub{}
+
+func newFakeGithub() *fakeGithub {
+	return &fakeGithub{
+		repos:         make(map[string]map[string][]byte),
+		collaborators: make(map[string][]string),
+		zipballs:      make(map[string][]byte),
+		branches:      make(map[string]map[string][]byte),
+		pulls:         make(map[string]*github.PullRequest),
+		reviews:       make(map[string][]github.Review),
+		comments:      make(map[string][]github.ReviewComment),
+		commits:       make(map[string][]github.Commit),
+		events:        make(map[string][]github.Event),
+	}
+}
+
+func (g *fakeGithub) CurrentUser() (*github.User, error) {
+	return &github.User{Login: "codecoach-bot"}, nil
+}
+
+func (g *fakeGithub) NewRepo(candidate, name, desc string) (*github.Repo, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	r := new(github.Repo)
+	r.Name = fmt.Sprintf("%s-candidate-%s-%d", name, candidate, len(g.repos))
+	r.Owner.Login = "codecoach-bot"
+	r.HTMLURL = "https://github.com/codecoach-bot/" + r.Name
+
+	g.repos[r.Name] = make(map[string][]byte)
+
+	return r, nil
+}
+
+func (g *fakeGithub) DeleteRepo(username, repo string) (*github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	delete(g.repos, repo)
+
+	return &github.Response{}, nil
+}
+
+func (g *fakeGithub) DownloadRepo(username, repo, branch string) (*github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if files, found := g.repos[repo]; found {
+		b, err := zipball(repo+"-"+branch, files)
+		if err != nil {
+			return nil, err
+		}
+		return &github.Response{Body: b}, nil
+	}
+
+	// Zipballs of branches other than the default branch are keyed
+	// by `owner/repo@branch`.
+	key := username + "/" + repo
+	if branch != "" {
+		key += "@" + branch
+	}
+
+	b, found := g.zipballs[key]
+	if !found {
+		return nil, &github.APIError{StatusCode: 404}
+	}
+
+	return &github.Response{Body: b}, nil
+}
+
+func (g *fakeGithub) HeadCommit(owner, repo string) (string, string, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	files, found := g.repos[repo]
+	if !found {
+		// Source repos can be downloaded at their head commit, even
+		// after their default branch has moved on.
+		b, found := g.zipballs[owner+"/"+repo]
+		if !found {
+			return "", "", &github.APIError{StatusCode: 404}
+		}
+		sha := fmt.Sprintf("%x", sha1.Sum(b))
+		g.zipballs[owner+"/"+repo+"@"+sha] = b
+		return "main", sha, nil
+	}
+	if len(files) == 0 {
+		return "main", "", nil
+	}
+
+	return "main", fmt.Sprintf("%040d", len(files)), nil
+}
+
+func (g *fakeGithub) PutFile(owner, repo, filePath string, content []byte, message string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	files, found := g.repos[repo]
+	if !found {
+		return &github.APIError{StatusCode: 404}
+	}
+	if _, exists := files[filePath]; exists {
+		return &github.APIError{StatusCode: 422}
+	}
+	files[filePath] = content
+
+	return nil
+}
+
+func (g *fakeGithub) AddCollaborator(owner, repo, candidate string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if g.failInvites > 0 {
+		g.failInvites--
+		return &github.APIError{StatusCode: 502}
+	}
+
+	g.collaborators[repo] = append(g.collaborators[repo], candidate)
+
+	return nil
+}
+
+func (g *fakeGithub) RemoveCollaborator(owner, repo, candidate string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	var cs []string
+	for _, c := range g.collaborators[repo] {
+		if c != candidate {
+			cs = append(cs, c)
+		}
+	}
+	g.collaborators[repo] = cs
+
+	return nil
+}
+
+func (g *fakeGithub) CreateBranch(owner, repo, branch, sha string) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	files, found := g.repos[repo]
+	if !found {
+		return &github.APIError{StatusCode: 404}
+	}
+
+	b := make(map[string][]byte)
+	for name, content := range files {
+		b[name] = content
+	}
+	g.branches[repo+"@"+branch] = b
+
+	return nil
+}
+
+func (g *fakeGithub) CommitFiles(owner, repo, branch string, files []github.File, message string) (string, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	b, found := g.branches[repo+"@"+branch]
+	if !found {
+		return "", &github.APIError{StatusCode: 404}
+	}
+
+	for _, f := range files {
+		if f.Content == nil {
+			delete(b, f.Path)
+		} else {
+			b[f.Path] = f.Content
+		}
+	}
+
+	return fmt.Sprintf("%040d", len(b)), nil
+}
+
+func (g *fakeGithub) CreatePull(owner, repo, title, body, head, base string) (*github.PullRequest, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if _, found := g.branches[repo+"@"+head]; !found {
+		return nil, &github.APIError{StatusCode: 422}
+	}
+
+	pr := &github.PullRequest{
+		Number:  1,
+		Title:   title,
+		State:   "open",
+		HTMLURL: "https://github.com/" + owner + "/" + repo + "/pull/1",
+	}
+	g.pulls[repo] = pr
+
+	return pr, nil
+}
+
+func (g *fakeGithub) ListReviews(username, repo string, pullNumber int, nextURL string) ([]github.Review, *github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	var rs []github.Review
+	res := page(len(g.reviews[repo]), nextURL, func(i int) { rs = append(rs, g.reviews[repo][i]) })
+
+	return rs, res, nil
+}
+
+func (g *fakeGithub) ListReviewComments(username, repo string, pullNumber int, nextURL string) ([]github.ReviewComment, *github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	var cs []github.ReviewComment
+	res := page(len(g.comments[repo]), nextURL, func(i int) { cs = append(cs, g.comments[repo][i]) })
+
+	return cs, res, nil
+}
+
+func (g *fakeGithub) ListCommits(owner, repo string, nextURL string) ([]github.Commit, *github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	var cs []github.Commit
+	res := page(len(g.commits[repo]), nextURL, func(i int) { cs = append(cs, g.commits[repo][i]) })
+
+	return cs, res, nil
+}
+
+func (g *fakeGithub) ListEvents(owner, repo string, nextURL string) ([]github.Event, *github.Response, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	var es []github.Event
+	res := page(len(g.events[repo]), nextURL, func(i int) { es = append(es, g.events[repo][i]) })
+
+	return es, res, nil
+}
+
+func (g *fakeGithub) Repo(owner, repo string) (*github.Repo, error) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	if _, found := g.repos[repo]; !found {
+		return nil, &github.APIError{StatusCode: 404}
+	}
+
+	r := new(github.Repo)
+	r.Name = repo
+	r.Owner.Login = owner
+	// Events are kept newest first.
+	if es := g.events[repo]; len(es) > 0 {
+		r.PushedAt = es[0].CreatedAt
+	}
+
+	return r, nil
+}
+
+// page calls add for each item on the page of n items given by nextURL,
+// two items per page, and returns a response linking to the next page.
+func page(n int, nextURL string, add func(i int)) *github.Response {
+	start := 0
+	if nextURL != "" {
+		fmt.Sscanf(nextURL, "page=%d", &start)
+	}
+
+	res := &github.Response{}
+	for i := start; i < n && i < start+2; i++ {
+		add(i)
+	}
+	if start+2 < n {
+		res.Next = fmt.Sprintf("page=%d", start+2)
+	}
+
+	return res
+}
+
+// zipball creates a zipball like the ones Github serves, with all
+// files in a top-level directory.
+func zipball(dir string, files map[string][]byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for name, content := range files {
+		w, err := zw.Create(dir + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// TestWorkspaces ...
+func (su *ts) TestWorkspaces() {
+	r := require.New(su.T())
+
+	// Create a starter repo zipball.
+	gh := newFakeGithub()
+	gh.failInvites = 1
+	{
+		b, err := zipball("acme-starter-1a2b3c4", map[string][]byte{
+			"main.go":     []byte("package main\n"),
+			"pkg/util.go": []byte("package pkg\n"),
+		})
+		r.NoError(err)
+
+		gh.zipballs["acme/starter"] = b
+	}
+
+	// Signup
+	a1, _, admin1Token := su.signup("Workspace Inc")
+
+	// POST /users (candidate with Github login)
+	cand1 := su.createUser(a1, admin1Token, user_c.PostUserRequest{
+		GivenName:   "Cand",
+		FamilyName:  "Idate",
+		Role:        domain.RoleCandidate,
+		GithubLogin: "cand-idate",
+	})
+	r.Equal("cand-idate", cand1.Profile.GithubLogin)
+
+	// POST /tasks (with and without starter code)
+	var taskIDs []domain.ID
+	for _, req := range []task_c.PostTaskRequest{
+		{Name: "Refactor the starter", Type: domain.TaskTypeRefactor, GithubRepoName: "acme/starter"},
+		{Name: "Write something new", Type: domain.TaskTypeCoding},
+	} {
+		taskIDs = append(taskIDs, su.createTask(a1, admin1Token, req).ID)
+	}
+
+	// POST /challenges (repos are archived when the challenge expires)
+	expiresAt := time.Now().Add(4 * time.Second)
+	ch1 := su.createChallenge(a1, admin1Token, challenge_c.PostChallengeRequest{
+		ForUserID: cand1.ID,
+		TaskIDs:   taskIDs,
+		ExpiresAt: &expiresAt,
+	})
+
+	// POST /login (candidate)
+	cand1Token := su.login(a1, cand1)
+
+	p := su.newPool()
+	// Delete archived repos right away.
+	c := *su.cfg
+	c.RepoRetention = 100 * time.Millisecond
+
+	store := storage.NewLocal(su.T().TempDir())
+
+	wuc := workspace_uc.New(&c, su.c, su.u, job_uc.New(&c, su.j), gh, store)
+	p.Register(domain.JobTypeProvisionRepo, func() domain.JobPayload { return new(domain.ProvisionRepoPayload) }, wuc.ProvisionRepo)
+	p.Register(domain.JobTypeArchiveRepo, func() domain.JobPayload { return new(domain.ArchiveRepoPayload) }, wuc.ArchiveRepo)
+	p.Register(domain.JobTypeDeleteRepo, func() domain.JobPayload { return new(domain.DeleteRepoPayload) }, wuc.DeleteRepo)
+	p.Start()
+	defer p.Stop()
+
+	// POST /challenges/{id}/start (candidate)
+	su.startChallenge(a1, ch1, cand1Token)
+
+	// GET /challenges/{id}/progress (candidate) until all repos are ready.
+	var progress domain.ChallengeProgress
+	r.Eventually(func() bool {
+		progress = domain.ChallengeProgress{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s/progress", a1.ID, ch1.ID), cand1Token, nil, &progress)
+
+		if len(progress.Tasks) != 2 {
+			return false
+		}
+		for _, t := range progress.Tasks {
+			if t.Repo == nil || t.Repo.Status != domain.RepoStatusReady {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+
+	for i, t := range progress.Tasks {
+		r.Equal(taskIDs[i], t.TaskID)
+		r.Equal("codecoach-bot", t.Repo.Owner)
+		r.Contains(t.Repo.URL, t.Repo.Name)
+		r.NotContains(t.Repo.Name, "cand-idate")
+		r.Empty(t.Repo.Error)
+		r.Nil(t.Repo.FailedAt)
+		r.NotNil(t.Repo.ProvisionedAt)
+	}
+
+	repos := []string{progress.Tasks[0].Repo.Name, progress.Tasks[1].Repo.Name}
+
+	gh.mux.Lock()
+
+	// Starter code is uploaded without the zipball's top-level directory.
+	starter := gh.repos[repos[0]]
+	r.Len(starter, 2)
+	r.Equal("package main\n", string(starter["main.go"]))
+	r.Equal("package pkg\n", string(starter["pkg/util.go"]))
+	r.Len(gh.repos[repos[1]], 0)
+
+	// The candidate is invited to each repo exactly once, even though
+	// the first invite failed.
+	r.Equal(0, gh.failInvites)
+	for _, name := range repos {
+		r.Equal([]string{"cand-idate"}, gh.collaborators[name])
+	}
+
+	// The candidate pushes some work.
+	starter["main.go"] = []byte("package main\n\nfunc main() {}\n")
+	starter["README.md"] = []byte("# Done\n")
+
+	gh.mux.Unlock()
+
+	// Repos are archived at the deadline and deleted after the
+	// retention period.
+	var ch domain.Challenge
+	r.Eventually(func() bool {
+		ch = domain.Challenge{}
+
+		_, _ = httpclient.CallWithToken("GET", su.url("/api/v1/accounts/%s/challenges/%s", a1.ID, ch1.ID), admin1Token, nil, &ch)
+
+		if len(ch.Details.Progress) != 2 {
+			return false
+		}
+		for _, tp := range ch.Details.Progress {
+			if tp.Repo == nil || tp.Repo.Status != domain.RepoStatusDeleted {
+				return false
+			}
+		}
+		return true
+	}, 10*time.Second, 100*time.Millisecond)
+
+	gh.mux.Lock()
+	defer gh.mux.Unlock()
+
+	for _, name := range repos {
+		r.Empty(gh.collaborators[name])
+		r.NotContains(gh.repos, name)
+	}
+
+	// The final state of the repo with work in it is saved.
+	tp := ch.Details.Progress[0]
+	r.NotNil(tp.Repo.ArchivedAt)
+	r.NotNil(tp.Repo.DeletedAt)
+	r.Equal(fmt.Sprintf("%040d", 3), tp.Repo.HeadCommit)
+	r.NotEmpty(tp.Repo.SnapshotKey)
+
+	b, err := store.Get(tp.Repo.SnapshotKey)
+	r.NoError(err)
+
+	files, err := github.ExtractZipball(b)
+	r.NoError(err)
+	r.Len(files, 3)
+
+	// Empty repos have no snapshot.
+	tp = ch.Details.Progress[1]
+	r.NotNil(tp.Repo.ArchivedAt)
+	r.Empty(tp.Repo.HeadCommit)
+	r.Empty(tp.Repo.SnapshotKey)
+}
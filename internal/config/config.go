@@ -4,8 +4,11 @@ import (
 	"os"
 	"path"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/anrid/codecoach/internal/domain"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
@@ -22,7 +25,50 @@ type Config struct {
 	GithubClientSecret string
 	GithubRedirectURI  string
 	GithubAccessToken  string
-	TokenExpires       time.Duration
+	// TokenExpires is how long access tokens are valid. Clients use a
+	// refresh token to get a new one.
+	TokenExpires time.Duration
+	// TokenSecret is the key access and refresh tokens are hashed
+	// with before they're stored.
+	TokenSecret string
+	// SessionIdleTimeout ends sessions that haven't been used for this
+	// long, and SessionAbsoluteTimeout ends sessions this long after
+	// login, however much they're used. Zero means no limit.
+	SessionIdleTimeout     time.Duration
+	SessionAbsoluteTimeout time.Duration
+	WorkerConcurrency      int
+	WorkerPollInterval     time.Duration
+	JobLockFor             time.Duration
+	JobMaxAttempts         int
+	JobBackoffBase         time.Duration
+	JobBackoffMax          time.Duration
+	StorageDir             string
+	RepoRetention          time.Duration
+	SweepInterval          time.Duration
+	SandboxTimeout         time.Duration
+	SandboxCPUTime         time.Duration
+	SandboxMemoryMB        int
+	SandboxProcesses       int
+	// SandboxToolchain holds dirs, besides the system dirs, that tests
+	// can read, e.g. where Go is installed.
+	SandboxToolchain []string
+	// SimilarityThreshold is the similarity score above which two
+	// candidates' code in the same task is flagged.
+	SimilarityThreshold float64
+	// PasswordResetURL is the page password reset links point to, and
+	// PasswordResetExpires how long the links work.
+	PasswordResetURL     string
+	PasswordResetExpires time.Duration
+	// SMTPHost is the mail server emails are sent through. Emails are
+	// only logged if it's not set.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	MailFrom     string
+	// PasswordResetRateLimit is how many password resets can be
+	// requested per account and email per hour.
+	PasswordResetRateLimit int
 }
 
 // New ...
@@ -54,17 +100,43 @@ func New() *Config {
 	}
 
 	return &Config{
-		Host:               mustEnv("HOST"),
-		DBHost:             mustEnv("DB_HOST"),
-		DBPort:             mustEnv("DB_PORT"),
-		DBUser:             mustEnv("DB_USER"),
-		DBPass:             mustEnv("DB_PASS"),
-		DBName:             mustEnv("DB_NAME"),
-		GithubClientID:     mustEnv("GITHUB_CLIENT_ID"),
-		GithubClientSecret: mustEnv("GITHUB_CLIENT_SECRET"),
-		GithubRedirectURI:  mustEnv("GITHUB_REDIRECT_URI"),
-		GithubAccessToken:  mustEnv("GITHUB_ACCESS_TOKEN"),
-		TokenExpires:       24 * 7 * time.Hour,
+		Host:                   mustEnv("HOST"),
+		DBHost:                 mustEnv("DB_HOST"),
+		DBPort:                 mustEnv("DB_PORT"),
+		DBUser:                 mustEnv("DB_USER"),
+		DBPass:                 mustEnv("DB_PASS"),
+		DBName:                 mustEnv("DB_NAME"),
+		GithubClientID:         mustEnv("GITHUB_CLIENT_ID"),
+		GithubClientSecret:     mustEnv("GITHUB_CLIENT_SECRET"),
+		GithubRedirectURI:      mustEnv("GITHUB_REDIRECT_URI"),
+		GithubAccessToken:      mustEnv("GITHUB_ACCESS_TOKEN"),
+		TokenExpires:           durationEnv("TOKEN_EXPIRES", 15*time.Minute),
+		TokenSecret:            mustEnv("TOKEN_SECRET"),
+		SessionIdleTimeout:     durationEnv("SESSION_IDLE_TIMEOUT", 24*7*time.Hour),
+		SessionAbsoluteTimeout: durationEnv("SESSION_ABSOLUTE_TIMEOUT", 24*30*time.Hour),
+		WorkerConcurrency:      intEnv("WORKER_CONCURRENCY", 4),
+		WorkerPollInterval:     durationEnv("WORKER_POLL_INTERVAL", 1*time.Second),
+		JobLockFor:             durationEnv("JOB_LOCK_FOR", 5*time.Minute),
+		JobMaxAttempts:         intEnv("JOB_MAX_ATTEMPTS", domain.DefaultJobMaxAttempts),
+		JobBackoffBase:         durationEnv("JOB_BACKOFF_BASE", 10*time.Second),
+		JobBackoffMax:          durationEnv("JOB_BACKOFF_MAX", 1*time.Hour),
+		StorageDir:             stringEnv("STORAGE_DIR", "data"),
+		RepoRetention:          durationEnv("REPO_RETENTION", 0),
+		SweepInterval:          durationEnv("SWEEP_INTERVAL", 1*time.Minute),
+		SandboxTimeout:         durationEnv("SANDBOX_TIMEOUT", 3*time.Minute),
+		SandboxCPUTime:         durationEnv("SANDBOX_CPU_TIME", 2*time.Minute),
+		SandboxMemoryMB:        intEnv("SANDBOX_MEMORY_MB", 2048),
+		SandboxProcesses:       intEnv("SANDBOX_PROCESSES", 1024),
+		SandboxToolchain:       listEnv("SANDBOX_TOOLCHAIN", "/usr/local/go"),
+		SimilarityThreshold:    floatEnv("SIMILARITY_THRESHOLD", 0.5),
+		PasswordResetURL:       stringEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		PasswordResetExpires:   durationEnv("PASSWORD_RESET_EXPIRES", 1*time.Hour),
+		SMTPHost:               stringEnv("SMTP_HOST", ""),
+		SMTPPort:               intEnv("SMTP_PORT", 587),
+		SMTPUsername:           stringEnv("SMTP_USERNAME", ""),
+		SMTPPassword:           stringEnv("SMTP_PASSWORD", ""),
+		MailFrom:               stringEnv("MAIL_FROM", "CodeCoach <no-reply@codecoach.us>"),
+		PasswordResetRateLimit: intEnv("PASSWORD_RESET_RATE_LIMIT", 3),
 	}
 }
 
@@ -75,3 +147,57 @@ func mustEnv(env string) string {
 	}
 	return v
 }
+
+func stringEnv(env string, def string) string {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func intEnv(env string, def int) int {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		panic("invalid int env " + env + ": " + v)
+	}
+	return i
+}
+
+func floatEnv(env string, def float64) float64 {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		panic("invalid float env " + env + ": " + v)
+	}
+	return f
+}
+
+func listEnv(env string, def string) []string {
+	var l []string
+	for _, v := range strings.Split(stringEnv(env, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func durationEnv(env string, def time.Duration) time.Duration {
+	v := os.Getenv(env)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic("invalid duration env " + env + ": " + v)
+	}
+	return d
+}
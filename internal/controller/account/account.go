@@ -21,6 +21,7 @@ func New(a domain.AccountUseCases) *Controller {
 // SetupRoutes ...
 func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
 	s.Private.PATCH("/api/v1/accounts/:account_id", co.PatchAccount)
+	s.Private.GET("/api/v1/accounts/:account_id/audit-log", co.GetAuditLog)
 }
 
 // PatchAccount ...
@@ -52,6 +53,30 @@ func (co *Controller) PatchAccount(c echo.Context) (err error) {
 
 // PatchAccountRequest ...
 type PatchAccountRequest struct {
-	Name string `json:"name" validate:"omitempty,gte=2"`
-	Logo string `json:"logo" validate:"omitempty,gte=1"`
+	Name        string `json:"name" validate:"omitempty,gte=2"`
+	Logo        string `json:"logo" validate:"omitempty,gte=1"`
+	BlindReview *bool  `json:"blind_review"`
+}
+
+// GetAuditLog ...
+// @Summary Get an account's audit log.
+// @Description Get an account's audit log, newest first, e.g. when blind review mode was changed and which candidates' identities were revealed. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} account.GetAuditLogResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/audit-log [get]
+func (co *Controller) GetAuditLog(c echo.Context) error {
+	es, err := co.a.AuditLog(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not get audit log")
+	}
+
+	return c.JSON(http.StatusOK, GetAuditLogResponse{Entries: es})
+}
+
+// GetAuditLogResponse ...
+type GetAuditLogResponse struct {
+	Entries []*domain.AuditEntry `json:"entries"`
 }
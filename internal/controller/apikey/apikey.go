@@ -0,0 +1,118 @@
+package apikey
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+)
+
+// Controller ...
+type Controller struct {
+	k domain.APIKeyUseCases
+}
+
+// New ...
+func New(k domain.APIKeyUseCases) *Controller {
+	return &Controller{k}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Private.POST("/api/v1/accounts/:account_id/api-keys", co.PostAPIKey)
+	s.Private.GET("/api/v1/accounts/:account_id/api-keys", co.GetList)
+	s.Private.DELETE("/api/v1/accounts/:account_id/api-keys/:id", co.DeleteAPIKey)
+}
+
+// PostAPIKey ...
+// @Summary Create an API key.
+// @Description Create an API key for scripts, limited to the given scopes. The key is only returned once. Admins only.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param opts body apikey.PostAPIKeyRequest true "Post API Key Request"
+// @Success 200 {object} apikey.PostAPIKeyResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/api-keys [post]
+func (co *Controller) PostAPIKey(c echo.Context) (err error) {
+	r := new(PostAPIKeyRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	k, err := co.k.Create(c.Request().Context(), domain.CreateAPIKeyArgs(*r))
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not create api key")
+	}
+
+	return c.JSON(http.StatusOK, PostAPIKeyResponse{
+		APIKey: k,
+		Key:    k.Key,
+	})
+}
+
+// PostAPIKeyRequest ...
+type PostAPIKeyRequest struct {
+	Name      string        `json:"name" validate:"required,gte=1,lte=255"`
+	Scopes    domain.Scopes `json:"scopes" validate:"required,gte=1"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+}
+
+// PostAPIKeyResponse ...
+type PostAPIKeyResponse struct {
+	APIKey *domain.APIKey `json:"api_key"`
+	// Key is the API key itself, which can't be retrieved again.
+	Key string `json:"key"`
+}
+
+// GetList ...
+// @Summary Get a list of API keys in an account.
+// @Description Get a list of API keys in an account, without the keys themselves. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} apikey.GetListResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/api-keys [get]
+func (co *Controller) GetList(c echo.Context) error {
+	ks, err := co.k.List(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list api keys")
+	}
+
+	return c.JSON(http.StatusOK, GetListResponse{APIKeys: ks})
+}
+
+// GetListResponse ...
+type GetListResponse struct {
+	APIKeys []*domain.APIKey `json:"api_keys"`
+}
+
+// DeleteAPIKey ...
+// @Summary Revoke an API key.
+// @Description Revoke an API key. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "API Key ID"
+// @Success 200 {object} apikey.DeleteAPIKeyResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/api-keys/{id} [delete]
+func (co *Controller) DeleteAPIKey(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	err := co.k.Revoke(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not revoke api key")
+	}
+
+	return c.JSON(http.StatusOK, DeleteAPIKeyResponse{ID: id})
+}
+
+// DeleteAPIKeyResponse ...
+type DeleteAPIKeyResponse struct {
+	ID domain.ID `json:"id"`
+}
@@ -0,0 +1,328 @@
+package challenge
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// Controller ...
+type Controller struct {
+	c domain.ChallengeUseCases
+}
+
+// New ...
+func New(c domain.ChallengeUseCases) *Controller {
+	return &Controller{c}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Scoped(domain.ScopeChallengesWrite, s.Private.POST("/api/v1/accounts/:account_id/challenges", co.PostChallenge))
+	s.Scoped(domain.ScopeChallengesRead, s.Private.GET("/api/v1/accounts/:account_id/challenges", co.GetList))
+	s.Scoped(domain.ScopeChallengesRead, s.Private.GET("/api/v1/accounts/:account_id/challenges/:id", co.GetChallenge))
+	s.Scoped(domain.ScopeChallengesWrite, s.Private.PATCH("/api/v1/accounts/:account_id/challenges/:id", co.PatchChallenge))
+	s.Scoped(domain.ScopeChallengesWrite, s.Private.POST("/api/v1/accounts/:account_id/challenges/:id/cancel", co.PostCancel))
+	s.Private.GET("/api/v1/accounts/:account_id/me/challenges", co.GetMyList)
+	s.Private.POST("/api/v1/accounts/:account_id/challenges/:id/start", co.PostStart)
+	s.Scoped(domain.ScopeChallengesRead, s.Private.GET("/api/v1/accounts/:account_id/challenges/:id/progress", co.GetProgress))
+	s.Private.POST("/api/v1/accounts/:account_id/challenges/:id/tasks/:task_id/submit", co.PostSubmitTask)
+	s.Scoped(domain.ScopeChallengesRead, s.Private.GET("/api/v1/accounts/:account_id/challenges/:id/tasks/:task_id/review", co.GetReview))
+}
+
+// PostChallenge ...
+// @Summary Create a new challenge for a candidate.
+// @Description Create a new challenge for a candidate from a set of tasks in the account's task library.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param opts body challenge.PostChallengeRequest true "Post Challenge Request"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges [post]
+func (co *Controller) PostChallenge(c echo.Context) (err error) {
+	r := new(PostChallengeRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	ch, err := co.c.Create(c.Request().Context(), domain.CreateChallengeArgs(*r))
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not create challenge")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// PostChallengeRequest ...
+type PostChallengeRequest struct {
+	ForUserID domain.ID   `json:"for_user_id" validate:"required,len=20"`
+	TaskIDs   []domain.ID `json:"task_ids" validate:"required,min=1,dive,len=20"`
+	ExpiresAt *time.Time  `json:"expires_at" validate:"omitempty"`
+}
+
+// GetChallenge ...
+// @Summary Get a challenge.
+// @Description Get a challenge.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id} [get]
+func (co *Controller) GetChallenge(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	ch, err := co.c.Get(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get challenge")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// PatchChallenge ...
+// @Summary Update a challenge's deadline.
+// @Description Update a challenge's deadline. New challenges are scheduled once they have a deadline.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Param opts body challenge.PatchChallengeRequest true "Patch Challenge Request"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Failure 409 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id} [patch]
+func (co *Controller) PatchChallenge(c echo.Context) (err error) {
+	id := domain.ID(c.Param("id"))
+
+	r := new(PatchChallengeRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	ch, err := co.c.SetExpiresAt(c.Request().Context(), id, r.ExpiresAt)
+	if err != nil {
+		return newStatusError(err, "could not update challenge")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// PatchChallengeRequest ...
+type PatchChallengeRequest struct {
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// PostCancel ...
+// @Summary Cancel a challenge.
+// @Description Cancel a challenge. Completed, failed, canceled and expired challenges cannot be canceled.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Failure 409 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/cancel [post]
+func (co *Controller) PostCancel(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	ch, err := co.c.Cancel(c.Request().Context(), id)
+	if err != nil {
+		return newStatusError(err, "could not cancel challenge")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// GetList ...
+// @Summary Get a list of challenges in an account.
+// @Description Get a list of challenges in an account, optionally filtered by status and candidate. Candidates only see their own challenges.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param status query string false "Comma-separated list of statuses, e.g. new,scheduled"
+// @Param for_user_id query string false "Candidate user ID"
+// @Success 200 {object} challenge.GetListResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges [get]
+func (co *Controller) GetList(c echo.Context) error {
+	a := domain.ListChallengesArgs{
+		Statuses:  statusesParam(c),
+		ForUserID: domain.ID(c.QueryParam("for_user_id")),
+	}
+
+	res, err := co.c.List(c.Request().Context(), a)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list challenges")
+	}
+
+	return c.JSON(http.StatusOK, GetListResponse{
+		Challenges: res.Challenges,
+		Total:      res.Total,
+	})
+}
+
+// GetListResponse ...
+type GetListResponse struct {
+	Challenges []*domain.Challenge `json:"challenges"`
+	Total      int                 `json:"total"`
+}
+
+// GetMyList ...
+// @Summary Get a list of challenges assigned to the current user.
+// @Description Get a list of challenges assigned to the current user, optionally filtered by status.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param status query string false "Comma-separated list of statuses, e.g. scheduled,active"
+// @Success 200 {object} challenge.GetListResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/me/challenges [get]
+func (co *Controller) GetMyList(c echo.Context) error {
+	res, err := co.c.ListMine(c.Request().Context(), statusesParam(c))
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list challenges")
+	}
+
+	return c.JSON(http.StatusOK, GetListResponse{
+		Challenges: res.Challenges,
+		Total:      res.Total,
+	})
+}
+
+// PostStart ...
+// @Summary Start a challenge.
+// @Description Start a challenge, which starts the clock for all of the challenge's tasks. Only the candidate the challenge was created for can start it.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Failure 403 {object} httpserver.ErrorResponse
+// @Failure 409 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/start [post]
+func (co *Controller) PostStart(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	ch, err := co.c.Start(c.Request().Context(), id)
+	if err != nil {
+		return newStatusError(err, "could not start challenge")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// GetProgress ...
+// @Summary Get the progress of a challenge.
+// @Description Get the remaining time and submission status of each of a challenge's tasks.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} domain.ChallengeProgress
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/progress [get]
+func (co *Controller) GetProgress(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	p, err := co.c.Progress(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get challenge progress")
+	}
+
+	return c.JSON(http.StatusOK, p)
+}
+
+// PostSubmitTask ...
+// @Summary Submit a challenge task.
+// @Description Submit a challenge task. Submissions are rejected once the task's time limit or the challenge's deadline has passed.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} domain.Challenge
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Failure 403 {object} httpserver.ErrorResponse
+// @Failure 409 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/tasks/{task_id}/submit [post]
+func (co *Controller) PostSubmitTask(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+	taskID := domain.ID(c.Param("task_id"))
+
+	ch, err := co.c.SubmitTask(c.Request().Context(), id, taskID)
+	if err != nil {
+		return newStatusError(err, "could not submit task")
+	}
+
+	return c.JSON(http.StatusOK, ch)
+}
+
+// GetReview ...
+// @Summary Get a candidate's code review.
+// @Description Get the reviews and line comments a candidate left on the pull request in a code review task. Only available to admins and hiring managers once the task has been submitted or its deadline has passed.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} domain.CodeReview
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/tasks/{task_id}/review [get]
+func (co *Controller) GetReview(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+	taskID := domain.ID(c.Param("task_id"))
+
+	r, err := co.c.Review(c.Request().Context(), id, taskID)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get review")
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+// statusesParam returns all statuses in the `status` query param.
+func statusesParam(c echo.Context) []domain.Status {
+	var statuses []domain.Status
+	for _, v := range c.QueryParams()["status"] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				statuses = append(statuses, domain.Status(s))
+			}
+		}
+	}
+	return statuses
+}
+
+// newStatusError returns a 409 Conflict error for illegal challenge
+// status transitions, changes to final challenges, submissions in
+// challenges that aren't active and repeated submissions, a 403
+// Forbidden error for missed deadlines and a 500 Internal Server Error
+// otherwise.
+func newStatusError(err error, message string) error {
+	var te *domain.TransitionError
+	switch {
+	case errors.As(err, &te):
+		return httpserver.NewError(http.StatusConflict, err, message+": "+te.Error())
+	case errors.Is(err, domain.ErrChallengeFinal):
+		return httpserver.NewError(http.StatusConflict, err, message+": "+domain.ErrChallengeFinal.Error())
+	case errors.Is(err, domain.ErrChallengeNotActive):
+		return httpserver.NewError(http.StatusConflict, err, message+": "+domain.ErrChallengeNotActive.Error())
+	case errors.Is(err, domain.ErrAlreadySubmitted):
+		return httpserver.NewError(http.StatusConflict, err, message+": "+domain.ErrAlreadySubmitted.Error())
+	case errors.Is(err, domain.ErrDeadlinePassed):
+		return httpserver.NewError(http.StatusForbidden, err, message+": "+domain.ErrDeadlinePassed.Error())
+	}
+	return httpserver.NewError(http.StatusInternalServerError, err, message)
+}
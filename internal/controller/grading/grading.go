@@ -0,0 +1,47 @@
+package grading
+
+import (
+	"net/http"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+)
+
+// Controller ...
+type Controller struct {
+	g domain.GradingUseCases
+}
+
+// New ...
+func New(g domain.GradingUseCases) *Controller {
+	return &Controller{g}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Scoped(domain.ScopeChallengesRead, s.Private.GET("/api/v1/accounts/:account_id/challenges/:id/tasks/:task_id/diff", co.GetDiff))
+}
+
+// GetDiff ...
+// @Summary Get a candidate's changes to a task's starter code.
+// @Description Get a per-file unified diff between a task's starter code and the latest commit in the candidate's repo, or the repo's snapshot once it's been archived. Binary files and files that are too large are listed without a patch. Only available to admins and hiring managers.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} domain.SubmissionDiff
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/tasks/{task_id}/diff [get]
+func (co *Controller) GetDiff(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+	taskID := domain.ID(c.Param("task_id"))
+
+	d, err := co.g.Diff(c.Request().Context(), id, taskID)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get diff")
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
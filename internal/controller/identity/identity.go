@@ -0,0 +1,45 @@
+package identity
+
+import (
+	"net/http"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+)
+
+// Controller ...
+type Controller struct {
+	i domain.IdentityUseCases
+}
+
+// New ...
+func New(i domain.IdentityUseCases) *Controller {
+	return &Controller{i}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Private.POST("/api/v1/accounts/:account_id/users/:id/reveal", co.PostReveal)
+}
+
+// PostReveal ...
+// @Summary Reveal a candidate's identity to reviewers.
+// @Description Reveal a candidate's identity to hiring managers in blind review mode, from then on. Every reveal is recorded in the account's audit log. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "User ID"
+// @Success 200 {object} domain.User
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/users/{id}/reveal [post]
+func (co *Controller) PostReveal(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	u, err := co.i.Reveal(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not reveal identity")
+	}
+
+	return c.JSON(http.StatusOK, u)
+}
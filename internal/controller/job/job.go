@@ -0,0 +1,73 @@
+package job
+
+import (
+	"net/http"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+)
+
+// Controller ...
+type Controller struct {
+	j domain.JobUseCases
+}
+
+// New ...
+func New(j domain.JobUseCases) *Controller {
+	return &Controller{j}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Scoped(domain.ScopeJobsRead, s.Private.GET("/api/v1/accounts/:account_id/jobs/dead", co.GetDeadList))
+	s.Scoped(domain.ScopeJobsWrite, s.Private.POST("/api/v1/accounts/:account_id/jobs/:id/retry", co.PostRetry))
+}
+
+// GetDeadList ...
+// @Summary Get a list of dead jobs in an account.
+// @Description Get a list of background jobs in an account that failed and used up all their attempts.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} job.GetDeadListResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/jobs/dead [get]
+func (co *Controller) GetDeadList(c echo.Context) error {
+	res, err := co.j.ListDead(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list dead jobs")
+	}
+
+	return c.JSON(http.StatusOK, GetDeadListResponse{
+		Jobs:  res.Jobs,
+		Total: res.Total,
+	})
+}
+
+// GetDeadListResponse ...
+type GetDeadListResponse struct {
+	Jobs  []*domain.Job `json:"jobs"`
+	Total int           `json:"total"`
+}
+
+// PostRetry ...
+// @Summary Retry a dead job.
+// @Description Put a dead job back in the queue with a fresh set of attempts.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Job ID"
+// @Success 200 {object} domain.Job
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/jobs/{id}/retry [post]
+func (co *Controller) PostRetry(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	j, err := co.j.Retry(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not retry job")
+	}
+
+	return c.JSON(http.StatusOK, j)
+}
@@ -119,7 +119,7 @@ func (co *Controller) GetOAuthCallback(c echo.Context) error {
 		// Do the login oauth flow.
 		if state.AccountCode != "" {
 			// Perform full login if we're passed an account code.
-			res, err := co.u.GithubLogin(ctx, state.AccountCode, up.ID)
+			res, err := co.u.GithubLogin(ctx, state.AccountCode, up.ID, httpserver.ClientInfo(c))
 			if err != nil {
 				return httpserver.NewError(http.StatusInternalServerError, err, "could not perform login")
 			}
@@ -128,6 +128,7 @@ func (co *Controller) GetOAuthCallback(c echo.Context) error {
 			resp.Account = res.Account
 			resp.User = res.User
 			resp.Token = res.Token
+			resp.RefreshToken = res.RefreshToken
 		} else {
 			// Otherwise return all available accounts for user.
 			as, err := co.u.GithubGetAvailableAccounts(ctx, up.ID)
@@ -171,6 +172,7 @@ func (co *Controller) GetOAuthCallback(c echo.Context) error {
 			GithubLogin: up.Login,
 			PhotoURL:    up.AvatarURL,
 			Location:    up.Location,
+			Client:      httpserver.ClientInfo(c),
 		})
 		if err != nil {
 			return httpserver.NewError(http.StatusInternalServerError, err, "could not perform signup")
@@ -180,6 +182,7 @@ func (co *Controller) GetOAuthCallback(c echo.Context) error {
 		resp.Account = res.Account
 		resp.User = res.User
 		resp.Token = res.Token
+		resp.RefreshToken = res.RefreshToken
 
 		return c.JSON(http.StatusOK, resp)
 
@@ -193,6 +196,7 @@ type GetOAuthCallbackResponse struct {
 	Account           *domain.Account       `json:"account"`
 	User              *domain.User          `json:"user"`
 	Token             string                `json:"token"`
+	RefreshToken      string                `json:"refresh_token"`
 	AvailableAccounts []*domain.AccountInfo `json:"available_accounts"`
 	Type              responseType          `json:"type"`
 }
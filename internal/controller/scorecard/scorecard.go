@@ -0,0 +1,117 @@
+package scorecard
+
+import (
+	"net/http"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+)
+
+// Controller ...
+type Controller struct {
+	s domain.ScorecardUseCases
+}
+
+// New ...
+func New(s domain.ScorecardUseCases) *Controller {
+	return &Controller{s}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Scoped(domain.ScopeScorecardsWrite, s.Private.PUT("/api/v1/accounts/:account_id/challenges/:id/tasks/:task_id/scorecard", co.PutScorecard))
+	s.Scoped(domain.ScopeScorecardsRead, s.Private.GET("/api/v1/accounts/:account_id/challenges/:id/scorecards", co.GetScorecards))
+	s.Scoped(domain.ScopeScorecardsRead, s.Private.GET("/api/v1/accounts/:account_id/scorecards/calibration", co.GetCalibration))
+}
+
+// PutScorecard ...
+// @Summary Score a candidate's work on a task.
+// @Description Create or update the current user's scorecard for a challenge task. Every criterion in the task's rubric must be scored from 1 to 5, and the overall score is the weighted average of the scores. Earlier versions of the scorecard are kept in its history. Only available to admins and hiring managers.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Param task_id path string true "Task ID"
+// @Param opts body scorecard.PutScorecardRequest true "Put Scorecard Request"
+// @Success 200 {object} domain.Scorecard
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/tasks/{task_id}/scorecard [put]
+func (co *Controller) PutScorecard(c echo.Context) (err error) {
+	id := domain.ID(c.Param("id"))
+	taskID := domain.ID(c.Param("task_id"))
+
+	r := new(PutScorecardRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	var scores []*domain.CriterionScore
+	for _, cs := range r.Scores {
+		ds := domain.CriterionScore(cs)
+		scores = append(scores, &ds)
+	}
+
+	s, err := co.s.Submit(c.Request().Context(), id, taskID, domain.SubmitScorecardArgs{
+		Scores:  scores,
+		Comment: r.Comment,
+	})
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not save scorecard")
+	}
+
+	return c.JSON(http.StatusOK, s)
+}
+
+// PutScorecardRequest ...
+type PutScorecardRequest struct {
+	Scores  []CriterionScore `json:"scores" validate:"required,min=1,max=50,dive"`
+	Comment string           `json:"comment" validate:"omitempty,lte=4096"`
+}
+
+// CriterionScore ...
+type CriterionScore struct {
+	Criterion string `json:"criterion" validate:"required,gte=1,lte=128"`
+	Score     int    `json:"score" validate:"required,gte=1,lte=5"`
+	Comment   string `json:"comment" validate:"omitempty,lte=1024"`
+}
+
+// GetScorecards ...
+// @Summary Get all scorecards for a challenge.
+// @Description Get all reviewers' scorecards for a challenge's tasks, with each task's average overall and per-criterion scores and the average across scored tasks. Scoring is blind: other reviewers' scores on a task are hidden until the current user has scored it. Only available to admins and hiring managers.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Challenge ID"
+// @Success 200 {object} domain.ChallengeScorecards
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/challenges/{id}/scorecards [get]
+func (co *Controller) GetScorecards(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	res, err := co.s.List(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get scorecards")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// GetCalibration ...
+// @Summary Get a reviewer calibration report.
+// @Description Get a report on how well the account's reviewers agree when they score the same challenge tasks: Krippendorff's alpha and variance of overall and per-criterion scores, and reviewers who are consistently harsher or more lenient than others. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} domain.CalibrationReport
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/scorecards/calibration [get]
+func (co *Controller) GetCalibration(c echo.Context) error {
+	res, err := co.s.Calibration(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not get calibration report")
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
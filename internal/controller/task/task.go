@@ -0,0 +1,290 @@
+package task
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/httpserver"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+// Controller ...
+type Controller struct {
+	t domain.TaskUseCases
+}
+
+// New ...
+func New(t domain.TaskUseCases) *Controller {
+	return &Controller{t}
+}
+
+// SetupRoutes ...
+func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
+	s.Scoped(domain.ScopeTasksWrite, s.Private.POST("/api/v1/accounts/:account_id/tasks", co.PostTask))
+	s.Scoped(domain.ScopeTasksRead, s.Private.GET("/api/v1/accounts/:account_id/tasks", co.GetList))
+	s.Scoped(domain.ScopeTasksRead, s.Private.GET("/api/v1/accounts/:account_id/tasks/:id", co.GetTask))
+	s.Scoped(domain.ScopeTasksWrite, s.Private.PATCH("/api/v1/accounts/:account_id/tasks/:id", co.PatchTask))
+	s.Scoped(domain.ScopeTasksWrite, s.Private.DELETE("/api/v1/accounts/:account_id/tasks/:id", co.DeleteTask))
+	s.Scoped(domain.ScopeTasksWrite, s.Private.PUT("/api/v1/accounts/:account_id/tasks/:id/rubric", co.PutRubric))
+}
+
+// PostTask ...
+// @Summary Create a new task in an account's task library.
+// @Description Create a new task in an account's task library.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param opts body task.PostTaskRequest true "Post Task Request"
+// @Success 200 {object} domain.Task
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks [post]
+func (co *Controller) PostTask(c echo.Context) (err error) {
+	r := new(PostTaskRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	t, err := co.t.Create(c.Request().Context(), domain.CreateTaskArgs{
+		Name:             r.Name,
+		Type:             r.Type,
+		GithubRepoName:   r.GithubRepoName,
+		TimeLimit:        time.Duration(r.TimeLimitMinutes) * time.Minute,
+		ReviewBranch:     r.ReviewBranch,
+		ExpectedFindings: findings(r.ExpectedFindings),
+		TestCommand:      r.TestCommand,
+		TestReport:       r.TestReport,
+	})
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not create task")
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
+
+// PostTaskRequest ...
+type PostTaskRequest struct {
+	Name             string            `json:"name" validate:"required,gte=1,lte=128"`
+	Type             domain.TaskType   `json:"type" validate:"required,oneof=refactor code_review coding"`
+	GithubRepoName   string            `json:"github_repo_name" validate:"omitempty,gte=1"`
+	TimeLimitMinutes int               `json:"time_limit_minutes" validate:"omitempty,gte=1,lte=10080"`
+	ReviewBranch     string            `json:"review_branch" validate:"omitempty,gte=1,lte=255"`
+	ExpectedFindings []ExpectedFinding `json:"expected_findings" validate:"omitempty,max=100,dive"`
+	TestCommand      string            `json:"test_command" validate:"omitempty,gte=1,lte=1024"`
+	TestReport       string            `json:"test_report" validate:"omitempty,gte=1,lte=1024"`
+}
+
+// GetTask ...
+// @Summary Get a task in an account's task library.
+// @Description Get a task in an account's task library.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Task ID"
+// @Success 200 {object} domain.Task
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks/{id} [get]
+func (co *Controller) GetTask(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	t, err := co.t.Get(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusNotFound, err, "could not get task")
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
+
+// PatchTask ...
+// @Summary Update a task in an account's task library.
+// @Description Update a task in an account's task library.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Task ID"
+// @Param opts body task.PatchTaskRequest true "Patch Task Request"
+// @Success 200 {object} domain.Task
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks/{id} [patch]
+func (co *Controller) PatchTask(c echo.Context) (err error) {
+	id := domain.ID(c.Param("id"))
+
+	r := new(PatchTaskRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	t, err := co.t.Update(c.Request().Context(), id, domain.UpdateTaskArgs{
+		Name:             r.Name,
+		GithubRepoName:   r.GithubRepoName,
+		TimeLimit:        time.Duration(r.TimeLimitMinutes) * time.Minute,
+		ReviewBranch:     r.ReviewBranch,
+		ExpectedFindings: findings(r.ExpectedFindings),
+		TestCommand:      r.TestCommand,
+		TestReport:       r.TestReport,
+	})
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not update task")
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
+
+// PatchTaskRequest ...
+type PatchTaskRequest struct {
+	Name             string            `json:"name" validate:"omitempty,gte=1,lte=128"`
+	GithubRepoName   string            `json:"github_repo_name" validate:"omitempty,gte=1"`
+	TimeLimitMinutes int               `json:"time_limit_minutes" validate:"omitempty,gte=1,lte=10080"`
+	ReviewBranch     string            `json:"review_branch" validate:"omitempty,gte=1,lte=255"`
+	ExpectedFindings []ExpectedFinding `json:"expected_findings" validate:"omitempty,max=100,dive"`
+	TestCommand      string            `json:"test_command" validate:"omitempty,gte=1,lte=1024"`
+	TestReport       string            `json:"test_report" validate:"omitempty,gte=1,lte=1024"`
+}
+
+// ExpectedFinding ...
+type ExpectedFinding struct {
+	Path        string                 `json:"path" validate:"required,gte=1,lte=1024"`
+	StartLine   int                    `json:"start_line" validate:"omitempty,gte=1"`
+	EndLine     int                    `json:"end_line" validate:"omitempty,gtefield=StartLine"`
+	Category    domain.FindingCategory `json:"category" validate:"required,oneof=bug security performance design style testing"`
+	Weight      float64                `json:"weight" validate:"omitempty,gt=0,lte=100"`
+	Description string                 `json:"description" validate:"omitempty,lte=1024"`
+}
+
+// findings converts expected findings in a request to domain findings.
+func findings(fs []ExpectedFinding) []*domain.ExpectedFinding {
+	if fs == nil {
+		return nil
+	}
+
+	res := []*domain.ExpectedFinding{}
+	for _, f := range fs {
+		df := domain.ExpectedFinding(f)
+		res = append(res, &df)
+	}
+
+	return res
+}
+
+// PutRubric ...
+// @Summary Set the rubric reviewers score a task against.
+// @Description Set the weighted criteria that reviewers score candidates' work on a task against. An empty list of criteria removes the rubric. Admins only.
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Task ID"
+// @Param opts body task.PutRubricRequest true "Put Rubric Request"
+// @Success 200 {object} domain.Task
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks/{id}/rubric [put]
+func (co *Controller) PutRubric(c echo.Context) (err error) {
+	id := domain.ID(c.Param("id"))
+
+	r := new(PutRubricRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	var rubric *domain.Rubric
+	if len(r.Criteria) > 0 {
+		rubric = &domain.Rubric{}
+		for _, rc := range r.Criteria {
+			dc := domain.RubricCriterion(rc)
+			rubric.Criteria = append(rubric.Criteria, &dc)
+		}
+	}
+
+	t, err := co.t.SetRubric(c.Request().Context(), id, rubric)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not set rubric")
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
+
+// PutRubricRequest ...
+type PutRubricRequest struct {
+	Criteria []RubricCriterion `json:"criteria" validate:"omitempty,max=50,dive"`
+}
+
+// RubricCriterion ...
+type RubricCriterion struct {
+	Name        string   `json:"name" validate:"required,gte=1,lte=128"`
+	Description string   `json:"description" validate:"omitempty,lte=1024"`
+	Weight      float64  `json:"weight" validate:"required,gt=0,lte=100"`
+	Levels      []string `json:"levels" validate:"omitempty,len=5,dive,lte=1024"`
+}
+
+// DeleteTask ...
+// @Summary Delete a task from an account's task library.
+// @Description Delete a task from an account's task library.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Task ID"
+// @Success 200 {object} task.DeleteTaskResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks/{id} [delete]
+func (co *Controller) DeleteTask(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	err := co.t.Delete(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not delete task")
+	}
+
+	return c.JSON(http.StatusOK, DeleteTaskResponse{id})
+}
+
+// DeleteTaskResponse ...
+type DeleteTaskResponse struct {
+	ID domain.ID `json:"id"`
+}
+
+// GetList ...
+// @Summary Get a list of tasks in an account's task library.
+// @Description Get a list of tasks in an account's task library, optionally filtered by task type.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param type query string false "Comma-separated list of task types, e.g. refactor,coding"
+// @Success 200 {object} task.GetListResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/tasks [get]
+func (co *Controller) GetList(c echo.Context) error {
+	var types []domain.TaskType
+	for _, v := range c.QueryParams()["type"] {
+		for _, t := range strings.Split(v, ",") {
+			tt := domain.TaskType(strings.TrimSpace(t))
+			if tt == "" {
+				continue
+			}
+			if !tt.IsValid() {
+				err := errors.Errorf("invalid task type '%s'", tt)
+				return httpserver.NewError(http.StatusBadRequest, err, err.Error())
+			}
+			types = append(types, tt)
+		}
+	}
+
+	res, err := co.t.List(c.Request().Context(), domain.ListTasksArgs{Types: types})
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list tasks")
+	}
+
+	return c.JSON(http.StatusOK, GetListResponse{
+		Tasks: res.Tasks,
+		Total: res.Total,
+	})
+}
+
+// GetListResponse ...
+type GetListResponse struct {
+	Tasks []*domain.Task `json:"tasks"`
+	Total int            `json:"total"`
+}
@@ -3,10 +3,12 @@ package user
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/anrid/codecoach/internal/domain"
 	"github.com/anrid/codecoach/internal/pkg/httpserver"
 	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
 )
 
 // Controller ...
@@ -23,10 +25,23 @@ func New(u domain.UserUseCases) *Controller {
 func (co *Controller) SetupRoutes(s *httpserver.HTTPServer) {
 	s.Echo.POST("/api/v1/signup", co.Signup)
 	s.Echo.POST("/api/v1/login", co.Login)
-	s.Private.POST("/api/v1/accounts/:account_id/users", co.PostUser)
-	s.Private.GET("/api/v1/accounts/:account_id/users", co.GetList)
-	s.Private.PATCH("/api/v1/accounts/:account_id/users/:id", co.PatchUser)
+	s.Echo.POST("/api/v1/token/refresh", co.Refresh)
+	s.Scoped(domain.ScopeUsersWrite, s.Private.POST("/api/v1/accounts/:account_id/users", co.PostUser))
+	s.Scoped(domain.ScopeUsersRead, s.Private.GET("/api/v1/accounts/:account_id/users", co.GetList))
+	s.Scoped(domain.ScopeUsersWrite, s.Private.PATCH("/api/v1/accounts/:account_id/users/:id", co.PatchUser))
 	s.Private.GET("/api/v1/accounts/:account_id/secret", co.GetSecret)
+	s.Private.GET("/api/v1/accounts/:account_id/sessions", co.GetSessions)
+	s.Private.DELETE("/api/v1/accounts/:account_id/sessions", co.DeleteOtherSessions)
+	s.Private.DELETE("/api/v1/accounts/:account_id/sessions/current", co.DeleteCurrentSession)
+	s.Private.DELETE("/api/v1/accounts/:account_id/sessions/:id", co.DeleteSession)
+	s.Private.DELETE("/api/v1/accounts/:account_id/users/:id/sessions", co.DeleteUserSessions)
+}
+
+// SetupPasswordResetRoutes sets up routes to reset forgotten passwords,
+// which only make sense once reset links can be emailed to users.
+func (co *Controller) SetupPasswordResetRoutes(s *httpserver.HTTPServer) {
+	s.Echo.POST("/api/v1/password-reset", co.RequestPasswordReset)
+	s.Echo.POST("/api/v1/password-reset/confirm", co.ResetPassword)
 }
 
 // GetSecret ...
@@ -85,11 +100,12 @@ func (co *Controller) PostUser(c echo.Context) (err error) {
 
 // PostUserRequest ...
 type PostUserRequest struct {
-	GivenName  string      `json:"given_name" validate:"required,gte=1"`
-	FamilyName string      `json:"family_name" validate:"required,gte=1"`
-	Email      string      `json:"email" validate:"required,email"`
-	Password   string      `json:"password" validate:"required,gte=8"`
-	Role       domain.Role `json:"role" validate:"required,oneof=admin hiring_manager candidate"`
+	GivenName   string      `json:"given_name" validate:"required,gte=1"`
+	FamilyName  string      `json:"family_name" validate:"required,gte=1"`
+	Email       string      `json:"email" validate:"required,email"`
+	Password    string      `json:"password" validate:"required,gte=8"`
+	Role        domain.Role `json:"role" validate:"required,oneof=admin hiring_manager candidate"`
+	GithubLogin string      `json:"github_login" validate:"omitempty,gte=1,lte=39"`
 }
 
 // Signup ...
@@ -106,6 +122,7 @@ func (co *Controller) Signup(c echo.Context) (err error) {
 		FamilyName:  r.FamilyName,
 		Email:       r.Email,
 		Password:    r.Password,
+		Client:      httpserver.ClientInfo(c),
 	})
 	if err != nil {
 		return httpserver.NewError(http.StatusInternalServerError, err, "could not perform signup")
@@ -125,9 +142,10 @@ type SignupRequest struct {
 
 // SignupResponse ...
 type SignupResponse struct {
-	Account *domain.Account `json:"account"`
-	User    *domain.User    `json:"user"`
-	Token   string          `json:"token"`
+	Account      *domain.Account `json:"account"`
+	User         *domain.User    `json:"user"`
+	Token        string          `json:"token"`
+	RefreshToken string          `json:"refresh_token"`
 }
 
 // Login ...
@@ -137,7 +155,7 @@ func (co *Controller) Login(c echo.Context) (err error) {
 		return err
 	}
 
-	res, err := co.u.Login(c.Request().Context(), r.AccountCode, r.Email, r.Password)
+	res, err := co.u.Login(c.Request().Context(), r.AccountCode, r.Email, r.Password, httpserver.ClientInfo(c))
 	if err != nil {
 		return httpserver.NewError(http.StatusInternalServerError, err, "could not perform login")
 	}
@@ -154,9 +172,129 @@ type LoginRequest struct {
 
 // LoginResponse ...
 type LoginResponse struct {
-	Account *domain.Account `json:"account"`
-	User    *domain.User    `json:"user"`
-	Token   string          `json:"token"`
+	Account      *domain.Account `json:"account"`
+	User         *domain.User    `json:"user"`
+	Token        string          `json:"token"`
+	RefreshToken string          `json:"refresh_token"`
+}
+
+// Refresh ...
+// @Summary Exchange a refresh token for a new access token and refresh token.
+// @Description Exchange a refresh token for a new access token and refresh token. Refresh tokens are single use; reusing one revokes its session.
+// @Accept json
+// @Produce json
+// @Param opts body user.RefreshRequest true "Refresh Request"
+// @Success 200 {object} user.RefreshResponse
+// @Failure 401 {object} httpserver.ErrorResponse
+// @Router /token/refresh [post]
+func (co *Controller) Refresh(c echo.Context) (err error) {
+	r := new(RefreshRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	res, err := co.u.Refresh(c.Request().Context(), r.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRefreshTokenReused):
+			return httpserver.NewError(http.StatusUnauthorized, err, domain.ErrRefreshTokenReused.Error())
+		case errors.Is(err, domain.ErrSessionEnded):
+			return httpserver.NewError(http.StatusUnauthorized, err, domain.ErrSessionEnded.Error())
+		case errors.Is(err, domain.ErrRefreshTokenInvalid):
+			return httpserver.NewError(http.StatusUnauthorized, err, domain.ErrRefreshTokenInvalid.Error())
+		}
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not refresh token")
+	}
+
+	return c.JSON(http.StatusOK, RefreshResponse(*res))
+}
+
+// RefreshRequest ...
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshResponse ...
+type RefreshResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RequestPasswordReset ...
+// @Summary Email a password reset link to a user.
+// @Description Email a single use, time-limited password reset link to a user. Always succeeds, whether or not the email belongs to a user in the account and whether or not the request is rate limited.
+// @Accept json
+// @Produce json
+// @Param opts body user.RequestPasswordResetRequest true "Request Password Reset Request"
+// @Success 200 {object} user.PasswordResetResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /password-reset [post]
+func (co *Controller) RequestPasswordReset(c echo.Context) (err error) {
+	r := new(RequestPasswordResetRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	err = co.u.RequestPasswordReset(c.Request().Context(), domain.RequestPasswordResetArgs{
+		AccountCode: r.AccountCode,
+		Email:       r.Email,
+	})
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not request password reset")
+	}
+
+	return c.JSON(http.StatusOK, PasswordResetResponse{
+		Message: "if the email belongs to a user in the account, a reset link has been sent to it",
+	})
+}
+
+// RequestPasswordResetRequest ...
+type RequestPasswordResetRequest struct {
+	AccountCode string `json:"account_code" validate:"required,gte=2"`
+	Email       string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetResponse ...
+type PasswordResetResponse struct {
+	Message string `json:"message"`
+}
+
+// ResetPassword ...
+// @Summary Set a new password using a password reset token.
+// @Description Set a new password using a password reset token. Ends all of the user's sessions.
+// @Accept json
+// @Produce json
+// @Param opts body user.ResetPasswordRequest true "Reset Password Request"
+// @Success 200 {object} user.PasswordResetResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /password-reset/confirm [post]
+func (co *Controller) ResetPassword(c echo.Context) (err error) {
+	r := new(ResetPasswordRequest)
+	if err = httpserver.BindAndValidate(c, r); err != nil {
+		return err
+	}
+
+	err = co.u.ResetPassword(c.Request().Context(), domain.ResetPasswordArgs{
+		Token:    r.Token,
+		Password: r.Password,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrPasswordResetInvalid) {
+			return httpserver.NewError(http.StatusBadRequest, err, domain.ErrPasswordResetInvalid.Error())
+		}
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not reset password")
+	}
+
+	return c.JSON(http.StatusOK, PasswordResetResponse{
+		Message: "password reset, please log in again",
+	})
+}
+
+// ResetPasswordRequest ...
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,gte=8"`
 }
 
 // PatchUser ...
@@ -232,3 +370,111 @@ type GetListResponse struct {
 	TotalPages int            `json:"total_pages"`
 	Total      int            `json:"total"`
 }
+
+// GetSessions ...
+// @Summary Get the current user's sessions.
+// @Description Get the current user's sessions, e.g. one per device the user is logged in on.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} user.GetSessionsResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/sessions [get]
+func (co *Controller) GetSessions(c echo.Context) error {
+	ss, err := co.u.ListSessions(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not list sessions")
+	}
+
+	return c.JSON(http.StatusOK, GetSessionsResponse{
+		Sessions: ss,
+	})
+}
+
+// GetSessionsResponse ...
+type GetSessionsResponse struct {
+	Sessions []*domain.UserSession `json:"sessions"`
+}
+
+// DeleteCurrentSession ...
+// @Summary Log out, i.e. end the current session.
+// @Description Log out, i.e. end the current session.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} user.DeleteSessionsResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/sessions/current [delete]
+func (co *Controller) DeleteCurrentSession(c echo.Context) error {
+	err := co.u.Logout(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not log out")
+	}
+
+	return c.JSON(http.StatusOK, DeleteSessionsResponse{Revoked: 1})
+}
+
+// DeleteSession ...
+// @Summary Revoke one of the current user's sessions.
+// @Description Revoke one of the current user's sessions.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "Session ID"
+// @Success 200 {object} user.DeleteSessionsResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/sessions/{id} [delete]
+func (co *Controller) DeleteSession(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	err := co.u.RevokeSession(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not revoke session")
+	}
+
+	return c.JSON(http.StatusOK, DeleteSessionsResponse{Revoked: 1})
+}
+
+// DeleteOtherSessions ...
+// @Summary Revoke all of the current user's sessions except the current one.
+// @Description Revoke all of the current user's sessions except the current one, i.e. log out everywhere else.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Success 200 {object} user.DeleteSessionsResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/sessions [delete]
+func (co *Controller) DeleteOtherSessions(c echo.Context) error {
+	n, err := co.u.RevokeOtherSessions(c.Request().Context())
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not revoke sessions")
+	}
+
+	return c.JSON(http.StatusOK, DeleteSessionsResponse{Revoked: n})
+}
+
+// DeleteUserSessions ...
+// @Summary Revoke all sessions of a user in an account.
+// @Description Revoke all sessions of a user in an account. Admins only.
+// @Produce json
+// @Security Bearer
+// @Param account_id path string true "Account ID"
+// @Param id path string true "User ID"
+// @Success 200 {object} user.DeleteSessionsResponse
+// @Failure 400 {object} httpserver.ErrorResponse
+// @Router /accounts/{account_id}/users/{id}/sessions [delete]
+func (co *Controller) DeleteUserSessions(c echo.Context) error {
+	id := domain.ID(c.Param("id"))
+
+	n, err := co.u.RevokeUserSessions(c.Request().Context(), id)
+	if err != nil {
+		return httpserver.NewError(http.StatusInternalServerError, err, "could not revoke user's sessions")
+	}
+
+	return c.JSON(http.StatusOK, DeleteSessionsResponse{Revoked: n})
+}
+
+// DeleteSessionsResponse ...
+type DeleteSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
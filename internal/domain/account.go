@@ -9,14 +9,15 @@ import (
 
 // Account ...
 type Account struct {
-	ID        ID             `json:"id" db:"id"`
-	Name      string         `json:"name" db:"name"`
-	Code      string         `json:"code" db:"code"`
-	Profile   AccountProfile `json:"profile" db:"profile"`
-	Members   Members        `json:"members" db:"members"`
-	OwnerID   ID             `json:"owner_id" db:"owner_id"`
-	CreatedAt time.Time      `json:"created_at" db:"created_at"`
-	UpdatedAt *time.Time     `json:"updated_at" db:"updated_at"`
+	ID        ID              `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Code      string          `json:"code" db:"code"`
+	Profile   AccountProfile  `json:"profile" db:"profile"`
+	Settings  AccountSettings `json:"settings" db:"settings"`
+	Members   Members         `json:"members" db:"members"`
+	OwnerID   ID              `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt *time.Time      `json:"updated_at" db:"updated_at"`
 }
 
 // NewAccount ...
@@ -59,6 +60,13 @@ type AccountProfile struct {
 	Logo string `json:"logo"`
 }
 
+// AccountSettings ...
+type AccountSettings struct {
+	// BlindReview hides candidates' identities from hiring managers
+	// until an admin reveals them.
+	BlindReview bool `json:"blind_review"`
+}
+
 // Role ...
 type Role string
 
@@ -102,10 +110,13 @@ type AccountInfo struct {
 // AccountUseCases ...
 type AccountUseCases interface {
 	Update(ctx context.Context, id ID, a UpdateAccountArgs) (*Account, error)
+	// AuditLog returns the account's audit log, newest first.
+	AuditLog(ctx context.Context) ([]*AuditEntry, error)
 }
 
 // UpdateAccountArgs ...
 type UpdateAccountArgs struct {
-	Name string
-	Logo string
+	Name        string
+	Logo        string
+	BlindReview *bool
 }
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// APIKeyPrefix starts every API key, which sets them apart from
+// session access tokens.
+const APIKeyPrefix = "cck_"
+
+// APIKeyTouchInterval is how often an API key's last used time is
+// updated while it's in use.
+const APIKeyTouchInterval = time.Minute
+
+// APIKey lets scripts call the API without a user's login token. A key
+// acts on behalf of the admin who created it, but can only call routes
+// that its scopes allow.
+type APIKey struct {
+	AccountID ID     `json:"account_id" db:"account_id"`
+	ID        ID     `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	// Prefix is the start of the key, which identifies it in lists
+	// without revealing the key itself.
+	Prefix     string     `json:"prefix" db:"prefix"`
+	KeyHash    string     `json:"-" db:"key_hash"`
+	Scopes     Scopes     `json:"scopes" db:"scopes"`
+	CreatedBy  ID         `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	// Key is only known when the key is created. Only its hash is
+	// stored.
+	Key string `json:"-" db:"-"`
+}
+
+// Scope ...
+type Scope string
+
+// Scopes ...
+type Scopes []Scope
+
+const (
+	// ScopeUsersRead ...
+	ScopeUsersRead Scope = "users:read"
+	// ScopeUsersWrite ...
+	ScopeUsersWrite Scope = "users:write"
+	// ScopeTasksRead ...
+	ScopeTasksRead Scope = "tasks:read"
+	// ScopeTasksWrite ...
+	ScopeTasksWrite Scope = "tasks:write"
+	// ScopeChallengesRead ...
+	ScopeChallengesRead Scope = "challenges:read"
+	// ScopeChallengesWrite ...
+	ScopeChallengesWrite Scope = "challenges:write"
+	// ScopeScorecardsRead ...
+	ScopeScorecardsRead Scope = "scorecards:read"
+	// ScopeScorecardsWrite ...
+	ScopeScorecardsWrite Scope = "scorecards:write"
+	// ScopeJobsRead ...
+	ScopeJobsRead Scope = "jobs:read"
+	// ScopeJobsWrite ...
+	ScopeJobsWrite Scope = "jobs:write"
+)
+
+// AllScopes ...
+var AllScopes = Scopes{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopeTasksRead,
+	ScopeTasksWrite,
+	ScopeChallengesRead,
+	ScopeChallengesWrite,
+	ScopeScorecardsRead,
+	ScopeScorecardsWrite,
+	ScopeJobsRead,
+	ScopeJobsWrite,
+}
+
+// Has returns true if s contains the given scope.
+func (s Scopes) Has(scope Scope) bool {
+	for _, x := range s {
+		if x == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate ...
+func (s Scopes) Validate() error {
+	if len(s) == 0 {
+		return errors.New("missing scopes")
+	}
+	for _, x := range s {
+		if !AllScopes.Has(x) {
+			return errors.Errorf("invalid scope '%s'", x)
+		}
+	}
+	return nil
+}
+
+// NewAPIKeyArgs ...
+type NewAPIKeyArgs struct {
+	AccountID ID
+	CreatedBy ID
+	Name      string
+	Scopes    Scopes
+	ExpiresAt *time.Time
+	// Code is the random part of the key's prefix and Secret the rest
+	// of the key.
+	Code   string
+	Secret string
+}
+
+// NewAPIKey returns a new key, which the caller must hash before
+// storing it.
+func NewAPIKey(a NewAPIKeyArgs) (*APIKey, error) {
+	if a.Name = strings.TrimSpace(a.Name); a.Name == "" {
+		return nil, errors.New("missing name arg")
+	}
+	if err := a.Scopes.Validate(); err != nil {
+		return nil, err
+	}
+	if a.ExpiresAt != nil && !a.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	prefix := APIKeyPrefix + a.Code
+	key := prefix + "_" + a.Secret
+
+	return &APIKey{
+		AccountID: a.AccountID,
+		ID:        NewID(),
+		Name:      a.Name,
+		Prefix:    prefix,
+		Scopes:    a.Scopes,
+		CreatedBy: a.CreatedBy,
+		CreatedAt: time.Now(),
+		ExpiresAt: a.ExpiresAt,
+		Key:       key,
+	}, nil
+}
+
+// IsAPIKey returns true if the given bearer token looks like an API
+// key rather than a session access token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Expired ...
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
+
+// APIKeyDAO ...
+type APIKeyDAO interface {
+	Create(ctx context.Context, k *APIKey) error
+	GetByKeyHash(ctx context.Context, keyHash string) (*APIKey, error)
+	List(ctx context.Context, accountID ID) ([]*APIKey, error)
+	Delete(ctx context.Context, accountID, id ID) error
+	// Touch updates a key's last used time.
+	Touch(ctx context.Context, accountID, id ID, at time.Time) error
+}
+
+// APIKeyUseCases ...
+type APIKeyUseCases interface {
+	Create(ctx context.Context, a CreateAPIKeyArgs) (*APIKey, error)
+	List(ctx context.Context) ([]*APIKey, error)
+	Revoke(ctx context.Context, id ID) error
+}
+
+// CreateAPIKeyArgs ...
+type CreateAPIKeyArgs struct {
+	Name      string
+	Scopes    Scopes
+	ExpiresAt *time.Time
+}
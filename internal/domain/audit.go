@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEntry records a sensitive action taken in an account.
+type AuditEntry struct {
+	AccountID ID          `json:"account_id" db:"account_id"`
+	ID        ID          `json:"id" db:"id"`
+	ActorID   ID          `json:"actor_id" db:"actor_id"`
+	Action    AuditAction `json:"action" db:"action"`
+	// TargetID is the ID of the user, challenge etc. acted on, if any.
+	TargetID  ID        `json:"target_id" db:"target_id"`
+	Details   string    `json:"details" db:"details"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AuditAction ...
+type AuditAction string
+
+const (
+	// AuditActionRevealIdentity means an admin revealed a candidate's
+	// identity to reviewers in blind review mode.
+	AuditActionRevealIdentity AuditAction = "reveal_identity"
+	// AuditActionSetBlindReview means an admin turned blind review mode
+	// on or off.
+	AuditActionSetBlindReview AuditAction = "set_blind_review"
+	// AuditActionCreateAPIKey means an admin created an API key.
+	AuditActionCreateAPIKey AuditAction = "create_api_key"
+	// AuditActionRevokeAPIKey means an admin revoked an API key.
+	AuditActionRevokeAPIKey AuditAction = "revoke_api_key"
+)
+
+// NewAuditEntry ...
+func NewAuditEntry(accountID, actorID ID, action AuditAction, targetID ID, details string) *AuditEntry {
+	return &AuditEntry{
+		AccountID: accountID,
+		ID:        NewID(),
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+}
+
+// AuditDAO ...
+type AuditDAO interface {
+	Create(ctx context.Context, e *AuditEntry) error
+	// List returns an account's audit entries, newest first, optionally
+	// only those with the given actions.
+	List(ctx context.Context, accountID ID, actions ...AuditAction) ([]*AuditEntry, error)
+}
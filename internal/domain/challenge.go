@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +17,7 @@ type Challenge struct {
 	Details         ChallengeDetails `json:"details" db:"details"`
 	CreatedByUserID ID               `json:"created_by_user_id" db:"created_by_user_id"`
 	ExpiresAt       *time.Time       `json:"expires_at" db:"expires_at"`
+	StartedAt       *time.Time       `json:"started_at" db:"started_at"`
 	CreatedAt       time.Time        `json:"created_at" db:"created_at"`
 	UpdatedAt       *time.Time       `json:"updated_at" db:"updated_at"`
 }
@@ -41,15 +44,21 @@ func NewChallenge(a NewChallengeArgs) (*Challenge, error) {
 		return nil, errors.Errorf("missing tasks arg")
 	}
 
+	now := time.Now()
+
 	c := &Challenge{
 		AccountID:       a.AccountID,
 		ID:              NewID(),
 		ForUserID:       a.ForUserID,
+		Status:          StatusNew,
 		CreatedByUserID: a.CreatedByUserID,
 		Details: ChallengeDetails{
 			Tasks: a.Tasks,
+			History: []StatusChange{
+				{To: StatusNew, At: now, ByUserID: a.CreatedByUserID},
+			},
 		},
-		CreatedAt: time.Now(),
+		CreatedAt: now,
 	}
 
 	return c, nil
@@ -57,13 +66,372 @@ func NewChallenge(a NewChallengeArgs) (*Challenge, error) {
 
 // ChallengeDetails ...
 type ChallengeDetails struct {
-	Tasks []*Task `json:"tasks"`
+	Tasks    []*Task         `json:"tasks"`
+	History  []StatusChange  `json:"history"`
+	Progress []*TaskProgress `json:"progress"`
+	// PendingJobs are jobs that have yet to be enqueued.
+	PendingJobs []*PendingJob `json:"pending_jobs,omitempty"`
+}
+
+// TaskProgress tracks a candidate's progress on a single challenge task.
+type TaskProgress struct {
+	TaskID      ID                `json:"task_id"`
+	DeadlineAt  *time.Time        `json:"deadline_at"`
+	SubmittedAt *time.Time        `json:"submitted_at"`
+	Repo        *TaskRepo         `json:"repo"`
+	Review      *CodeReview       `json:"review"`
+	Grade       *TestGrade        `json:"grade"`
+	Metrics     *RefactorReport   `json:"metrics"`
+	Similarity  *SimilarityReport `json:"similarity"`
+	Timeline    *CommitTimeline   `json:"timeline"`
+}
+
+// Remaining returns the time left until the task's deadline, or nil if
+// the task has no deadline.
+func (p *TaskProgress) Remaining(now time.Time) *time.Duration {
+	if p.DeadlineAt == nil {
+		return nil
+	}
+	d := p.DeadlineAt.Sub(now)
+	if d < 0 || p.SubmittedAt != nil {
+		d = 0
+	}
+	return &d
+}
+
+// HideGrading removes everything used to grade the challenge's tasks,
+// i.e. expected findings, test commands, review scores, test grades,
+// refactoring metrics, similarity reports and commit timelines, so that
+// the challenge can be shown to its candidate.
+func (c *Challenge) HideGrading() {
+	for i, t := range c.Details.Tasks {
+		h := *t
+		h.Details.ExpectedFindings = nil
+		h.Details.TestCommand = ""
+		h.Details.TestReport = ""
+		h.Details.Rubric = nil
+		c.Details.Tasks[i] = &h
+	}
+
+	for i, tp := range c.Details.Progress {
+		p := *tp
+		p.Grade = nil
+		p.Metrics = nil
+		p.Similarity = nil
+		p.Timeline = nil
+		if tp.Review != nil {
+			r := *tp.Review
+			r.Score = nil
+			p.Review = &r
+		}
+		c.Details.Progress[i] = &p
+	}
+}
+
+var (
+	// ErrDeadlinePassed is returned when a candidate tries to start or
+	// submit work after a challenge or task deadline.
+	ErrDeadlinePassed = errors.New("deadline has passed")
+	// ErrAlreadySubmitted is returned when a candidate tries to submit
+	// a task more than once.
+	ErrAlreadySubmitted = errors.New("task has already been submitted")
+	// ErrChallengeFinal is returned when trying to change a challenge
+	// that has been completed, canceled or has expired.
+	ErrChallengeFinal = errors.New("challenge is final")
+	// ErrChallengeNotActive is returned when a candidate tries to
+	// submit a task in a challenge they haven't started.
+	ErrChallengeNotActive = errors.New("challenge is not active")
+)
+
+// Start activates the challenge and starts the clock for all of its
+// tasks. A task's deadline is the start time plus the task's time limit,
+// capped by the challenge's ExpiresAt.
+func (c *Challenge) Start(byUserID ID, now time.Time) error {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return ErrDeadlinePassed
+	}
+
+	err := c.Transition(StatusActive, byUserID)
+	if err != nil {
+		return err
+	}
+
+	c.StartedAt = &now
+	c.Details.Progress = nil
+
+	for _, t := range c.Details.Tasks {
+		var deadline *time.Time
+		if t.Details.TimeLimit > 0 {
+			d := now.Add(t.Details.TimeLimit)
+			deadline = &d
+		}
+		if c.ExpiresAt != nil && (deadline == nil || c.ExpiresAt.Before(*deadline)) {
+			d := *c.ExpiresAt
+			deadline = &d
+		}
+
+		c.Details.Progress = append(c.Details.Progress, &TaskProgress{
+			TaskID:     t.ID,
+			DeadlineAt: deadline,
+		})
+	}
+
+	return nil
+}
+
+// SetExpiresAt sets the challenge's deadline. In active challenges,
+// the deadlines of tasks that are still open are recomputed as if the
+// challenge had started with the new deadline. It returns the progress
+// of the tasks whose deadlines changed.
+func (c *Challenge) SetExpiresAt(expiresAt, now time.Time) []*TaskProgress {
+	c.ExpiresAt = &expiresAt
+
+	if c.Status != StatusActive || c.StartedAt == nil {
+		return nil
+	}
+
+	var changed []*TaskProgress
+
+	for _, t := range c.Details.Tasks {
+		p, err := c.TaskProgress(t.ID)
+		if err != nil || p.SubmittedAt != nil {
+			continue
+		}
+		if p.DeadlineAt != nil && !now.Before(*p.DeadlineAt) {
+			// Too late, the repo has already been archived.
+			continue
+		}
+
+		deadline := expiresAt
+		if t.Details.TimeLimit > 0 {
+			if d := c.StartedAt.Add(t.Details.TimeLimit); d.Before(deadline) {
+				deadline = d
+			}
+		}
+
+		if p.DeadlineAt != nil && p.DeadlineAt.Equal(deadline) {
+			continue
+		}
+
+		p.DeadlineAt = &deadline
+		changed = append(changed, p)
+	}
+
+	return changed
+}
+
+// TaskProgress returns the progress of the given task.
+func (c *Challenge) TaskProgress(taskID ID) (*TaskProgress, error) {
+	for _, p := range c.Details.Progress {
+		if p.TaskID == taskID {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("could not find progress for task %s in challenge %s", taskID, c.ID)
+}
+
+// SubmitTask marks a task as submitted. The challenge is completed once
+// all of its tasks have been submitted.
+func (c *Challenge) SubmitTask(taskID, byUserID ID, now time.Time) error {
+	if c.IsFinal() {
+		return ErrChallengeFinal
+	}
+	if c.Status != StatusActive {
+		return ErrChallengeNotActive
+	}
+
+	p, err := c.TaskProgress(taskID)
+	if err != nil {
+		return err
+	}
+	if p.SubmittedAt != nil {
+		return ErrAlreadySubmitted
+	}
+	if p.DeadlineAt != nil && now.After(*p.DeadlineAt) {
+		return ErrDeadlinePassed
+	}
+	if c.ExpiresAt != nil && now.After(*c.ExpiresAt) {
+		return ErrDeadlinePassed
+	}
+
+	p.SubmittedAt = &now
+
+	for _, p := range c.Details.Progress {
+		if p.SubmittedAt == nil {
+			return nil
+		}
+	}
+
+	return c.Transition(StatusCompleted, byUserID)
+}
+
+// StatusChange records a single status transition.
+type StatusChange struct {
+	From     Status    `json:"from,omitempty"`
+	To       Status    `json:"to"`
+	At       time.Time `json:"at"`
+	ByUserID ID        `json:"by_user_id,omitempty"`
+}
+
+// challengeTransitions defines all legal challenge status transitions.
+// Completed, failed, canceled and expired challenges are final and can
+// never be moved to another status.
+var challengeTransitions = map[Status][]Status{
+	StatusNew:       {StatusScheduled, StatusActive, StatusCanceled, StatusExpired},
+	StatusScheduled: {StatusActive, StatusCanceled, StatusExpired},
+	StatusActive:    {StatusCompleted, StatusFailed, StatusCanceled, StatusExpired},
+}
+
+// CanTransitionChallenge returns true if a challenge is allowed to
+// move from one status to another.
+func CanTransitionChallenge(from, to Status) bool {
+	for _, s := range challengeTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal returns true if the challenge has reached a status
+// it can never leave.
+func (c *Challenge) IsFinal() bool {
+	return len(challengeTransitions[c.Status]) == 0
+}
+
+// Transition moves the challenge to a new status and records the
+// change in the challenge's transition history. An illegal move
+// returns a *TransitionError and leaves the challenge untouched.
+func (c *Challenge) Transition(to Status, byUserID ID) error {
+	if !CanTransitionChallenge(c.Status, to) {
+		return &TransitionError{From: c.Status, To: to}
+	}
+
+	c.Details.History = append(c.Details.History, StatusChange{
+		From:     c.Status,
+		To:       to,
+		At:       time.Now(),
+		ByUserID: byUserID,
+	})
+	c.Status = to
+
+	return nil
+}
+
+// TransitionError is returned when trying to make an illegal
+// status transition.
+type TransitionError struct {
+	From Status
+	To   Status
+}
+
+// Error ...
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("illegal status transition from '%s' to '%s'", e.From, e.To)
 }
 
 // ChallengeDAO ...
 type ChallengeDAO interface {
-	Create(u *Challenge) error
-	Get(accountID, id ID) (*Challenge, error)
-	GetAll(accountID ID, ids []ID) ([]*Challenge, error)
-	Update(accountID ID, id ID, updates []Field) (*Challenge, error)
+	Create(ctx context.Context, c *Challenge) error
+	Get(ctx context.Context, accountID, id ID) (*Challenge, error)
+	GetAll(ctx context.Context, accountID ID, ids []ID) ([]*Challenge, error)
+	List(ctx context.Context, accountID ID, a ListChallengesArgs) ([]*Challenge, int, error)
+	Update(ctx context.Context, accountID, id ID, updates []Field) (*Challenge, error)
+	// Modify loads a challenge, locks it for the duration of the
+	// given func and saves all changes made to it by the func.
+	Modify(ctx context.Context, accountID, id ID, fn func(c *Challenge) error) (*Challenge, error)
+	// ListOverdue lists challenges in all accounts that aren't final
+	// and expired before the given time, oldest first.
+	ListOverdue(ctx context.Context, before time.Time, limit int) ([]*Challenge, error)
+	// ListWithPendingJobs lists challenges in all accounts that have
+	// jobs that have yet to be enqueued.
+	ListWithPendingJobs(ctx context.Context, limit int) ([]*Challenge, error)
+}
+
+// ChallengeUseCases ...
+type ChallengeUseCases interface {
+	Create(ctx context.Context, a CreateChallengeArgs) (*Challenge, error)
+	Get(ctx context.Context, id ID) (*Challenge, error)
+	List(ctx context.Context, a ListChallengesArgs) (*ListChallengesResult, error)
+	SetExpiresAt(ctx context.Context, id ID, expiresAt time.Time) (*Challenge, error)
+	Cancel(ctx context.Context, id ID) (*Challenge, error)
+	ListMine(ctx context.Context, statuses []Status) (*ListChallengesResult, error)
+	Start(ctx context.Context, id ID) (*Challenge, error)
+	Progress(ctx context.Context, id ID) (*ChallengeProgress, error)
+	SubmitTask(ctx context.Context, id, taskID ID) (*Challenge, error)
+	// Review returns the candidate's review in a code review task.
+	Review(ctx context.Context, id, taskID ID) (*CodeReview, error)
+	// ExpireOverdue expires all challenges past their expiry date and
+	// returns the number of challenges expired.
+	ExpireOverdue(ctx context.Context) (int, error)
+	// EnqueuePendingJobs enqueues jobs that challenges needed but that
+	// couldn't be enqueued at the time, and returns the number of jobs
+	// enqueued.
+	EnqueuePendingJobs(ctx context.Context) (int, error)
+	// NotifyExpired is the job handler that tells a challenge's creator
+	// that the challenge has expired.
+	NotifyExpired(ctx context.Context, j *Job, p JobPayload) error
+}
+
+const (
+	// JobTypeNotifyChallengeExpired ...
+	JobTypeNotifyChallengeExpired JobType = "notify_challenge_expired"
+)
+
+// ChallengeExpiredPayload ...
+type ChallengeExpiredPayload struct {
+	AccountID   ID `json:"account_id"`
+	ChallengeID ID `json:"challenge_id"`
+}
+
+// JobType ...
+func (p *ChallengeExpiredPayload) JobType() JobType {
+	return JobTypeNotifyChallengeExpired
+}
+
+// Validate ...
+func (p *ChallengeExpiredPayload) Validate() error {
+	if p.AccountID == "" || p.ChallengeID == "" {
+		return errors.Errorf("missing account or challenge id")
+	}
+	return nil
+}
+
+// CreateChallengeArgs ...
+type CreateChallengeArgs struct {
+	ForUserID ID
+	TaskIDs   []ID
+	ExpiresAt *time.Time
+}
+
+// ListChallengesArgs ...
+type ListChallengesArgs struct {
+	Statuses  []Status
+	ForUserID ID
+}
+
+// ListChallengesResult ...
+type ListChallengesResult struct {
+	Challenges []*Challenge
+	Total      int
+}
+
+// ChallengeProgress ...
+type ChallengeProgress struct {
+	ChallengeID ID                  `json:"challenge_id"`
+	Status      Status              `json:"status"`
+	StartedAt   *time.Time          `json:"started_at"`
+	ExpiresAt   *time.Time          `json:"expires_at"`
+	Tasks       []*TaskProgressInfo `json:"tasks"`
+}
+
+// TaskProgressInfo ...
+type TaskProgressInfo struct {
+	TaskID           ID         `json:"task_id"`
+	Name             string     `json:"name"`
+	Type             TaskType   `json:"type"`
+	DeadlineAt       *time.Time `json:"deadline_at"`
+	RemainingSeconds *int64     `json:"remaining_seconds"`
+	SubmittedAt      *time.Time `json:"submitted_at"`
+	Repo             *TaskRepo  `json:"repo"`
 }
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeSetExpiresAt(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+
+	newChallenge := func() *Challenge {
+		c := &Challenge{
+			Status: StatusScheduled,
+			Details: ChallengeDetails{
+				Tasks: []*Task{
+					{ID: "limited", Details: TaskDetails{TimeLimit: 2 * time.Hour}},
+					{ID: "unlimited"},
+				},
+			},
+		}
+		expiresAt := at(10)
+		c.ExpiresAt = &expiresAt
+		if err := c.Start("candidate", start); err != nil {
+			panic(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		now       time.Time
+		submitted bool
+		changed   []ID
+		deadlines map[ID]time.Time
+	}{
+		{
+			name:      "extending moves open tasks up to their time limit",
+			expiresAt: at(20),
+			now:       at(1),
+			changed:   []ID{"unlimited"},
+			deadlines: map[ID]time.Time{"limited": at(2), "unlimited": at(20)},
+		},
+		{
+			name:      "shortening caps all open tasks",
+			expiresAt: at(1),
+			now:       start,
+			changed:   []ID{"limited", "unlimited"},
+			deadlines: map[ID]time.Time{"limited": at(1), "unlimited": at(1)},
+		},
+		{
+			name:      "tasks past their deadline are left alone",
+			expiresAt: at(20),
+			now:       at(3),
+			changed:   []ID{"unlimited"},
+			deadlines: map[ID]time.Time{"limited": at(2), "unlimited": at(20)},
+		},
+		{
+			name:      "submitted tasks are left alone",
+			expiresAt: at(20),
+			now:       at(1),
+			submitted: true,
+			changed:   nil,
+			deadlines: map[ID]time.Time{"limited": at(2), "unlimited": at(10)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			c := newChallenge()
+			if tt.submitted {
+				for _, p := range c.Details.Progress {
+					p.SubmittedAt = &tt.now
+				}
+			}
+
+			var changed []ID
+			for _, p := range c.SetExpiresAt(tt.expiresAt, tt.now) {
+				changed = append(changed, p.TaskID)
+			}
+
+			r.Equal(tt.changed, changed)
+			r.Equal(tt.expiresAt, *c.ExpiresAt)
+			for id, d := range tt.deadlines {
+				p, err := c.TaskProgress(id)
+				r.NoError(err)
+				r.Equal(d, *p.DeadlineAt, id)
+			}
+		})
+	}
+
+	// Challenges that haven't started have no task deadlines to move.
+	c := &Challenge{Status: StatusScheduled}
+	r := require.New(t)
+	r.Empty(c.SetExpiresAt(at(1), start))
+	r.Equal(at(1), *c.ExpiresAt)
+}
+
+func TestChallengeSubmitTaskStatus(t *testing.T) {
+	tests := []struct {
+		status Status
+		err    error
+	}{
+		{status: StatusNew, err: ErrChallengeNotActive},
+		{status: StatusScheduled, err: ErrChallengeNotActive},
+		{status: StatusCompleted, err: ErrChallengeFinal},
+		{status: StatusFailed, err: ErrChallengeFinal},
+		{status: StatusCanceled, err: ErrChallengeFinal},
+		{status: StatusExpired, err: ErrChallengeFinal},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			c := &Challenge{Status: tt.status}
+			require.Equal(t, tt.err, c.SubmitTask("task", "candidate", time.Now()))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package domain
+
+// SubmissionDiff is what a candidate changed in a task's starter code.
+type SubmissionDiff struct {
+	// Commit is the commit in the candidate's repo that was compared to
+	// the starter code.
+	Commit string      `json:"commit"`
+	Files  []*FileDiff `json:"files"`
+	// Added, Removed and Modified count files.
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+	// Additions and Deletions count lines in text files.
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+	// Truncated is set if the patches of some files were left out to
+	// keep the diff's size down.
+	Truncated bool `json:"truncated"`
+}
+
+// FileDiff ...
+type FileDiff struct {
+	Path      string         `json:"path"`
+	Status    FileDiffStatus `json:"status"`
+	Binary    bool           `json:"binary"`
+	Additions int            `json:"additions"`
+	Deletions int            `json:"deletions"`
+	// TooLarge is set if either version of the file is too large to
+	// diff.
+	TooLarge bool `json:"too_large"`
+	// Patch is the unified diff of the file, without file headers. It's
+	// empty for binary files and files that are too large to diff.
+	Patch string `json:"patch"`
+}
+
+// FileDiffStatus ...
+type FileDiffStatus string
+
+const (
+	// FileDiffStatusAdded ...
+	FileDiffStatusAdded FileDiffStatus = "added"
+	// FileDiffStatusRemoved ...
+	FileDiffStatusRemoved FileDiffStatus = "removed"
+	// FileDiffStatusModified ...
+	FileDiffStatusModified FileDiffStatus = "modified"
+)
@@ -35,8 +35,12 @@ func Dump(o interface{}) {
 
 // Session ...
 type Session struct {
-	RequestID string
-	User      *User
+	RequestID   string
+	User        *User
+	UserSession *UserSession
+	// APIKey is set if the request was made with an API key, in which
+	// case User is the admin who created the key.
+	APIKey *APIKey
 }
 
 type contextKey string
@@ -48,7 +52,7 @@ const (
 var requestIDCounter uint64
 
 // ContextWithSession ...
-func ContextWithSession(parent context.Context, u *User) context.Context {
+func ContextWithSession(parent context.Context, u *User, us *UserSession, k *APIKey) context.Context {
 	if parent == nil {
 		parent = context.Background()
 	}
@@ -57,8 +61,10 @@ func ContextWithSession(parent context.Context, u *User) context.Context {
 	requestID := fmt.Sprintf("%s-%d", time.Now().Format("2006-01-02"), id)
 
 	s := &Session{
-		RequestID: requestID,
-		User:      u,
+		RequestID:   requestID,
+		User:        u,
+		UserSession: us,
+		APIKey:      k,
 	}
 
 	return context.WithValue(parent, contextKeySession, s)
@@ -107,4 +113,6 @@ const (
 	StatusCanceled Status = "canceled"
 	// StatusExpired ...
 	StatusExpired Status = "expired"
+	// StatusDead ...
+	StatusDead Status = "dead"
 )
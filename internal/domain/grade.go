@@ -0,0 +1,250 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TestGrade is the automated grade of a coding or refactoring task,
+// based on running the task's hidden tests against the candidate's
+// code in a sandbox.
+type TestGrade struct {
+	Status GradeStatus `json:"status"`
+	// Commit is the commit in the candidate's repo that was tested.
+	Commit  string `json:"commit"`
+	Passed  int    `json:"passed"`
+	Failed  int    `json:"failed"`
+	Skipped int    `json:"skipped"`
+	Total   int    `json:"total"`
+	// Score is the share of tests that passed, not counting skipped
+	// tests.
+	Score    float64       `json:"score"`
+	ExitCode int           `json:"exit_code"`
+	TimedOut bool          `json:"timed_out"`
+	Duration time.Duration `json:"duration"`
+	Tests    []*TestResult `json:"tests"`
+	// Log is the tail of the test command's output, which explains
+	// errors that aren't tied to a test.
+	Log      string    `json:"log"`
+	GradedAt time.Time `json:"graded_at"`
+}
+
+// GradeStatus ...
+type GradeStatus string
+
+const (
+	// GradeStatusPassed means all tests passed.
+	GradeStatusPassed GradeStatus = "passed"
+	// GradeStatusFailed means some tests failed, or the test command
+	// failed or timed out.
+	GradeStatusFailed GradeStatus = "failed"
+	// GradeStatusError means no test results could be found, e.g.
+	// because the repo was empty or the tests didn't produce a report.
+	GradeStatusError GradeStatus = "error"
+)
+
+// TestResult ...
+type TestResult struct {
+	Package string     `json:"package"`
+	Name    string     `json:"name"`
+	Status  TestStatus `json:"status"`
+	// Elapsed is the test's run time in seconds.
+	Elapsed float64 `json:"elapsed"`
+	Output  string  `json:"output"`
+}
+
+// TestStatus ...
+type TestStatus string
+
+const (
+	// TestStatusPass ...
+	TestStatusPass TestStatus = "pass"
+	// TestStatusFail ...
+	TestStatusFail TestStatus = "fail"
+	// TestStatusSkip ...
+	TestStatusSkip TestStatus = "skip"
+)
+
+// Tally counts the tests by status and sets the grade's status and
+// score. A grade without tests is an error.
+func (g *TestGrade) Tally() {
+	g.Passed, g.Failed, g.Skipped = 0, 0, 0
+
+	for _, t := range g.Tests {
+		switch t.Status {
+		case TestStatusPass:
+			g.Passed++
+		case TestStatusFail:
+			g.Failed++
+		case TestStatusSkip:
+			g.Skipped++
+		}
+	}
+
+	g.Total = len(g.Tests)
+	g.Score = 0
+	if n := g.Passed + g.Failed; n > 0 {
+		g.Score = float64(g.Passed) / float64(n)
+	}
+
+	switch {
+	case g.Total == 0:
+		g.Status = GradeStatusError
+	case g.Failed > 0 || g.ExitCode != 0 || g.TimedOut:
+		g.Status = GradeStatusFailed
+	default:
+		g.Status = GradeStatusPassed
+	}
+}
+
+// RefactorReport compares static code metrics of the starter code and
+// the candidate's code in a refactoring task.
+type RefactorReport struct {
+	Before *CodeMetrics `json:"before"`
+	After  *CodeMetrics `json:"after"`
+	// Delta is After minus Before. Lower is better for all metrics
+	// except the number of files, lines, functions and packages, which
+	// only give context.
+	Delta *CodeMetrics `json:"delta"`
+	// Commit is the commit in the candidate's repo that was analyzed.
+	Commit     string    `json:"commit"`
+	AnalyzedAt time.Time `json:"analyzed_at"`
+}
+
+// CodeMetrics are static code metrics of the Go code in a repo.
+type CodeMetrics struct {
+	Files             int     `json:"files"`
+	Lines             int     `json:"lines"`
+	Functions         int     `json:"functions"`
+	AvgComplexity     float64 `json:"avg_complexity"`
+	MaxComplexity     int     `json:"max_complexity"`
+	ComplexFunctions  int     `json:"complex_functions"`
+	AvgFunctionLength float64 `json:"avg_function_length"`
+	MaxFunctionLength int     `json:"max_function_length"`
+	LongFunctions     int     `json:"long_functions"`
+	// Duplication is the share of code that's duplicated elsewhere.
+	Duplication float64 `json:"duplication"`
+	Packages    int     `json:"packages"`
+	// AvgCoupling is the average number of other packages in the repo
+	// imported by each package.
+	AvgCoupling  float64 `json:"avg_coupling"`
+	MaxCoupling  int     `json:"max_coupling"`
+	LintFindings int     `json:"lint_findings"`
+	// VetFindings is the number of problems reported by `go vet`, or
+	// nil if it couldn't be run.
+	VetFindings *int               `json:"vet_findings"`
+	Hotspots    []*FunctionMetrics `json:"hotspots"`
+}
+
+// FunctionMetrics ...
+type FunctionMetrics struct {
+	Name       string `json:"name"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Lines      int    `json:"lines"`
+	Complexity int    `json:"complexity"`
+}
+
+// Sub returns the difference between m and o, without hotspots.
+func (m *CodeMetrics) Sub(o *CodeMetrics) *CodeMetrics {
+	d := &CodeMetrics{
+		Files:             m.Files - o.Files,
+		Lines:             m.Lines - o.Lines,
+		Functions:         m.Functions - o.Functions,
+		AvgComplexity:     m.AvgComplexity - o.AvgComplexity,
+		MaxComplexity:     m.MaxComplexity - o.MaxComplexity,
+		ComplexFunctions:  m.ComplexFunctions - o.ComplexFunctions,
+		AvgFunctionLength: m.AvgFunctionLength - o.AvgFunctionLength,
+		MaxFunctionLength: m.MaxFunctionLength - o.MaxFunctionLength,
+		LongFunctions:     m.LongFunctions - o.LongFunctions,
+		Duplication:       m.Duplication - o.Duplication,
+		Packages:          m.Packages - o.Packages,
+		AvgCoupling:       m.AvgCoupling - o.AvgCoupling,
+		MaxCoupling:       m.MaxCoupling - o.MaxCoupling,
+		LintFindings:      m.LintFindings - o.LintFindings,
+	}
+
+	if m.VetFindings != nil && o.VetFindings != nil {
+		v := *m.VetFindings - *o.VetFindings
+		d.VetFindings = &v
+	}
+
+	return d
+}
+
+const (
+	// JobTypeRunTests ...
+	JobTypeRunTests JobType = "run_tests"
+	// JobTypeAnalyzeRefactor ...
+	JobTypeAnalyzeRefactor JobType = "analyze_refactor"
+)
+
+// RunTestsPayload ...
+type RunTestsPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *RunTestsPayload) JobType() JobType {
+	return JobTypeRunTests
+}
+
+// AnalyzeRefactorPayload ...
+type AnalyzeRefactorPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *AnalyzeRefactorPayload) JobType() JobType {
+	return JobTypeAnalyzeRefactor
+}
+
+// GradingJobs returns the jobs that grade a candidate's work on a task.
+// If pending is set, grading that's already been done is left out,
+// except for the commit timeline, which is always rebuilt since
+// candidates can keep pushing until their access is revoked.
+func GradingJobs(ref TaskRef, t *Task, tp *TaskProgress, pending bool) []EnqueueJobArgs {
+	if tp.Repo == nil || tp.Repo.Name == "" {
+		return nil
+	}
+
+	var jobs []EnqueueJobArgs
+
+	if t.Type == TaskTypeCodeReview && tp.Repo.PullNumber != 0 && (!pending || tp.Review == nil) {
+		jobs = append(jobs, EnqueueJobArgs{Name: "collect review", Payload: &CollectReviewPayload{TaskRef: ref}})
+	}
+	if t.Details.TestCommand != "" && (!pending || tp.Grade == nil) {
+		jobs = append(jobs, EnqueueJobArgs{Name: "run tests", Payload: &RunTestsPayload{TaskRef: ref}})
+	}
+	if t.Type == TaskTypeRefactor && (!pending || tp.Metrics == nil) {
+		jobs = append(jobs, EnqueueJobArgs{Name: "analyze refactor", Payload: &AnalyzeRefactorPayload{TaskRef: ref}})
+	}
+	if t.Type != TaskTypeCodeReview && (!pending || tp.Similarity == nil) {
+		jobs = append(jobs, EnqueueJobArgs{Name: "check similarity", Payload: &CheckSimilarityPayload{TaskRef: ref}})
+	}
+	jobs = append(jobs, EnqueueJobArgs{Name: "analyze timeline", Payload: &AnalyzeTimelinePayload{TaskRef: ref}})
+
+	for i := range jobs {
+		jobs[i].AccountID = ref.AccountID
+	}
+
+	return jobs
+}
+
+// GradingUseCases ...
+type GradingUseCases interface {
+	// RunTests is the job handler that runs a task's hidden tests
+	// against the candidate's repo.
+	RunTests(ctx context.Context, j *Job, p JobPayload) error
+	// AnalyzeRefactor is the job handler that compares code metrics of
+	// the starter code and the candidate's repo in refactoring tasks.
+	AnalyzeRefactor(ctx context.Context, j *Job, p JobPayload) error
+	// CheckSimilarity is the job handler that compares the candidate's
+	// repo to other candidates' repos in the same task.
+	CheckSimilarity(ctx context.Context, j *Job, p JobPayload) error
+	// AnalyzeTimeline is the job handler that builds a timeline of the
+	// commits in the candidate's repo.
+	AnalyzeTimeline(ctx context.Context, j *Job, p JobPayload) error
+	// Diff returns what the candidate changed in a task's starter code.
+	Diff(ctx context.Context, id, taskID ID) (*SubmissionDiff, error)
+}
@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// Pseudonym is a stable stand-in for a candidate's identity, shown to
+// reviewers in blind review mode.
+type Pseudonym string
+
+// NewPseudonym returns the pseudonym of a candidate, which is the same
+// every time for the same candidate.
+func NewPseudonym(accountID, userID ID) Pseudonym {
+	sum := sha256.Sum256([]byte(string(accountID) + "/" + string(userID)))
+	return Pseudonym(strings.ToUpper(hex.EncodeToString(sum[:3])))
+}
+
+// Login returns the pseudonym as a Github login.
+func (p Pseudonym) Login() string {
+	return "candidate-" + strings.ToLower(string(p))
+}
+
+// Email returns the pseudonym as an email address.
+func (p Pseudonym) Email() string {
+	return p.Login() + "@anonymous.invalid"
+}
+
+// Blind hides candidates' identities by replacing them with pseudonyms
+// and scrubbing their Github logins and emails from repo content and
+// Github data. A nil Blind hides nothing.
+type Blind struct {
+	candidates map[ID]*blindCandidate
+}
+
+type blindCandidate struct {
+	pseudonym Pseudonym
+	// identifiers matches the candidate's Github login and email.
+	identifiers *regexp.Regexp
+}
+
+// NewBlind ...
+func NewBlind() *Blind {
+	return &Blind{candidates: make(map[ID]*blindCandidate)}
+}
+
+// Add hides a candidate's identity.
+func (b *Blind) Add(u *User) {
+	bc := &blindCandidate{pseudonym: NewPseudonym(u.AccountID, u.ID)}
+
+	// Emails go first so that a login that's also the email's local
+	// part doesn't break up the email.
+	var ids []string
+	if u.Email != "" {
+		ids = append(ids, regexp.QuoteMeta(u.Email))
+	}
+	if u.Profile.GithubLogin != "" {
+		ids = append(ids, `\b`+regexp.QuoteMeta(u.Profile.GithubLogin)+`\b`)
+	}
+	if len(ids) > 0 {
+		bc.identifiers = regexp.MustCompile(`(?i)(` + strings.Join(ids, "|") + `)`)
+	}
+
+	b.candidates[u.ID] = bc
+}
+
+// Hides returns true if the candidate's identity is hidden.
+func (b *Blind) Hides(userID ID) bool {
+	return b != nil && b.candidates[userID] != nil
+}
+
+// User replaces a hidden candidate's email and profile with their
+// pseudonym.
+func (b *Blind) User(u *User) {
+	if !b.Hides(u.ID) {
+		return
+	}
+	p := b.candidates[u.ID].pseudonym
+
+	u.Email = p.Email()
+	u.GithubID = 0
+	u.Profile.GivenName = "Candidate"
+	u.Profile.FamilyName = string(p)
+	u.Profile.PhotoURL = ""
+	u.Profile.GithubLogin = p.Login()
+	u.Profile.Location = ""
+}
+
+// Text scrubs a hidden candidate's Github login and email from s.
+func (b *Blind) Text(userID ID, s string) string {
+	if !b.Hides(userID) {
+		return s
+	}
+	bc := b.candidates[userID]
+	if bc.identifiers == nil {
+		return s
+	}
+
+	p := bc.pseudonym
+	return bc.identifiers.ReplaceAllStringFunc(s, func(m string) string {
+		if strings.Contains(m, "@") {
+			return p.Email()
+		}
+		return p.Login()
+	})
+}
+
+// Challenge scrubs the candidate's identity from a challenge's repos
+// and grading data.
+func (b *Blind) Challenge(c *Challenge) {
+	if !b.Hides(c.ForUserID) {
+		return
+	}
+	scrub := func(s string) string { return b.Text(c.ForUserID, s) }
+
+	for _, tp := range c.Details.Progress {
+		if r := tp.Repo; r != nil {
+			r.Owner = scrub(r.Owner)
+			r.Name = scrub(r.Name)
+			r.URL = scrub(r.URL)
+			r.PullURL = scrub(r.PullURL)
+			r.Error = scrub(r.Error)
+		}
+		if r := tp.Review; r != nil {
+			b.Review(c.ForUserID, r)
+		}
+		if g := tp.Grade; g != nil {
+			g.Log = scrub(g.Log)
+			for _, t := range g.Tests {
+				t.Package = scrub(t.Package)
+				t.Output = scrub(t.Output)
+			}
+		}
+		if m := tp.Metrics; m != nil {
+			for _, cm := range []*CodeMetrics{m.Before, m.After} {
+				if cm == nil {
+					continue
+				}
+				for _, h := range cm.Hotspots {
+					h.File = scrub(h.File)
+				}
+			}
+		}
+		if t := tp.Timeline; t != nil {
+			for _, tc := range t.Commits {
+				tc.Author = b.candidates[c.ForUserID].pseudonym.Login()
+				tc.Message = scrub(tc.Message)
+			}
+		}
+	}
+}
+
+// Review scrubs a hidden candidate's identity from their code review.
+func (b *Blind) Review(userID ID, r *CodeReview) {
+	if !b.Hides(userID) {
+		return
+	}
+	scrub := func(s string) string { return b.Text(userID, s) }
+
+	r.PullURL = scrub(r.PullURL)
+	for _, pr := range r.Reviews {
+		pr.Body = scrub(pr.Body)
+	}
+	for _, rc := range r.Comments {
+		rc.Path = scrub(rc.Path)
+		rc.DiffHunk = scrub(rc.DiffHunk)
+		rc.Body = scrub(rc.Body)
+	}
+}
+
+// Diff scrubs a hidden candidate's identity from a diff of their repo.
+func (b *Blind) Diff(userID ID, d *SubmissionDiff) {
+	if !b.Hides(userID) {
+		return
+	}
+
+	for _, f := range d.Files {
+		f.Path = b.Text(userID, f.Path)
+		f.Patch = b.Text(userID, f.Patch)
+	}
+}
+
+// IdentityUseCases ...
+type IdentityUseCases interface {
+	// Reveal shows a candidate's identity to reviewers in blind review
+	// mode. Only admins can reveal identities, and every reveal is
+	// audit-logged.
+	Reveal(ctx context.Context, userID ID) (*User, error)
+	// Blind returns what the viewer must not see of the candidates'
+	// identities, or nil if they may see everything.
+	Blind(ctx context.Context, viewer *User, candidateIDs ...ID) (*Blind, error)
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/pkg/errors"
@@ -9,6 +10,7 @@ import (
 
 // Job ...
 type Job struct {
+	AccountID     ID         `json:"account_id" db:"account_id"`
 	ID            ID         `json:"id" db:"id"`
 	Name          string     `json:"name" db:"name"`
 	Type          JobType    `json:"type" db:"type"`
@@ -16,6 +18,11 @@ type Job struct {
 	Details       JobDetails `json:"details" db:"details"`
 	ScheduledDate *time.Time `json:"scheduled_at" db:"scheduled_at"`
 	RunAt         time.Time  `json:"run_at" db:"run_at"`
+	LockedBy      string     `json:"locked_by" db:"locked_by"`
+	LockedUntil   *time.Time `json:"locked_until" db:"locked_until"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	MaxAttempts   int        `json:"max_attempts" db:"max_attempts"`
+	LastError     string     `json:"last_error" db:"last_error"`
 	CompletedAt   *time.Time `json:"completed_at" db:"completed_at"`
 	FailedAt      *time.Time `json:"failed_at" db:"failed_at"`
 	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
@@ -23,32 +30,96 @@ type Job struct {
 }
 
 // NewJob ...
-func NewJob(name string, t JobType, d JobDetails) (*Job, error) {
+func NewJob(name string, p JobPayload) (*Job, error) {
 	if name == "" {
 		return nil, errors.Errorf("missing name arg")
 	}
-	if t == "" {
-		return nil, errors.Errorf("missing name job type")
+	if p == nil {
+		return nil, errors.Errorf("missing payload arg")
 	}
-	if d.Something == "" {
-		return nil, errors.Errorf("missing name job details")
+	if p.JobType() == "" {
+		return nil, errors.Errorf("missing job type")
 	}
 
+	err := p.Validate()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s job payload", p.JobType())
+	}
+
+	d, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not marshal %s job payload", p.JobType())
+	}
+
+	now := time.Now()
+
 	j := &Job{
-		ID:        NewID(),
-		Name:      name,
-		Type:      t,
-		Status:    StatusNew,
-		Details:   d,
-		CreatedAt: time.Now(),
+		ID:          NewID(),
+		Name:        name,
+		Type:        p.JobType(),
+		Status:      StatusNew,
+		Details:     d,
+		RunAt:       now,
+		MaxAttempts: DefaultJobMaxAttempts,
+		CreatedAt:   now,
 	}
 
 	return j, nil
 }
 
-// JobDetails ...
-type JobDetails struct {
-	Something string `json:"something"`
+// DefaultJobMaxAttempts is the number of times a job is run before
+// it's moved to the dead-letter state, unless configured otherwise.
+const DefaultJobMaxAttempts = 5
+
+// Schedule makes the job run at the given time instead of right away.
+func (j *Job) Schedule(runAt time.Time) {
+	j.Status = StatusScheduled
+	j.RunAt = runAt
+	j.ScheduledDate = &runAt
+}
+
+// Decode unmarshals the job's payload into p, which must be a pointer
+// to the payload type registered for the job's type.
+func (j *Job) Decode(p JobPayload) error {
+	if p.JobType() != j.Type {
+		return errors.Errorf("cannot decode %s job %s into %s payload", j.Type, j.ID, p.JobType())
+	}
+
+	err := json.Unmarshal(j.Details, p)
+	if err != nil {
+		return errors.Wrapf(err, "could not decode payload of %s job %s", j.Type, j.ID)
+	}
+
+	err = p.Validate()
+	if err != nil {
+		return errors.Wrapf(err, "invalid payload in %s job %s", j.Type, j.ID)
+	}
+
+	return nil
+}
+
+// JobDetails holds a job's payload as raw JSON. It's decoded into the
+// payload type registered for the job's type when the job is run.
+type JobDetails []byte
+
+// MarshalJSON ...
+func (d JobDetails) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+// UnmarshalJSON ...
+func (d *JobDetails) UnmarshalJSON(b []byte) error {
+	*d = append((*d)[0:0], b...)
+	return nil
+}
+
+// JobPayload is implemented by the payload of each job type.
+type JobPayload interface {
+	JobType() JobType
+	Validate() error
 }
 
 // JobType ...
@@ -61,25 +132,75 @@ const (
 
 // JobDAO ...
 type JobDAO interface {
-	Create(ctx context.Context, u *Job) error
+	Create(ctx context.Context, j *Job) error
 	Get(ctx context.Context, id ID) (*Job, error)
 	GetAll(ctx context.Context, ids []ID) ([]*Job, error)
 	Update(ctx context.Context, id ID, updates []Field) (*Job, error)
+	// Claim locks up to limit due jobs of the given types for the given
+	// worker and marks them as running. Jobs that are still running when
+	// their lock expires, e.g. because their worker died, can be claimed
+	// again.
+	Claim(ctx context.Context, workerID string, types []JobType, limit int, lockFor time.Duration) ([]*Job, error)
+	// Release unlocks a job claimed by the given worker and applies
+	// the given updates. It fails if the worker no longer holds the lock.
+	Release(ctx context.Context, id ID, workerID string, updates []Field) (*Job, error)
+	List(ctx context.Context, accountID ID, status Status) ([]*Job, int, error)
+	// Requeue resets a dead job's attempts and makes it due right away.
+	Requeue(ctx context.Context, accountID, id ID) (*Job, error)
 }
 
 // JobUseCases ...
 type JobUseCases interface {
-	Enqueue(ctx context.Context, id ID, a EnqueueJobArgs) (*Job, error)
-	Update(ctx context.Context, id ID, a UpdateJobArgs) (*Job, error)
+	Enqueue(ctx context.Context, a EnqueueJobArgs) (*Job, error)
+	ListDead(ctx context.Context) (*ListJobsResult, error)
+	Retry(ctx context.Context, id ID) (*Job, error)
 }
 
 // EnqueueJobArgs ...
 type EnqueueJobArgs struct {
-	Something string
+	// ID is optional. Enqueueing a job with the ID of a job that's
+	// already been enqueued does nothing.
+	ID          ID
+	AccountID   ID
+	Name        string
+	Payload     JobPayload
+	RunAt       *time.Time
+	MaxAttempts int
+}
+
+// ListJobsResult ...
+type ListJobsResult struct {
+	Jobs  []*Job
+	Total int
+}
+
+// JobHandler runs a single job with its decoded payload. Returning an
+// error fails the job, which is retried unless the error is permanent.
+type JobHandler func(ctx context.Context, j *Job, p JobPayload) error
+
+// PermanentError marks a job failure that retrying won't fix.
+type PermanentError struct {
+	Err error
+}
+
+// Error ...
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap ...
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so that the job failing with it is moved to the
+// dead-letter state right away instead of being retried.
+func Permanent(err error) error {
+	return &PermanentError{err}
 }
 
-// UpdateJobArgs ...
-type UpdateJobArgs struct {
-	Name string
-	Logo string
+// IsPermanent ...
+func IsPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
 }
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PasswordReset is a single use, time-limited token that lets a user
+// who forgot their password set a new one.
+type PasswordReset struct {
+	AccountID ID         `json:"account_id" db:"account_id"`
+	ID        ID         `json:"id" db:"id"`
+	UserID    ID         `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	// Token is only known when the reset is requested. Only its hash
+	// is stored.
+	Token string `json:"-" db:"-"`
+}
+
+// NewPasswordReset ...
+func NewPasswordReset(u *User, token, tokenHash string, expires time.Duration) *PasswordReset {
+	now := time.Now()
+
+	return &PasswordReset{
+		AccountID: u.AccountID,
+		ID:        NewID(),
+		UserID:    u.ID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expires),
+		Token:     token,
+	}
+}
+
+// Expired ...
+func (r *PasswordReset) Expired(now time.Time) bool {
+	return !r.ExpiresAt.After(now)
+}
+
+// ErrPasswordResetInvalid is returned when a password reset token
+// doesn't exist, has expired or has already been used.
+var ErrPasswordResetInvalid = errors.New("reset token invalid or expired")
+
+// PasswordResetDAO ...
+type PasswordResetDAO interface {
+	Create(ctx context.Context, r *PasswordReset) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*PasswordReset, error)
+	// Use marks an unused password reset as used. It returns false if
+	// the reset has already been used.
+	Use(ctx context.Context, tokenHash string, at time.Time) (bool, error)
+	// DeleteByUser deletes all of a user's password resets.
+	DeleteByUser(ctx context.Context, accountID, userID ID) error
+}
+
+// RequestPasswordResetArgs ...
+type RequestPasswordResetArgs struct {
+	AccountCode string
+	Email       string
+}
+
+// ResetPasswordArgs ...
+type ResetPasswordArgs struct {
+	Token    string
+	Password string
+}
+
+const (
+	// JobTypeSendPasswordReset ...
+	JobTypeSendPasswordReset JobType = "send_password_reset"
+)
+
+// SendPasswordResetPayload ...
+type SendPasswordResetPayload struct {
+	AccountID ID     `json:"account_id"`
+	Email     string `json:"email"`
+}
+
+// JobType ...
+func (p *SendPasswordResetPayload) JobType() JobType {
+	return JobTypeSendPasswordReset
+}
+
+// Validate ...
+func (p *SendPasswordResetPayload) Validate() error {
+	if p.AccountID == "" || p.Email == "" {
+		return errors.Errorf("missing account id or email")
+	}
+	return nil
+}
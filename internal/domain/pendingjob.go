@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PendingJob is a job that a change to a challenge needs, e.g. a repo
+// to provision once the challenge has started. It's saved on the
+// challenge along with the change, and enqueued once the change has
+// been committed, so that a failure in between can't lose the job.
+type PendingJob struct {
+	// ID is the ID of the job once it's enqueued, which keeps a job
+	// from being enqueued twice.
+	ID     ID         `json:"id"`
+	Type   JobType    `json:"type"`
+	TaskID ID         `json:"task_id,omitempty"`
+	RunAt  *time.Time `json:"run_at,omitempty"`
+}
+
+// AddPendingJob adds a job that must be enqueued once the challenge
+// has been saved.
+func (c *Challenge) AddPendingJob(t JobType, taskID ID, runAt *time.Time) {
+	c.Details.PendingJobs = append(c.Details.PendingJobs, &PendingJob{
+		ID:     NewID(),
+		Type:   t,
+		TaskID: taskID,
+		RunAt:  runAt,
+	})
+}
+
+// RemovePendingJobs removes jobs that have been enqueued.
+func (c *Challenge) RemovePendingJobs(ids map[ID]bool) {
+	var left []*PendingJob
+	for _, pj := range c.Details.PendingJobs {
+		if !ids[pj.ID] {
+			left = append(left, pj)
+		}
+	}
+	c.Details.PendingJobs = left
+}
+
+// PendingJobArgs returns the args to enqueue one of the challenge's
+// pending jobs with.
+func (c *Challenge) PendingJobArgs(pj *PendingJob) (EnqueueJobArgs, error) {
+	ref := TaskRef{AccountID: c.AccountID, ChallengeID: c.ID, TaskID: pj.TaskID}
+
+	a := EnqueueJobArgs{
+		ID:        pj.ID,
+		AccountID: c.AccountID,
+		RunAt:     pj.RunAt,
+	}
+
+	switch pj.Type {
+	case JobTypeProvisionRepo:
+		a.Name = "provision repo"
+		a.Payload = &ProvisionRepoPayload{TaskRef: ref}
+	case JobTypeArchiveRepo:
+		a.Name = "archive repo"
+		a.Payload = &ArchiveRepoPayload{TaskRef: ref}
+	case JobTypeDeleteRepo:
+		a.Name = "delete repo"
+		a.Payload = &DeleteRepoPayload{TaskRef: ref}
+	case JobTypeCollectReview:
+		a.Name = "collect review"
+		a.Payload = &CollectReviewPayload{TaskRef: ref}
+	case JobTypeRunTests:
+		a.Name = "run tests"
+		a.Payload = &RunTestsPayload{TaskRef: ref}
+	case JobTypeAnalyzeRefactor:
+		a.Name = "analyze refactor"
+		a.Payload = &AnalyzeRefactorPayload{TaskRef: ref}
+	case JobTypeCheckSimilarity:
+		a.Name = "check similarity"
+		a.Payload = &CheckSimilarityPayload{TaskRef: ref}
+	case JobTypeAnalyzeTimeline:
+		a.Name = "analyze timeline"
+		a.Payload = &AnalyzeTimelinePayload{TaskRef: ref}
+	case JobTypeNotifyChallengeExpired:
+		a.Name = "notify challenge expired"
+		a.Payload = &ChallengeExpiredPayload{AccountID: c.AccountID, ChallengeID: c.ID}
+	default:
+		return a, errors.Errorf("unknown pending job type '%s'", pj.Type)
+	}
+
+	return a, nil
+}
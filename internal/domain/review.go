@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"time"
+)
+
+// CodeReview is a candidate's review of the pull request seeded in a
+// code review task, as collected from Github.
+type CodeReview struct {
+	PullNumber  int              `json:"pull_number"`
+	PullURL     string           `json:"pull_url"`
+	Reviews     []*PullReview    `json:"reviews"`
+	Comments    []*ReviewComment `json:"comments"`
+	CollectedAt time.Time        `json:"collected_at"`
+	// Score is set when the task has expected findings. It's never shown
+	// to candidates.
+	Score *ReviewScore `json:"score"`
+}
+
+// PullReview is a review submitted on a pull request, e.g. an approval
+// or a request for changes along with a summary.
+type PullReview struct {
+	ID          int64      `json:"id"`
+	State       string     `json:"state"`
+	Body        string     `json:"body"`
+	CommitID    string     `json:"commit_id"`
+	SubmittedAt *time.Time `json:"submitted_at"`
+}
+
+// ReviewComment is a comment on one or more lines in a pull request's
+// diff. StartLine is set for comments on a range of lines.
+type ReviewComment struct {
+	ID          int64     `json:"id"`
+	ReviewID    int64     `json:"review_id"`
+	InReplyToID int64     `json:"in_reply_to_id"`
+	Path        string    `json:"path"`
+	Line        int       `json:"line"`
+	StartLine   int       `json:"start_line"`
+	Side        string    `json:"side"`
+	DiffHunk    string    `json:"diff_hunk"`
+	CommitID    string    `json:"commit_id"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const (
+	// JobTypeCollectReview ...
+	JobTypeCollectReview JobType = "collect_review"
+)
+
+// CollectReviewPayload ...
+type CollectReviewPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *CollectReviewPayload) JobType() JobType {
+	return JobTypeCollectReview
+}
+
+// FindingCategory ...
+type FindingCategory string
+
+const (
+	// FindingCategoryBug ...
+	FindingCategoryBug FindingCategory = "bug"
+	// FindingCategorySecurity ...
+	FindingCategorySecurity FindingCategory = "security"
+	// FindingCategoryPerformance ...
+	FindingCategoryPerformance FindingCategory = "performance"
+	// FindingCategoryDesign ...
+	FindingCategoryDesign FindingCategory = "design"
+	// FindingCategoryStyle ...
+	FindingCategoryStyle FindingCategory = "style"
+	// FindingCategoryTesting ...
+	FindingCategoryTesting FindingCategory = "testing"
+)
+
+// ExpectedFinding is an issue planted in the changes under review that
+// candidates are expected to point out. A finding without a line range
+// covers the whole file.
+type ExpectedFinding struct {
+	Path        string          `json:"path"`
+	StartLine   int             `json:"start_line"`
+	EndLine     int             `json:"end_line"`
+	Category    FindingCategory `json:"category"`
+	Weight      float64         `json:"weight"`
+	Description string          `json:"description"`
+}
+
+// findingLineSlack is how many lines outside a finding's line range a
+// comment can be and still count as pointing out the finding.
+const findingLineSlack = 2
+
+// Matches returns true if the comment points out the finding, i.e. it's
+// on the same file and its lines overlap the finding's line range.
+func (f *ExpectedFinding) Matches(c *ReviewComment) bool {
+	if c.Path != f.Path {
+		return false
+	}
+	if f.StartLine == 0 && f.EndLine == 0 {
+		return true
+	}
+	if c.Line == 0 {
+		return false
+	}
+
+	from, to := c.StartLine, c.Line
+	if from == 0 || from > to {
+		from = to
+	}
+
+	end := f.EndLine
+	if end < f.StartLine {
+		end = f.StartLine
+	}
+
+	return from <= end+findingLineSlack && to >= f.StartLine-findingLineSlack
+}
+
+// ReviewScore is how well a candidate's review comments cover the
+// expected findings of a code review task.
+type ReviewScore struct {
+	// Recall is the share of expected findings pointed out.
+	Recall float64 `json:"recall"`
+	// Precision is the share of review comments that point out an
+	// expected finding. Replies aren't counted.
+	Precision float64 `json:"precision"`
+	// Score is the weighted share of expected findings pointed out.
+	Score    float64          `json:"score"`
+	Findings []*FindingResult `json:"findings"`
+	ScoredAt time.Time        `json:"scored_at"`
+}
+
+// FindingResult ...
+type FindingResult struct {
+	Finding    *ExpectedFinding `json:"finding"`
+	Found      bool             `json:"found"`
+	CommentIDs []int64          `json:"comment_ids"`
+}
+
+// ScoreReview matches a review's comments to the expected findings and
+// scores the review. Findings without a weight have a weight of 1.
+// It returns nil if there are no expected findings.
+func ScoreReview(findings []*ExpectedFinding, r *CodeReview, now time.Time) *ReviewScore {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	s := &ReviewScore{ScoredAt: now}
+
+	matched := make(map[int64]bool)
+	var found int
+	var total, weight float64
+
+	for _, f := range findings {
+		fr := &FindingResult{Finding: f, CommentIDs: []int64{}}
+
+		for _, c := range r.Comments {
+			if c.InReplyToID == 0 && f.Matches(c) {
+				fr.CommentIDs = append(fr.CommentIDs, c.ID)
+				matched[c.ID] = true
+			}
+		}
+
+		w := f.Weight
+		if w <= 0 {
+			w = 1
+		}
+		total += w
+
+		if len(fr.CommentIDs) > 0 {
+			fr.Found = true
+			found++
+			weight += w
+		}
+
+		s.Findings = append(s.Findings, fr)
+	}
+
+	var comments int
+	for _, c := range r.Comments {
+		if c.InReplyToID == 0 {
+			comments++
+		}
+	}
+
+	s.Recall = float64(found) / float64(len(findings))
+	s.Score = weight / total
+	if comments > 0 {
+		s.Precision = float64(len(matched)) / float64(comments)
+	}
+
+	return s
+}
@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedFindingMatches(t *testing.T) {
+	lines := &ExpectedFinding{Path: "a.go", StartLine: 10, EndLine: 12}
+
+	tests := []struct {
+		name    string
+		finding *ExpectedFinding
+		comment *ReviewComment
+		want    bool
+	}{
+		{name: "other file", finding: lines, comment: &ReviewComment{Path: "b.go", Line: 11}},
+		{name: "inside", finding: lines, comment: &ReviewComment{Path: "a.go", Line: 11}, want: true},
+		{name: "just before", finding: lines, comment: &ReviewComment{Path: "a.go", Line: 8}, want: true},
+		{name: "too far before", finding: lines, comment: &ReviewComment{Path: "a.go", Line: 7}},
+		{name: "just after", finding: lines, comment: &ReviewComment{Path: "a.go", Line: 14}, want: true},
+		{name: "too far after", finding: lines, comment: &ReviewComment{Path: "a.go", Line: 15}},
+		{name: "range overlapping", finding: lines, comment: &ReviewComment{Path: "a.go", StartLine: 1, Line: 9}, want: true},
+		{name: "range around", finding: lines, comment: &ReviewComment{Path: "a.go", StartLine: 1, Line: 30}, want: true},
+		{name: "range before", finding: lines, comment: &ReviewComment{Path: "a.go", StartLine: 1, Line: 7}},
+		{name: "file comment", finding: lines, comment: &ReviewComment{Path: "a.go"}},
+		{name: "whole file", finding: &ExpectedFinding{Path: "a.go"}, comment: &ReviewComment{Path: "a.go"}, want: true},
+		{name: "one line", finding: &ExpectedFinding{Path: "a.go", StartLine: 10}, comment: &ReviewComment{Path: "a.go", Line: 12}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.finding.Matches(tt.comment))
+		})
+	}
+}
+
+func TestScoreReview(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	findings := []*ExpectedFinding{
+		{Path: "a.go", StartLine: 10, EndLine: 12, Weight: 3},
+		{Path: "b.go", StartLine: 5},
+		{Path: "c.go", Weight: 1},
+	}
+
+	tests := []struct {
+		name      string
+		comments  []*ReviewComment
+		recall    float64
+		precision float64
+		score     float64
+		// found holds the IDs of the comments that point out each
+		// finding.
+		found [][]int64
+	}{
+		{
+			name:  "no comments",
+			found: [][]int64{{}, {}, {}},
+		},
+		{
+			name: "all findings pointed out",
+			comments: []*ReviewComment{
+				{ID: 1, Path: "a.go", Line: 11},
+				{ID: 2, Path: "b.go", Line: 6},
+				{ID: 3, Path: "c.go", Line: 100},
+			},
+			recall:    1,
+			precision: 1,
+			score:     1,
+			found:     [][]int64{{1}, {2}, {3}},
+		},
+		{
+			name: "some findings pointed out",
+			comments: []*ReviewComment{
+				{ID: 1, Path: "a.go", Line: 14},
+				{ID: 2, Path: "b.go", Line: 20},
+				{ID: 3, Path: "c.go", Line: 1, InReplyToID: 1},
+				{ID: 4, Path: "a.go", Line: 9},
+			},
+			recall:    1.0 / 3,
+			precision: 2.0 / 3,
+			score:     3.0 / 5,
+			found:     [][]int64{{1, 4}, {}, {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			s := ScoreReview(findings, &CodeReview{Comments: tt.comments}, now)
+			r.NotNil(s)
+			r.InDelta(tt.recall, s.Recall, 1e-9)
+			r.InDelta(tt.precision, s.Precision, 1e-9)
+			r.InDelta(tt.score, s.Score, 1e-9)
+			r.Equal(now, s.ScoredAt)
+
+			r.Len(s.Findings, len(findings))
+			for i, fr := range s.Findings {
+				r.Equal(findings[i], fr.Finding)
+				r.Equal(tt.found[i], fr.CommentIDs)
+				r.Equal(len(tt.found[i]) > 0, fr.Found)
+			}
+		})
+	}
+
+	// Reviews of tasks without expected findings aren't scored.
+	require.Nil(t, ScoreReview(nil, &CodeReview{}, now))
+}
@@ -0,0 +1,255 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinScore is the lowest score on a rubric criterion.
+	MinScore = 1
+	// MaxScore is the highest score on a rubric criterion.
+	MaxScore = 5
+)
+
+// Rubric is the set of weighted criteria that reviewers score a
+// candidate's work on a task against.
+type Rubric struct {
+	Criteria []*RubricCriterion `json:"criteria"`
+}
+
+// RubricCriterion ...
+type RubricCriterion struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight"`
+	// Levels describe what each score from MinScore to MaxScore means,
+	// lowest first.
+	Levels []string `json:"levels"`
+}
+
+// Validate ...
+func (r *Rubric) Validate() error {
+	if len(r.Criteria) == 0 {
+		return errors.New("rubric has no criteria")
+	}
+
+	names := make(map[string]bool)
+	for _, c := range r.Criteria {
+		if c.Name == "" {
+			return errors.New("rubric criterion has no name")
+		}
+		if names[c.Name] {
+			return errors.Errorf("rubric has more than one criterion named '%s'", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Weight <= 0 {
+			return errors.Errorf("rubric criterion '%s' must have a positive weight", c.Name)
+		}
+		if len(c.Levels) != 0 && len(c.Levels) != MaxScore-MinScore+1 {
+			return errors.Errorf("rubric criterion '%s' must describe all %d levels or none", c.Name, MaxScore-MinScore+1)
+		}
+	}
+
+	return nil
+}
+
+// Overall returns the weighted average of scores given on the rubric's
+// criteria. Every criterion must be scored exactly once.
+func (r *Rubric) Overall(scores []*CriterionScore) (float64, error) {
+	byName := make(map[string]*CriterionScore)
+	for _, s := range scores {
+		if byName[s.Criterion] != nil {
+			return 0, errors.Errorf("criterion '%s' is scored more than once", s.Criterion)
+		}
+		if s.Score < MinScore || s.Score > MaxScore {
+			return 0, errors.Errorf("score %d on criterion '%s' is not between %d and %d", s.Score, s.Criterion, MinScore, MaxScore)
+		}
+		byName[s.Criterion] = s
+	}
+
+	var total, weights float64
+	for _, c := range r.Criteria {
+		s := byName[c.Name]
+		if s == nil {
+			return 0, errors.Errorf("criterion '%s' is not scored", c.Name)
+		}
+		delete(byName, c.Name)
+
+		total += c.Weight * float64(s.Score)
+		weights += c.Weight
+	}
+
+	for name := range byName {
+		return 0, errors.Errorf("rubric has no criterion named '%s'", name)
+	}
+
+	return total / weights, nil
+}
+
+// Scorecard is a reviewer's scores on a candidate's work on a task,
+// given against the task's rubric.
+type Scorecard struct {
+	AccountID   ID               `json:"account_id" db:"account_id"`
+	ChallengeID ID               `json:"challenge_id" db:"challenge_id"`
+	TaskID      ID               `json:"task_id" db:"task_id"`
+	ReviewerID  ID               `json:"reviewer_id" db:"reviewer_id"`
+	Details     ScorecardDetails `json:"details" db:"details"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt   *time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// ScorecardDetails ...
+type ScorecardDetails struct {
+	// Rubric is the rubric the scores were given against, kept so that
+	// later changes to the task's rubric don't change past scores.
+	Rubric  *Rubric           `json:"rubric"`
+	Scores  []*CriterionScore `json:"scores"`
+	Comment string            `json:"comment"`
+	// Overall is the weighted average of the scores.
+	Overall float64 `json:"overall"`
+	// History holds earlier versions of the scorecard, oldest first.
+	History []*ScorecardRevision `json:"history"`
+}
+
+// CriterionScore ...
+type CriterionScore struct {
+	Criterion string `json:"criterion"`
+	Score     int    `json:"score"`
+	Comment   string `json:"comment"`
+}
+
+// ScorecardRevision is an earlier version of a scorecard.
+type ScorecardRevision struct {
+	Rubric   *Rubric           `json:"rubric"`
+	Scores   []*CriterionScore `json:"scores"`
+	Comment  string            `json:"comment"`
+	Overall  float64           `json:"overall"`
+	EditedAt time.Time         `json:"edited_at"`
+}
+
+// ChallengeScorecards are all scorecards given on a challenge's tasks.
+type ChallengeScorecards struct {
+	Scorecards []*Scorecard `json:"scorecards"`
+	Tasks      []*TaskScore `json:"tasks"`
+	// Overall is the average overall score of the scored tasks.
+	Overall float64 `json:"overall"`
+}
+
+// TaskScore ...
+type TaskScore struct {
+	TaskID    ID  `json:"task_id"`
+	Reviewers int `json:"reviewers"`
+	// Blind is set if the current user hasn't scored the task yet, in
+	// which case other reviewers' scores are hidden.
+	Blind bool `json:"blind"`
+	// Overall is the average overall score given by the reviewers.
+	Overall  float64               `json:"overall"`
+	Criteria []*CriterionAggregate `json:"criteria"`
+}
+
+// CriterionAggregate sums up the scores reviewers gave on a criterion.
+type CriterionAggregate struct {
+	Criterion string  `json:"criterion"`
+	Mean      float64 `json:"mean"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+}
+
+const (
+	// BiasThreshold is how far above or below other reviewers a
+	// reviewer's overall scores must be on average to count as lenient
+	// or harsh.
+	BiasThreshold = 0.5
+	// MinBiasSamples is the number of tasks a reviewer must have scored
+	// alongside other reviewers before they're called lenient or harsh.
+	MinBiasSamples = 3
+)
+
+// CalibrationReport shows how well an account's reviewers agree when
+// they score the same candidates' work.
+type CalibrationReport struct {
+	Scorecards int `json:"scorecards"`
+	// Samples is the number of challenge tasks scored by more than one
+	// reviewer, which are the only ones that say anything about
+	// agreement.
+	Samples int `json:"samples"`
+	// Alpha is Krippendorff's alpha of the overall scores, or nil if
+	// there's too little data.
+	Alpha     *float64               `json:"alpha"`
+	Criteria  []*CriterionAgreement  `json:"criteria"`
+	Reviewers []*ReviewerCalibration `json:"reviewers"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// CriterionAgreement ...
+type CriterionAgreement struct {
+	Criterion string `json:"criterion"`
+	Samples   int    `json:"samples"`
+	// Alpha is Krippendorff's alpha of the scores on the criterion, or
+	// nil if there's too little data.
+	Alpha *float64 `json:"alpha"`
+	// Variance is the average variance of the scores given on the same
+	// challenge task.
+	Variance float64 `json:"variance"`
+}
+
+// ReviewerCalibration ...
+type ReviewerCalibration struct {
+	ReviewerID ID  `json:"reviewer_id"`
+	Scorecards int `json:"scorecards"`
+	// Samples is the number of tasks the reviewer scored alongside
+	// other reviewers.
+	Samples int `json:"samples"`
+	// Bias is how much higher the reviewer's overall scores are than
+	// other reviewers' scores on the same tasks, on average.
+	Bias     float64          `json:"bias"`
+	Tendency ReviewerTendency `json:"tendency"`
+}
+
+// ReviewerTendency ...
+type ReviewerTendency string
+
+const (
+	// TendencyHarsh ...
+	TendencyHarsh ReviewerTendency = "harsh"
+	// TendencyLenient ...
+	TendencyLenient ReviewerTendency = "lenient"
+	// TendencyNeutral ...
+	TendencyNeutral ReviewerTendency = "neutral"
+	// TendencyUnknown means the reviewer hasn't scored enough tasks
+	// alongside other reviewers.
+	TendencyUnknown ReviewerTendency = "unknown"
+)
+
+// SubmitScorecardArgs ...
+type SubmitScorecardArgs struct {
+	Scores  []*CriterionScore
+	Comment string
+}
+
+// ScorecardDAO ...
+type ScorecardDAO interface {
+	// Modify loads a reviewer's scorecard for a challenge task, or a new
+	// empty one, locks it for the duration of the given func and saves
+	// all changes made to it by the func.
+	Modify(ctx context.Context, accountID, challengeID, taskID, reviewerID ID, fn func(s *Scorecard) error) (*Scorecard, error)
+	ListByChallenge(ctx context.Context, accountID, challengeID ID) ([]*Scorecard, error)
+	ListByAccount(ctx context.Context, accountID ID) ([]*Scorecard, error)
+}
+
+// ScorecardUseCases ...
+type ScorecardUseCases interface {
+	// Submit creates or updates the current user's scorecard for a
+	// challenge task.
+	Submit(ctx context.Context, id, taskID ID, a SubmitScorecardArgs) (*Scorecard, error)
+	// List returns all scorecards given on a challenge's tasks. Scoring
+	// is blind: other reviewers' scores on a task are only shown once
+	// the current user has scored it.
+	List(ctx context.Context, id ID) (*ChallengeScorecards, error)
+	// Calibration reports how well the account's reviewers agree.
+	Calibration(ctx context.Context) (*CalibrationReport, error)
+}
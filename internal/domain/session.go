@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SessionTouchInterval is how often a session's last seen time is
+// updated while it's in use.
+const SessionTouchInterval = time.Minute
+
+// UserSession is a user's login on a device. A user can have any
+// number of sessions, each with its own short-lived access token and
+// family of single use refresh tokens.
+type UserSession struct {
+	AccountID ID `json:"account_id" db:"account_id"`
+	ID        ID `json:"id" db:"id"`
+	UserID    ID `json:"user_id" db:"user_id"`
+	// Token is the session's access token, which is only known when
+	// it's issued. Only its hash is stored.
+	Token      string    `json:"-" db:"-"`
+	TokenHash  string    `json:"-" db:"token_hash"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	// ExpiresAt is when the current access token expires.
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+	// Current is set on the session making the request.
+	Current bool `json:"current" db:"-"`
+}
+
+// ClientInfo describes the device a user logs in from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// NewUserSession returns a new session without an access token.
+func NewUserSession(u *User, ci ClientInfo) *UserSession {
+	now := time.Now()
+
+	return &UserSession{
+		AccountID:  u.AccountID,
+		ID:         NewID(),
+		UserID:     u.ID,
+		UserAgent:  ci.UserAgent,
+		IP:         ci.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
+
+// SetAccessToken gives the session a new access token that expires
+// after the given duration, but never after the session's absolute
+// timeout. A zero timeout means no limit.
+func (s *UserSession) SetAccessToken(token, tokenHash string, now time.Time, expires, absolute time.Duration) {
+	s.Token = token
+	s.TokenHash = tokenHash
+	s.LastSeenAt = now
+	s.ExpiresAt = now.Add(expires)
+
+	if absolute > 0 {
+		if end := s.CreatedAt.Add(absolute); end.Before(s.ExpiresAt) {
+			s.ExpiresAt = end
+		}
+	}
+}
+
+// Expired returns true if the session's access token has expired.
+func (s *UserSession) Expired(now time.Time) bool {
+	return !s.ExpiresAt.After(now)
+}
+
+// Ended returns true if the session has been idle for longer than the
+// idle timeout or is older than the absolute timeout, i.e. it can no
+// longer be refreshed. A zero timeout means no limit.
+func (s *UserSession) Ended(now time.Time, idle, absolute time.Duration) bool {
+	if idle > 0 && now.Sub(s.LastSeenAt) > idle {
+		return true
+	}
+	if absolute > 0 && now.Sub(s.CreatedAt) > absolute {
+		return true
+	}
+	return false
+}
+
+// RefreshToken is a single use token that gets a session a new access
+// token and a new refresh token. All refresh tokens of a session form
+// a family: using one that's already been used means it has leaked,
+// and revokes the whole session.
+type RefreshToken struct {
+	TokenHash string     `json:"-" db:"token_hash"`
+	AccountID ID         `json:"account_id" db:"account_id"`
+	UserID    ID         `json:"user_id" db:"user_id"`
+	SessionID ID         `json:"session_id" db:"session_id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UsedAt    *time.Time `json:"used_at" db:"used_at"`
+	// Token is only known when the refresh token is issued.
+	Token string `json:"-" db:"-"`
+}
+
+// NewRefreshToken ...
+func NewRefreshToken(s *UserSession, token, tokenHash string) *RefreshToken {
+	return &RefreshToken{
+		TokenHash: tokenHash,
+		AccountID: s.AccountID,
+		UserID:    s.UserID,
+		SessionID: s.ID,
+		CreatedAt: time.Now(),
+		Token:     token,
+	}
+}
+
+var (
+	// ErrRefreshTokenInvalid is returned when a refresh token doesn't
+	// exist or its session has been revoked.
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	// ErrRefreshTokenReused is returned when a refresh token is used
+	// more than once. Its session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	// ErrSessionEnded is returned when refreshing a session that's been
+	// idle for too long or is too old.
+	ErrSessionEnded = errors.New("session ended")
+)
+
+// SessionDAO ...
+type SessionDAO interface {
+	Create(ctx context.Context, s *UserSession) error
+	Get(ctx context.Context, accountID, id ID) (*UserSession, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*UserSession, error)
+	Update(ctx context.Context, accountID, id ID, updates []Field) error
+	// Touch updates a session's last seen time.
+	Touch(ctx context.Context, accountID, id ID, at time.Time) error
+	ListByUser(ctx context.Context, accountID, userID ID) ([]*UserSession, error)
+	// Delete deletes one of a user's sessions.
+	Delete(ctx context.Context, accountID, userID, id ID) error
+	// DeleteByUser deletes all of a user's sessions except the given
+	// ones and returns the number of sessions deleted.
+	DeleteByUser(ctx context.Context, accountID, userID ID, except ...ID) (int, error)
+	CreateRefreshToken(ctx context.Context, rt *RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// UseRefreshToken marks an unused refresh token as used. It returns
+	// false if the token has already been used.
+	UseRefreshToken(ctx context.Context, tokenHash string, at time.Time) (bool, error)
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Fingerprint holds the fingerprints of a candidate's code in a task,
+// which are compared to other candidates' code in the same task to find
+// shared solutions.
+type Fingerprint struct {
+	AccountID   ID                 `json:"account_id" db:"account_id"`
+	TaskID      ID                 `json:"task_id" db:"task_id"`
+	ChallengeID ID                 `json:"challenge_id" db:"challenge_id"`
+	UserID      ID                 `json:"user_id" db:"user_id"`
+	Commit      string             `json:"commit" db:"head_commit"`
+	Details     FingerprintDetails `json:"details" db:"details"`
+	CreatedAt   time.Time          `json:"created_at" db:"created_at"`
+}
+
+// FingerprintDetails ...
+type FingerprintDetails struct {
+	Marks []*FingerprintMark `json:"marks"`
+}
+
+// FingerprintMark is the hash of a sequence of tokens in a file.
+type FingerprintMark struct {
+	Hash      uint64 `json:"hash"`
+	File      string `json:"file"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// SimilarityReport lists other candidates' code in the same task that's
+// suspiciously similar to a candidate's code.
+type SimilarityReport struct {
+	// Commit is the commit in the candidate's repo that was checked.
+	Commit    string             `json:"commit"`
+	Matches   []*SimilarityMatch `json:"matches"`
+	CheckedAt time.Time          `json:"checked_at"`
+}
+
+// SimilarityMatch is another candidate's code in the same task that's
+// more similar than the threshold.
+type SimilarityMatch struct {
+	ChallengeID ID     `json:"challenge_id"`
+	UserID      ID     `json:"user_id"`
+	Commit      string `json:"commit"`
+	// Score is the share of fingerprints in the smaller of the two
+	// submissions that also occur in the other. Code from the starter
+	// repo doesn't count.
+	Score float64 `json:"score"`
+	// Regions are the largest matching regions.
+	Regions []*MatchRegion `json:"regions"`
+	FoundAt time.Time      `json:"found_at"`
+}
+
+// MatchRegion is a range of lines in the candidate's code that matches
+// a range of lines in the other candidate's code.
+type MatchRegion struct {
+	File           string `json:"file"`
+	StartLine      int    `json:"start_line"`
+	EndLine        int    `json:"end_line"`
+	OtherFile      string `json:"other_file"`
+	OtherStartLine int    `json:"other_start_line"`
+	OtherEndLine   int    `json:"other_end_line"`
+}
+
+// SetMatch adds a match to the report, replacing any earlier match with
+// the same challenge, and keeps the matches sorted by score.
+func (r *SimilarityReport) SetMatch(m *SimilarityMatch) {
+	matches := []*SimilarityMatch{m}
+	for _, o := range r.Matches {
+		if o.ChallengeID != m.ChallengeID {
+			matches = append(matches, o)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	r.Matches = matches
+}
+
+// RemoveMatch removes the match with a challenge from the report.
+func (r *SimilarityReport) RemoveMatch(challengeID ID) {
+	matches := []*SimilarityMatch{}
+	for _, o := range r.Matches {
+		if o.ChallengeID != challengeID {
+			matches = append(matches, o)
+		}
+	}
+	r.Matches = matches
+}
+
+const (
+	// JobTypeCheckSimilarity ...
+	JobTypeCheckSimilarity JobType = "check_similarity"
+)
+
+// CheckSimilarityPayload ...
+type CheckSimilarityPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *CheckSimilarityPayload) JobType() JobType {
+	return JobTypeCheckSimilarity
+}
+
+// FingerprintDAO ...
+type FingerprintDAO interface {
+	// Put creates or replaces the fingerprint of a challenge task.
+	Put(ctx context.Context, f *Fingerprint) error
+	// ListByTask lists the fingerprints of all challenges with a task in
+	// an account.
+	ListByTask(ctx context.Context, accountID, taskID ID) ([]*Fingerprint, error)
+}
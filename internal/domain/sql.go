@@ -21,6 +21,21 @@ func (s *AccountProfile) Scan(value interface{}) error {
 	return json.Unmarshal(b, &s)
 }
 
+// Value ...
+func (s AccountSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *AccountSettings) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
+
 // Value ...
 func (s Members) Value() (driver.Value, error) {
 	return json.Marshal(s)
@@ -50,3 +65,97 @@ func (s *UserProfile) Scan(value interface{}) error {
 
 	return json.Unmarshal(b, &s)
 }
+
+// Value ...
+func (s TaskDetails) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *TaskDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
+
+// Value ...
+func (s ChallengeDetails) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *ChallengeDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
+
+// Value ...
+func (s FingerprintDetails) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *FingerprintDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
+
+// Value ...
+func (s ScorecardDetails) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *ScorecardDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
+
+// Value ...
+func (s JobDetails) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return []byte("null"), nil
+	}
+	return []byte(s), nil
+}
+
+// Scan ...
+func (s *JobDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	*s = append((*s)[0:0], b...)
+	return nil
+}
+
+// Value ...
+func (s Scopes) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan ...
+func (s *Scopes) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(b, &s)
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -32,8 +33,14 @@ func NewTask(a NewTaskArgs) (*Task, error) {
 	if a.Name == "" {
 		return nil, errors.Errorf("missing name arg")
 	}
-	if a.Type == "" {
-		return nil, errors.Errorf("missing type arg")
+	if !a.Type.IsValid() {
+		return nil, errors.Errorf("missing or invalid type arg '%s'", a.Type)
+	}
+	if len(a.Details.ExpectedFindings) > 0 && a.Type != TaskTypeCodeReview {
+		return nil, errors.Errorf("only %s tasks can have expected findings", TaskTypeCodeReview)
+	}
+	if a.Details.TestCommand != "" && a.Type == TaskTypeCodeReview {
+		return nil, errors.Errorf("%s tasks cannot have a test command", TaskTypeCodeReview)
 	}
 
 	c := &Task{
@@ -52,6 +59,25 @@ func NewTask(a NewTaskArgs) (*Task, error) {
 type TaskDetails struct {
 	GithubRepoName string        `json:"github_repo_name" db:"github_repo_name"`
 	TimeLimit      time.Duration `json:"time_limit" db:"time_limit"`
+	// ReviewBranch is the branch in the source repo holding the changes
+	// that candidates review in code review tasks. The source repo's
+	// default branch is used as the base of the pull request.
+	ReviewBranch string `json:"review_branch" db:"review_branch"`
+	// ExpectedFindings are the issues planted in the changes under review
+	// in code review tasks. They're never shown to candidates.
+	ExpectedFindings []*ExpectedFinding `json:"expected_findings" db:"expected_findings"`
+	// TestCommand is a shell command that runs hidden tests against the
+	// candidate's code in coding and refactoring tasks, e.g.
+	// `go test -json ./...`. It's never shown to candidates.
+	TestCommand string `json:"test_command" db:"test_command"`
+	// TestReport is the path, relative to the dir in the OUT_DIR env
+	// var, of a JUnit XML report written by the test command, e.g.
+	// `report.xml` for `gotestsum --junitfile $OUT_DIR/report.xml`. The
+	// command's output is parsed as `go test -json` output if not set.
+	TestReport string `json:"test_report" db:"test_report"`
+	// Rubric is what reviewers score the candidate's work against. It's
+	// never shown to candidates.
+	Rubric *Rubric `json:"rubric" db:"rubric"`
 }
 
 // TaskType ...
@@ -66,10 +92,66 @@ const (
 	TaskTypeCoding TaskType = "coding"
 )
 
+// IsValid returns true if the task type is one of the known task types.
+func (t TaskType) IsValid() bool {
+	switch t {
+	case TaskTypeRefactor, TaskTypeCodeReview, TaskTypeCoding:
+		return true
+	}
+	return false
+}
+
 // TaskDAO ...
 type TaskDAO interface {
-	Create(u *Task) error
-	Get(accountID, id ID) (*Task, error)
-	GetAll(accountID ID, ids []ID) ([]*Task, error)
-	Update(accountID ID, id ID, updates []Field) (*Task, error)
+	Create(ctx context.Context, t *Task) error
+	Get(ctx context.Context, accountID, id ID) (*Task, error)
+	GetAll(ctx context.Context, accountID ID, ids []ID) ([]*Task, error)
+	List(ctx context.Context, accountID ID, types []TaskType) ([]*Task, int, error)
+	Update(ctx context.Context, accountID, id ID, updates []Field) (*Task, error)
+	Delete(ctx context.Context, accountID, id ID) error
+}
+
+// TaskUseCases ...
+type TaskUseCases interface {
+	Create(ctx context.Context, a CreateTaskArgs) (*Task, error)
+	Get(ctx context.Context, id ID) (*Task, error)
+	Update(ctx context.Context, id ID, a UpdateTaskArgs) (*Task, error)
+	Delete(ctx context.Context, id ID) error
+	List(ctx context.Context, a ListTasksArgs) (*ListTasksResult, error)
+	// SetRubric replaces a task's rubric, or removes it if nil.
+	SetRubric(ctx context.Context, id ID, r *Rubric) (*Task, error)
+}
+
+// CreateTaskArgs ...
+type CreateTaskArgs struct {
+	Name             string
+	Type             TaskType
+	GithubRepoName   string
+	TimeLimit        time.Duration
+	ReviewBranch     string
+	ExpectedFindings []*ExpectedFinding
+	TestCommand      string
+	TestReport       string
+}
+
+// UpdateTaskArgs ...
+type UpdateTaskArgs struct {
+	Name             string
+	GithubRepoName   string
+	TimeLimit        time.Duration
+	ReviewBranch     string
+	ExpectedFindings []*ExpectedFinding
+	TestCommand      string
+	TestReport       string
+}
+
+// ListTasksArgs ...
+type ListTasksArgs struct {
+	Types []TaskType
+}
+
+// ListTasksResult ...
+type ListTasksResult struct {
+	Tasks []*Task
+	Total int
 }
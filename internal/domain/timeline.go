@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// SessionGap is the longest time between two pushes in the same
+	// work session.
+	SessionGap = 45 * time.Minute
+	// SessionLead is the time assumed to be spent before the first
+	// push in a work session.
+	SessionLead = 15 * time.Minute
+)
+
+// CommitTimeline shows how a candidate spent their time on a task,
+// based on when they pushed commits to their repo.
+type CommitTimeline struct {
+	Commits  []*TimelineCommit `json:"commits"`
+	Sessions []*WorkSession    `json:"sessions"`
+	// ActiveTime is the estimated time spent working on the task, i.e.
+	// the total length of all work sessions.
+	ActiveTime time.Duration `json:"active_time"`
+	// DeadlineAt is the deadline the commits were checked against.
+	DeadlineAt  *time.Time `json:"deadline_at"`
+	LateCommits int        `json:"late_commits"`
+	AnalyzedAt  time.Time  `json:"analyzed_at"`
+}
+
+// TimelineCommit ...
+type TimelineCommit struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
+	Author  string `json:"author"`
+	// CommittedAt is the commit's committer date, which is set by the
+	// candidate's git client. It's only shown, never checked.
+	CommittedAt time.Time `json:"committed_at"`
+	// PushedAt is when Github received the commit. It's nil if it isn't
+	// known.
+	PushedAt *time.Time `json:"pushed_at"`
+	// Late is set if the commit was pushed after the deadline.
+	Late bool `json:"late"`
+}
+
+// WorkSession is a run of pushes less than SessionGap apart.
+type WorkSession struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+	Commits int       `json:"commits"`
+}
+
+// Push is a push to a repo, as recorded by Github.
+type Push struct {
+	At   time.Time
+	Head string
+	// SHAs are some or all of the commits pushed.
+	SHAs []string
+}
+
+// SetPushTimes sets when each commit was pushed, given commits oldest
+// first, i.e. parents before children. Since a commit can't be pushed
+// after its descendants, commits that aren't part of any known push get
+// the push time of their nearest descendant that is, or lastPushAt, the
+// time of the repo's latest push, if there's none.
+func SetPushTimes(commits []*TimelineCommit, pushes []*Push, lastPushAt *time.Time) {
+	pushedAt := make(map[string]time.Time)
+	add := func(sha string, at time.Time) {
+		if t, found := pushedAt[sha]; !found || at.Before(t) {
+			pushedAt[sha] = at
+		}
+	}
+	for _, p := range pushes {
+		add(p.Head, p.At)
+		for _, sha := range p.SHAs {
+			add(sha, p.At)
+		}
+	}
+
+	bound := lastPushAt
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if at, found := pushedAt[c.SHA]; found && (bound == nil || at.Before(*bound)) {
+			bound = &at
+		}
+		c.PushedAt = nil
+		if bound != nil {
+			at := *bound
+			c.PushedAt = &at
+		}
+	}
+}
+
+// NewCommitTimeline sorts commits by push time, groups their pushes into
+// work sessions and flags commits pushed after the deadline, if any.
+// Commits pushed at the same time, or at an unknown time, keep their
+// order.
+func NewCommitTimeline(commits []*TimelineCommit, deadlineAt *time.Time, now time.Time) *CommitTimeline {
+	t := &CommitTimeline{
+		Commits:    commits,
+		Sessions:   []*WorkSession{},
+		DeadlineAt: deadlineAt,
+		AnalyzedAt: now,
+	}
+	if t.Commits == nil {
+		t.Commits = []*TimelineCommit{}
+	}
+
+	sort.SliceStable(t.Commits, func(i, j int) bool {
+		a, b := t.Commits[i].PushedAt, t.Commits[j].PushedAt
+		return a != nil && (b == nil || a.Before(*b))
+	})
+
+	var s *WorkSession
+	for _, c := range t.Commits {
+		if c.PushedAt == nil {
+			continue
+		}
+		at := *c.PushedAt
+
+		if deadlineAt != nil && at.After(*deadlineAt) {
+			c.Late = true
+			t.LateCommits++
+		}
+
+		if s == nil || at.Sub(s.EndAt) > SessionGap {
+			s = &WorkSession{StartAt: at.Add(-SessionLead)}
+			t.Sessions = append(t.Sessions, s)
+		}
+		s.EndAt = at
+		s.Commits++
+	}
+
+	for _, s := range t.Sessions {
+		t.ActiveTime += s.EndAt.Sub(s.StartAt)
+	}
+
+	return t
+}
+
+const (
+	// JobTypeAnalyzeTimeline ...
+	JobTypeAnalyzeTimeline JobType = "analyze_timeline"
+)
+
+// AnalyzeTimelinePayload ...
+type AnalyzeTimelinePayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *AnalyzeTimelinePayload) JobType() JobType {
+	return JobTypeAnalyzeTimeline
+}
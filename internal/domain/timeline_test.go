@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPushTimes(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+
+	tests := []struct {
+		name       string
+		pushes     []*Push
+		lastPushAt int
+		// want maps commits, oldest first, to the minute they were
+		// pushed at, or -1 if it isn't known.
+		want []int
+	}{
+		{
+			name:   "every commit pushed",
+			pushes: []*Push{{At: at(10), Head: "a"}, {At: at(20), Head: "b"}, {At: at(30), Head: "c"}},
+			want:   []int{10, 20, 30},
+		},
+		{
+			name:   "earliest push wins",
+			pushes: []*Push{{At: at(20), Head: "c", SHAs: []string{"a", "b", "c"}}, {At: at(10), Head: "a"}},
+			want:   []int{10, 20, 20},
+		},
+		{
+			name:   "commits get their nearest pushed descendant's time",
+			pushes: []*Push{{At: at(10), Head: "a"}, {At: at(30), Head: "c"}},
+			want:   []int{10, 30, 30},
+		},
+		{
+			name:   "commits can't be pushed after their descendants",
+			pushes: []*Push{{At: at(40), Head: "a"}, {At: at(30), Head: "c"}},
+			want:   []int{30, 30, 30},
+		},
+		{
+			name:       "commits after the last known push get the repo's last push",
+			pushes:     []*Push{{At: at(10), Head: "a"}},
+			lastPushAt: 50,
+			want:       []int{10, 50, 50},
+		},
+		{
+			name: "no pushes",
+			want: []int{-1, -1, -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			commits := []*TimelineCommit{{SHA: "a"}, {SHA: "b"}, {SHA: "c"}}
+			var lastPushAt *time.Time
+			if tt.lastPushAt != 0 {
+				last := at(tt.lastPushAt)
+				lastPushAt = &last
+			}
+
+			SetPushTimes(commits, tt.pushes, lastPushAt)
+
+			for i, c := range commits {
+				if tt.want[i] < 0 {
+					r.Nil(c.PushedAt, c.SHA)
+					continue
+				}
+				r.NotNil(c.PushedAt, c.SHA)
+				r.Equal(at(tt.want[i]), *c.PushedAt, c.SHA)
+			}
+		})
+	}
+}
+
+func TestNewCommitTimeline(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+
+	tests := []struct {
+		name string
+		// pushed is the minute each commit was pushed at, or -1 if it
+		// isn't known.
+		pushed     []int
+		deadlineAt int
+		order      []string
+		late       []string
+		sessions   []*WorkSession
+		activeTime time.Duration
+	}{
+		{
+			name:     "no commits",
+			sessions: []*WorkSession{},
+		},
+		{
+			name:       "one session",
+			pushed:     []int{0, 10, 30},
+			order:      []string{"c0", "c1", "c2"},
+			sessions:   []*WorkSession{{StartAt: at(-15), EndAt: at(30), Commits: 3}},
+			activeTime: 45 * time.Minute,
+		},
+		{
+			name:   "long breaks start new sessions",
+			pushed: []int{0, 45, 91},
+			order:  []string{"c0", "c1", "c2"},
+			sessions: []*WorkSession{
+				{StartAt: at(-15), EndAt: at(45), Commits: 2},
+				{StartAt: at(76), EndAt: at(91), Commits: 1},
+			},
+			activeTime: 75 * time.Minute,
+		},
+		{
+			name:       "sorted by push time with unknown last",
+			pushed:     []int{-1, 20, 10, -1, 20},
+			order:      []string{"c2", "c1", "c4", "c0", "c3"},
+			sessions:   []*WorkSession{{StartAt: at(-5), EndAt: at(20), Commits: 3}},
+			activeTime: 25 * time.Minute,
+		},
+		{
+			name:       "commits pushed after the deadline are late",
+			pushed:     []int{10, 20, 21, -1},
+			deadlineAt: 20,
+			order:      []string{"c0", "c1", "c2", "c3"},
+			late:       []string{"c2"},
+			sessions:   []*WorkSession{{StartAt: at(-5), EndAt: at(21), Commits: 3}},
+			activeTime: 26 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var commits []*TimelineCommit
+			for i, m := range tt.pushed {
+				c := &TimelineCommit{SHA: "c" + string(rune('0'+i))}
+				if m >= 0 {
+					pushedAt := at(m)
+					c.PushedAt = &pushedAt
+				}
+				commits = append(commits, c)
+			}
+			var deadlineAt *time.Time
+			if tt.deadlineAt != 0 {
+				d := at(tt.deadlineAt)
+				deadlineAt = &d
+			}
+
+			tl := NewCommitTimeline(commits, deadlineAt, at(100))
+
+			var order, late []string
+			for _, c := range tl.Commits {
+				order = append(order, c.SHA)
+				if c.Late {
+					late = append(late, c.SHA)
+				}
+			}
+			r.NotNil(tl.Commits)
+			r.Equal(tt.order, order)
+			r.Equal(tt.late, late)
+			r.Equal(len(tt.late), tl.LateCommits)
+			r.Equal(tt.sessions, tl.Sessions)
+			r.Equal(tt.activeTime, tl.ActiveTime)
+			r.Equal(deadlineAt, tl.DeadlineAt)
+			r.Equal(at(100), tl.AnalyzedAt)
+		})
+	}
+}
@@ -10,17 +10,15 @@ import (
 
 // User ...
 type User struct {
-	AccountID      ID          `json:"account_id" db:"account_id"`
-	ID             ID          `json:"id" db:"id"`
-	GithubID       int64       `json:"github_id" db:"github_id"`
-	Email          string      `json:"email" db:"email"`
-	PasswordHash   []byte      `json:"-" db:"password_hash"`
-	Token          string      `json:"-" db:"token"`
-	TokenExpiresAt *time.Time  `json:"-" db:"token_expires_at"`
-	Profile        UserProfile `json:"profile" db:"profile"`
-	Role           Role        `json:"role" db:"role"`
-	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt      *time.Time  `json:"updated_at" db:"updated_at"`
+	AccountID    ID          `json:"account_id" db:"account_id"`
+	ID           ID          `json:"id" db:"id"`
+	GithubID     int64       `json:"github_id" db:"github_id"`
+	Email        string      `json:"email" db:"email"`
+	PasswordHash []byte      `json:"-" db:"password_hash"`
+	Profile      UserProfile `json:"profile" db:"profile"`
+	Role         Role        `json:"role" db:"role"`
+	CreatedAt    time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt    *time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type NewUserArgs struct {
@@ -83,6 +81,16 @@ func (u *User) CheckPassword(plaintextPassword string) error {
 	return bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(plaintextPassword))
 }
 
+// HasRole returns true if the user has any of the given roles.
+func (u *User) HasRole(roles ...Role) bool {
+	for _, r := range roles {
+		if u.Role == r {
+			return true
+		}
+	}
+	return false
+}
+
 // UserProfile ...
 type UserProfile struct {
 	GivenName   string `json:"given_name"`
@@ -101,19 +109,43 @@ type UserDAO interface {
 	GetByGithubID(ctx context.Context, accountID ID, githubID int64) (*User, error)
 	GetAllByGithubID(ctx context.Context, githubID int64) ([]*User, error)
 	GetAll(ctx context.Context, accountID, id ID) ([]*User, int, error)
-	GetByToken(ctx context.Context, token string) (*User, error)
 	Update(ctx context.Context, accountID, id ID, updates []Field) (*User, error)
 }
 
 // UserUseCases ...
 type UserUseCases interface {
 	Signup(ctx context.Context, a SignupArgs) (*SignupResult, error)
-	Login(ctx context.Context, accountCode, email, password string) (*LoginResult, error)
-	GithubLogin(ctx context.Context, accountCode string, githubID int64) (*LoginResult, error)
+	Login(ctx context.Context, accountCode, email, password string, ci ClientInfo) (*LoginResult, error)
+	GithubLogin(ctx context.Context, accountCode string, githubID int64, ci ClientInfo) (*LoginResult, error)
 	GithubGetAvailableAccounts(ctx context.Context, githubID int64) ([]*AccountInfo, error)
 	Create(ctx context.Context, a CreateUserArgs) (*User, error)
 	Update(ctx context.Context, accountID, id ID, a UpdateUserArgs) (*User, error)
 	List(ctx context.Context, page int) (*ListUsersResult, error)
+	// ListSessions returns the current user's sessions.
+	ListSessions(ctx context.Context) ([]*UserSession, error)
+	// Logout ends the current session.
+	Logout(ctx context.Context) error
+	// RevokeSession ends one of the current user's sessions.
+	RevokeSession(ctx context.Context, id ID) error
+	// RevokeOtherSessions ends all of the current user's sessions
+	// except the current one.
+	RevokeOtherSessions(ctx context.Context) (int, error)
+	// RevokeUserSessions ends all sessions of a user in the current
+	// user's account. Admins only.
+	RevokeUserSessions(ctx context.Context, userID ID) (int, error)
+	// Refresh exchanges a refresh token for a new access token and
+	// refresh token.
+	Refresh(ctx context.Context, refreshToken string) (*RefreshResult, error)
+	// RequestPasswordReset enqueues a job that emails a password reset
+	// link to a user. It doesn't fail if there's no such user or the
+	// request is rate limited, so as not to reveal who has an account.
+	RequestPasswordReset(ctx context.Context, a RequestPasswordResetArgs) error
+	// SendPasswordReset is the job handler that creates a password reset
+	// and emails the link to the user, if the user exists.
+	SendPasswordReset(ctx context.Context, j *Job, p JobPayload) error
+	// ResetPassword sets a new password using a reset token and ends
+	// all of the user's sessions.
+	ResetPassword(ctx context.Context, a ResetPasswordArgs) error
 }
 
 // SignupArgs ...
@@ -127,29 +159,40 @@ type SignupArgs struct {
 	GithubLogin string
 	PhotoURL    string
 	Location    string
+	Client      ClientInfo
 }
 
 // SignupResult ...
 type SignupResult struct {
-	Account *Account
-	User    *User
-	Token   string
+	Account      *Account
+	User         *User
+	Token        string
+	RefreshToken string
 }
 
 // LoginResult ...
 type LoginResult struct {
-	Account *Account
-	User    *User
-	Token   string
+	Account      *Account
+	User         *User
+	Token        string
+	RefreshToken string
+}
+
+// RefreshResult ...
+type RefreshResult struct {
+	Token        string
+	RefreshToken string
+	ExpiresAt    time.Time
 }
 
 // CreateUserArgs ...
 type CreateUserArgs struct {
-	GivenName  string
-	FamilyName string
-	Email      string
-	Password   string
-	Role       Role
+	GivenName   string
+	FamilyName  string
+	Email       string
+	Password    string
+	Role        Role
+	GithubLogin string
 }
 
 // UpdateUserArgs ...
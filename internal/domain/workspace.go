@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TaskRepo is the private Github repo a candidate works on for a
+// single challenge task. Error holds the last provisioning error, and
+// FailedAt is set once provisioning has been given up on.
+type TaskRepo struct {
+	Owner         string     `json:"owner"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	Status        RepoStatus `json:"status"`
+	Error         string     `json:"error"`
+	FailedAt      *time.Time `json:"failed_at"`
+	ProvisionedAt *time.Time `json:"provisioned_at"`
+	PullNumber    int        `json:"pull_number"`
+	PullURL       string     `json:"pull_url"`
+	HeadCommit    string     `json:"head_commit"`
+	// StarterCommit is the SHA of the commit in the task's source repo
+	// that the starter code was copied from.
+	StarterCommit string     `json:"starter_commit"`
+	SnapshotKey   string     `json:"snapshot_key"`
+	ArchivedAt    *time.Time `json:"archived_at"`
+	DeletedAt     *time.Time `json:"deleted_at"`
+}
+
+// RepoStatus ...
+type RepoStatus string
+
+const (
+	// RepoStatusPending means the repo hasn't been created yet.
+	RepoStatusPending RepoStatus = "pending"
+	// RepoStatusCreated means the repo exists but has no starter code yet.
+	RepoStatusCreated RepoStatus = "created"
+	// RepoStatusUploaded means the starter code has been uploaded but
+	// the candidate hasn't been invited yet.
+	RepoStatusUploaded RepoStatus = "uploaded"
+	// RepoStatusReady means the candidate has been invited to the repo.
+	RepoStatusReady RepoStatus = "ready"
+	// RepoStatusArchived means the candidate's access has been revoked
+	// and the final state of the repo has been saved.
+	RepoStatusArchived RepoStatus = "archived"
+	// RepoStatusDeleted means the repo has been deleted from Github.
+	RepoStatusDeleted RepoStatus = "deleted"
+)
+
+const (
+	// JobTypeProvisionRepo ...
+	JobTypeProvisionRepo JobType = "provision_repo"
+	// JobTypeArchiveRepo ...
+	JobTypeArchiveRepo JobType = "archive_repo"
+	// JobTypeDeleteRepo ...
+	JobTypeDeleteRepo JobType = "delete_repo"
+)
+
+// TaskRef identifies a single task in a challenge.
+type TaskRef struct {
+	AccountID   ID `json:"account_id"`
+	ChallengeID ID `json:"challenge_id"`
+	TaskID      ID `json:"task_id"`
+}
+
+// Validate ...
+func (r *TaskRef) Validate() error {
+	if r.AccountID == "" || r.ChallengeID == "" || r.TaskID == "" {
+		return errors.Errorf("missing account, challenge or task id")
+	}
+	return nil
+}
+
+// ProvisionRepoPayload ...
+type ProvisionRepoPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *ProvisionRepoPayload) JobType() JobType {
+	return JobTypeProvisionRepo
+}
+
+// ArchiveRepoPayload ...
+type ArchiveRepoPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *ArchiveRepoPayload) JobType() JobType {
+	return JobTypeArchiveRepo
+}
+
+// DeleteRepoPayload ...
+type DeleteRepoPayload struct {
+	TaskRef
+}
+
+// JobType ...
+func (p *DeleteRepoPayload) JobType() JobType {
+	return JobTypeDeleteRepo
+}
+
+// WorkspaceUseCases ...
+type WorkspaceUseCases interface {
+	// ProvisionRepo is the job handler that sets up a task's repo.
+	ProvisionRepo(ctx context.Context, j *Job, p JobPayload) error
+	// ArchiveRepo is the job handler that closes a task's repo at the
+	// task's deadline.
+	ArchiveRepo(ctx context.Context, j *Job, p JobPayload) error
+	// DeleteRepo is the job handler that deletes an archived repo.
+	DeleteRepo(ctx context.Context, j *Job, p JobPayload) error
+	// CollectReview is the job handler that fetches a candidate's
+	// review of the pull request in a code review task.
+	CollectReview(ctx context.Context, j *Job, p JobPayload) error
+}
@@ -28,9 +28,9 @@ func New(db *sqlx.DB) *DAO {
 func (d *DAO) Create(ctx context.Context, a *domain.Account) error {
 	stmt, err := d.db.PrepareNamed(`
 	INSERT INTO accounts
-		(id, name, code, profile, members, owner_id, created_at)
+		(id, name, code, profile, settings, members, owner_id, created_at)
 	VALUES
-		(:id, :name, :code, :profile, :members, :owner_id, :created_at)
+		(:id, :name, :code, :profile, :settings, :members, :owner_id, :created_at)
 	RETURNING *
 	`)
 	if err != nil {
@@ -113,6 +113,7 @@ func (d *DAO) CreateTable() int {
 		name VARCHAR(128),
 		code VARCHAR(64),
 		profile JSONB,
+		settings JSONB,
 		members JSONB,
 		owner_id CHAR(20) NOT NULL,
 		created_at TIMESTAMPTZ NOT NULL,
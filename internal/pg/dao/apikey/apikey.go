@@ -0,0 +1,112 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.APIKeyDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, k *domain.APIKey) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO api_keys
+		(account_id, id, name, prefix, key_hash, scopes, created_by, created_at, expires_at, last_used_at)
+	VALUES
+		(:account_id, :id, :name, :prefix, :key_hash, :scopes, :created_by, :created_at, :expires_at, :last_used_at)
+	`, k)
+	if err != nil {
+		return errors.Wrapf(err, "could not create api key in account %s", k.AccountID)
+	}
+
+	return nil
+}
+
+// GetByKeyHash ...
+func (d *DAO) GetByKeyHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	k := new(domain.APIKey)
+
+	err := d.db.GetContext(ctx, k, "SELECT * FROM api_keys WHERE key_hash = $1", keyHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get api key")
+	}
+
+	return k, nil
+}
+
+// List ...
+func (d *DAO) List(ctx context.Context, accountID domain.ID) ([]*domain.APIKey, error) {
+	var ks []*domain.APIKey
+
+	err := d.db.SelectContext(ctx, &ks, "SELECT * FROM api_keys WHERE account_id = $1 ORDER BY created_at DESC", accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list api keys in account %s", accountID)
+	}
+
+	return ks, nil
+}
+
+// Delete ...
+func (d *DAO) Delete(ctx context.Context, accountID, id domain.ID) error {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM api_keys WHERE account_id = $1 AND id = $2", accountID, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete api key %s in account %s", id, accountID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "could not delete api key %s in account %s", id, accountID)
+	}
+	if n == 0 {
+		return errors.Wrapf(sql.ErrNoRows, "could not find api key %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// Touch ...
+func (d *DAO) Touch(ctx context.Context, accountID, id domain.ID, at time.Time) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $3 WHERE account_id = $1 AND id = $2", accountID, id, at)
+	if err != nil {
+		return errors.Wrapf(err, "could not touch api key %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE api_keys (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		prefix VARCHAR(32) NOT NULL,
+		key_hash CHAR(64) NOT NULL,
+		scopes JSONB,
+		created_by CHAR(20) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NULL,
+		last_used_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE UNIQUE INDEX ON api_keys (key_hash)`)
+
+	return 1
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.AuditDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, e *domain.AuditEntry) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO audit_log
+		(account_id, id, actor_id, action, target_id, details, created_at)
+	VALUES
+		(:account_id, :id, :actor_id, :action, :target_id, :details, :created_at)
+	`, e)
+	if err != nil {
+		return errors.Wrapf(err, "could not create audit entry in account %s", e.AccountID)
+	}
+
+	return nil
+}
+
+// List ...
+func (d *DAO) List(ctx context.Context, accountID domain.ID, actions ...domain.AuditAction) ([]*domain.AuditEntry, error) {
+	var es []*domain.AuditEntry
+
+	q := psql.Select("*").From("audit_log").
+		Where(sq.Eq{"account_id": accountID}).
+		OrderBy("created_at DESC")
+
+	if len(actions) > 0 {
+		q = q.Where(sq.Eq{"action": actions})
+	}
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create select query")
+	}
+
+	err = d.db.SelectContext(ctx, &es, sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list audit entries in account %s", accountID)
+	}
+
+	return es, nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE audit_log (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		actor_id CHAR(20) NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		target_id VARCHAR(64),
+		details TEXT,
+		created_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE INDEX ON audit_log (account_id, action)`)
+	d.db.MustExec(`CREATE INDEX ON audit_log (account_id, created_at)`)
+
+	return 1
+}
@@ -0,0 +1,254 @@
+package challenge
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pg/txn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.ChallengeDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, c *domain.Challenge) error {
+	stmt, err := d.db.PrepareNamed(`
+	INSERT INTO challenges
+		(account_id, id, for_user_id, status, details, created_by_user_id, expires_at, created_at)
+	VALUES
+		(:account_id, :id, :for_user_id, :status, :details, :created_by_user_id, :expires_at, :created_at)
+	RETURNING *
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "could not prepare statement")
+	}
+
+	err = stmt.Get(c, c)
+	if err != nil {
+		return errors.Wrapf(err, "could not create challenge")
+	}
+
+	return nil
+}
+
+// Get ...
+func (d *DAO) Get(ctx context.Context, accountID, id domain.ID) (*domain.Challenge, error) {
+	c := new(domain.Challenge)
+
+	err := d.db.Get(c, "SELECT * FROM challenges WHERE account_id = $1 AND id = $2", accountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get challenge %s in account %s", id, accountID)
+	}
+
+	return c, nil
+}
+
+// GetAll ...
+func (d *DAO) GetAll(ctx context.Context, accountID domain.ID, ids []domain.ID) ([]*domain.Challenge, error) {
+	var cs []*domain.Challenge
+
+	if len(ids) == 0 {
+		return cs, nil
+	}
+
+	sql, args, err := psql.Select("*").From("challenges").
+		Where(sq.Eq{"account_id": accountID, "id": ids}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create select query")
+	}
+
+	err = d.db.Select(&cs, sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get challenges with ids %v in account %s", ids, accountID)
+	}
+
+	return cs, nil
+}
+
+// List ...
+func (d *DAO) List(ctx context.Context, accountID domain.ID, a domain.ListChallengesArgs) ([]*domain.Challenge, int, error) {
+	where := sq.Eq{"account_id": accountID}
+	if len(a.Statuses) > 0 {
+		where["status"] = a.Statuses
+	}
+	if a.ForUserID != "" {
+		where["for_user_id"] = a.ForUserID
+	}
+
+	sqlC, argsC, err := psql.Select("COUNT(*)").From("challenges").Where(where).ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not create count query")
+	}
+
+	sqlN, argsN, err := psql.Select("*").From("challenges").Where(where).OrderBy("created_at DESC").ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not create select query")
+	}
+
+	var total int
+	err = d.db.Get(&total, sqlC, argsC...)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list challenges (count)")
+	}
+
+	var cs []*domain.Challenge
+	err = d.db.Select(&cs, sqlN, argsN...)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list challenges")
+	}
+
+	return cs, total, nil
+}
+
+// Update ...
+func (d *DAO) Update(ctx context.Context, accountID, id domain.ID, updates []domain.Field) (*domain.Challenge, error) {
+	q := psql.Update("challenges").Where("account_id = ? AND id = ?", accountID, id)
+
+	for _, u := range updates {
+		q = q.Set(u.Name, u.Value)
+	}
+	q = q.Set("updated_at", time.Now())
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create update query")
+	}
+
+	_, err = d.db.Exec(sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update challenge %s in account %s", id, accountID)
+	}
+
+	return d.Get(ctx, accountID, id)
+}
+
+// Modify ...
+func (d *DAO) Modify(ctx context.Context, accountID, id domain.ID, fn func(c *domain.Challenge) error) (*domain.Challenge, error) {
+	var c *domain.Challenge
+
+	err := txn.Retry(func() (err error) {
+		c, err = d.modify(ctx, accountID, id, fn)
+		return err
+	})
+
+	return c, err
+}
+
+func (d *DAO) modify(ctx context.Context, accountID, id domain.ID, fn func(c *domain.Challenge) error) (*domain.Challenge, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not begin transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	c := new(domain.Challenge)
+
+	err = tx.Get(c, "SELECT * FROM challenges WHERE account_id = $1 AND id = $2 FOR UPDATE", accountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get challenge %s in account %s", id, accountID)
+	}
+
+	err = fn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	c.UpdatedAt = &now
+
+	_, err = tx.NamedExec(`
+	UPDATE challenges SET
+		status = :status,
+		details = :details,
+		expires_at = :expires_at,
+		started_at = :started_at,
+		updated_at = :updated_at
+	WHERE account_id = :account_id AND id = :id
+	`, c)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update challenge %s in account %s", id, accountID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not commit changes to challenge %s in account %s", id, accountID)
+	}
+
+	return c, nil
+}
+
+// ListOverdue ...
+func (d *DAO) ListOverdue(ctx context.Context, before time.Time, limit int) ([]*domain.Challenge, error) {
+	var cs []*domain.Challenge
+
+	err := d.db.SelectContext(ctx, &cs, `
+	SELECT * FROM challenges
+	WHERE status IN ($1, $2, $3) AND expires_at < $4
+	ORDER BY expires_at
+	LIMIT $5
+	`, domain.StatusNew, domain.StatusScheduled, domain.StatusActive, before, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list overdue challenges")
+	}
+
+	return cs, nil
+}
+
+// ListWithPendingJobs ...
+func (d *DAO) ListWithPendingJobs(ctx context.Context, limit int) ([]*domain.Challenge, error) {
+	var cs []*domain.Challenge
+
+	err := d.db.SelectContext(ctx, &cs, `
+	SELECT * FROM challenges
+	WHERE details->'pending_jobs' IS NOT NULL
+	ORDER BY updated_at
+	LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list challenges with pending jobs")
+	}
+
+	return cs, nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE challenges (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		for_user_id CHAR(20) NOT NULL,
+		status VARCHAR(32),
+		details JSONB,
+		created_by_user_id CHAR(20) NOT NULL,
+		expires_at TIMESTAMPTZ NULL,
+		started_at TIMESTAMPTZ NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE INDEX ON challenges (account_id, for_user_id)`)
+	d.db.MustExec(`CREATE INDEX ON challenges (account_id, status)`)
+	d.db.MustExec(`CREATE INDEX ON challenges (status, expires_at)`)
+
+	return 1
+}
@@ -0,0 +1,74 @@
+package fingerprint
+
+import (
+	"context"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.FingerprintDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Put ...
+func (d *DAO) Put(ctx context.Context, f *domain.Fingerprint) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO fingerprints
+		(account_id, task_id, challenge_id, user_id, head_commit, details, created_at)
+	VALUES
+		(:account_id, :task_id, :challenge_id, :user_id, :head_commit, :details, :created_at)
+	ON CONFLICT (account_id, task_id, challenge_id) DO UPDATE SET
+		user_id = excluded.user_id,
+		head_commit = excluded.head_commit,
+		details = excluded.details,
+		created_at = excluded.created_at
+	`, f)
+	if err != nil {
+		return errors.Wrapf(err, "could not save fingerprint of challenge %s task %s in account %s", f.ChallengeID, f.TaskID, f.AccountID)
+	}
+
+	return nil
+}
+
+// ListByTask ...
+func (d *DAO) ListByTask(ctx context.Context, accountID, taskID domain.ID) ([]*domain.Fingerprint, error) {
+	var fs []*domain.Fingerprint
+
+	err := d.db.SelectContext(ctx, &fs, `
+	SELECT * FROM fingerprints
+	WHERE account_id = $1 AND task_id = $2
+	ORDER BY created_at
+	`, accountID, taskID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list fingerprints of task %s in account %s", taskID, accountID)
+	}
+
+	return fs, nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE fingerprints (
+		account_id CHAR(20) NOT NULL,
+		task_id CHAR(20) NOT NULL,
+		challenge_id CHAR(20) NOT NULL,
+		user_id CHAR(20) NOT NULL,
+		head_commit VARCHAR(64),
+		details JSONB,
+		created_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (account_id, task_id, challenge_id)
+	)`)
+
+	return 1
+}
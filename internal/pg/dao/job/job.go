@@ -0,0 +1,258 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.JobDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, j *domain.Job) error {
+	stmt, err := d.db.PrepareNamed(`
+	INSERT INTO jobs
+		(account_id, id, name, type, status, details, scheduled_at, run_at, locked_by, attempts, max_attempts, last_error, created_at)
+	VALUES
+		(:account_id, :id, :name, :type, :status, :details, :scheduled_at, :run_at, :locked_by, :attempts, :max_attempts, :last_error, :created_at)
+	ON CONFLICT (id) DO NOTHING
+	RETURNING *
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "could not prepare statement")
+	}
+
+	err = stmt.Get(j, j)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The job has already been created.
+		old, err := d.Get(ctx, j.ID)
+		if err != nil {
+			return err
+		}
+		*j = *old
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not create job")
+	}
+
+	return nil
+}
+
+// Get ...
+func (d *DAO) Get(ctx context.Context, id domain.ID) (*domain.Job, error) {
+	j := new(domain.Job)
+
+	err := d.db.Get(j, "SELECT * FROM jobs WHERE id = $1", id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get job %s", id)
+	}
+
+	return j, nil
+}
+
+// GetAll ...
+func (d *DAO) GetAll(ctx context.Context, ids []domain.ID) ([]*domain.Job, error) {
+	var js []*domain.Job
+
+	if len(ids) == 0 {
+		return js, nil
+	}
+
+	sql, args, err := psql.Select("*").From("jobs").Where(sq.Eq{"id": ids}).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create select query")
+	}
+
+	err = d.db.Select(&js, sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get jobs with ids %v", ids)
+	}
+
+	return js, nil
+}
+
+// Update ...
+func (d *DAO) Update(ctx context.Context, id domain.ID, updates []domain.Field) (*domain.Job, error) {
+	q := psql.Update("jobs").Where("id = ?", id)
+
+	for _, u := range updates {
+		q = q.Set(u.Name, u.Value)
+	}
+	q = q.Set("updated_at", time.Now())
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create update query")
+	}
+
+	_, err = d.db.Exec(sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update job %s", id)
+	}
+
+	return d.Get(ctx, id)
+}
+
+// Claim ...
+func (d *DAO) Claim(ctx context.Context, workerID string, types []domain.JobType, limit int, lockFor time.Duration) ([]*domain.Job, error) {
+	var js []*domain.Job
+
+	if len(types) == 0 {
+		return js, nil
+	}
+
+	now := time.Now()
+
+	// The due condition is repeated in the outer query so that a job
+	// claimed by a concurrent worker between the subquery and the
+	// update is skipped rather than claimed twice. Every claim counts
+	// as an attempt, including claims of jobs whose worker died.
+	const due = `type = ANY($5) AND (
+		(status IN ('new', 'scheduled') AND run_at <= $3) OR
+		(status = 'running' AND locked_until < $3)
+	)`
+
+	ts := make([]string, len(types))
+	for i, t := range types {
+		ts[i] = string(t)
+	}
+
+	err := d.db.SelectContext(ctx, &js, `
+	UPDATE jobs SET
+		status = 'running',
+		locked_by = $1,
+		locked_until = $2,
+		attempts = attempts + 1,
+		updated_at = $3
+	WHERE id IN (
+		SELECT id FROM jobs WHERE `+due+` ORDER BY run_at LIMIT $4
+	) AND `+due+`
+	RETURNING *
+	`, workerID, now.Add(lockFor), now, limit, pq.Array(ts))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not claim jobs for worker %s", workerID)
+	}
+
+	return js, nil
+}
+
+// Release ...
+func (d *DAO) Release(ctx context.Context, id domain.ID, workerID string, updates []domain.Field) (*domain.Job, error) {
+	q := psql.Update("jobs").Where("id = ? AND locked_by = ? AND status = ?", id, workerID, domain.StatusRunning)
+
+	for _, u := range updates {
+		q = q.Set(u.Name, u.Value)
+	}
+	q = q.Set("locked_by", "")
+	q = q.Set("locked_until", nil)
+	q = q.Set("updated_at", time.Now())
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create update query")
+	}
+
+	res, err := d.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not release job %s", id)
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return nil, errors.Errorf("job %s is no longer locked by worker %s", id, workerID)
+	}
+
+	return d.Get(ctx, id)
+}
+
+// List ...
+func (d *DAO) List(ctx context.Context, accountID domain.ID, status domain.Status) ([]*domain.Job, int, error) {
+	var total int
+	err := d.db.Get(&total, "SELECT COUNT(*) FROM jobs WHERE account_id = $1 AND status = $2", accountID, status)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list jobs (count)")
+	}
+
+	var js []*domain.Job
+	err = d.db.Select(&js, "SELECT * FROM jobs WHERE account_id = $1 AND status = $2 ORDER BY updated_at DESC", accountID, status)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list jobs")
+	}
+
+	return js, total, nil
+}
+
+// Requeue ...
+func (d *DAO) Requeue(ctx context.Context, accountID, id domain.ID) (*domain.Job, error) {
+	now := time.Now()
+
+	res, err := d.db.Exec(`
+	UPDATE jobs SET
+		status = $1,
+		attempts = 0,
+		last_error = '',
+		failed_at = NULL,
+		run_at = $2,
+		updated_at = $2
+	WHERE account_id = $3 AND id = $4 AND status = $5
+	`, domain.StatusNew, now, accountID, id, domain.StatusDead)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not requeue job %s in account %s", id, accountID)
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return nil, errors.Errorf("could not find dead job %s in account %s", id, accountID)
+	}
+
+	return d.Get(ctx, id)
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE jobs (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		name VARCHAR(128),
+		type VARCHAR(64),
+		status VARCHAR(32),
+		details JSONB,
+		scheduled_at TIMESTAMPTZ NULL,
+		run_at TIMESTAMPTZ NOT NULL,
+		locked_by VARCHAR(128) NOT NULL DEFAULT '',
+		locked_until TIMESTAMPTZ NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		max_attempts INT NOT NULL DEFAULT 0,
+		last_error STRING NOT NULL DEFAULT '',
+		completed_at TIMESTAMPTZ NULL,
+		failed_at TIMESTAMPTZ NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (id)
+	)`)
+
+	d.db.MustExec(`CREATE INDEX ON jobs (status, type, run_at)`)
+	d.db.MustExec(`CREATE INDEX ON jobs (account_id, status)`)
+
+	return 1
+}
@@ -0,0 +1,95 @@
+package passwordreset
+
+import (
+	"context"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.PasswordResetDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, r *domain.PasswordReset) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO password_resets
+		(account_id, id, user_id, token_hash, created_at, expires_at, used_at)
+	VALUES
+		(:account_id, :id, :user_id, :token_hash, :created_at, :expires_at, :used_at)
+	`, r)
+	if err != nil {
+		return errors.Wrapf(err, "could not create password reset for user %s in account %s", r.UserID, r.AccountID)
+	}
+
+	return nil
+}
+
+// GetByTokenHash ...
+func (d *DAO) GetByTokenHash(ctx context.Context, tokenHash string) (*domain.PasswordReset, error) {
+	r := new(domain.PasswordReset)
+
+	err := d.db.GetContext(ctx, r, "SELECT * FROM password_resets WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get password reset")
+	}
+
+	return r, nil
+}
+
+// Use ...
+func (d *DAO) Use(ctx context.Context, tokenHash string, at time.Time) (bool, error) {
+	// Only one of several concurrent uses of the same token can win.
+	res, err := d.db.ExecContext(ctx, "UPDATE password_resets SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL", tokenHash, at)
+	if err != nil {
+		return false, errors.Wrap(err, "could not use password reset")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "could not use password reset")
+	}
+
+	return n == 1, nil
+}
+
+// DeleteByUser ...
+func (d *DAO) DeleteByUser(ctx context.Context, accountID, userID domain.ID) error {
+	_, err := d.db.ExecContext(ctx, "DELETE FROM password_resets WHERE account_id = $1 AND user_id = $2", accountID, userID)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete password resets of user %s in account %s", userID, accountID)
+	}
+
+	return nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE password_resets (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		user_id CHAR(20) NOT NULL,
+		token_hash CHAR(64) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		used_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE UNIQUE INDEX ON password_resets (token_hash)`)
+	d.db.MustExec(`CREATE INDEX ON password_resets (account_id, user_id)`)
+
+	return 1
+}
@@ -0,0 +1,145 @@
+package scorecard
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pg/txn"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.ScorecardDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Modify ...
+func (d *DAO) Modify(ctx context.Context, accountID, challengeID, taskID, reviewerID domain.ID, fn func(s *domain.Scorecard) error) (*domain.Scorecard, error) {
+	var s *domain.Scorecard
+
+	err := txn.Retry(func() (err error) {
+		s, err = d.modify(ctx, accountID, challengeID, taskID, reviewerID, fn)
+		return err
+	})
+
+	return s, err
+}
+
+func (d *DAO) modify(ctx context.Context, accountID, challengeID, taskID, reviewerID domain.ID, fn func(s *domain.Scorecard) error) (*domain.Scorecard, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not begin transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	s := new(domain.Scorecard)
+
+	err = tx.Get(s, `
+	SELECT * FROM scorecards
+	WHERE account_id = $1 AND challenge_id = $2 AND task_id = $3 AND reviewer_id = $4
+	FOR UPDATE
+	`, accountID, challengeID, taskID, reviewerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		s = &domain.Scorecard{
+			AccountID:   accountID,
+			ChallengeID: challengeID,
+			TaskID:      taskID,
+			ReviewerID:  reviewerID,
+		}
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "could not get scorecard of reviewer %s for challenge %s task %s in account %s", reviewerID, challengeID, taskID, accountID)
+	}
+
+	err = fn(s)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	} else {
+		s.UpdatedAt = &now
+	}
+
+	_, err = tx.NamedExec(`
+	INSERT INTO scorecards
+		(account_id, challenge_id, task_id, reviewer_id, details, created_at, updated_at)
+	VALUES
+		(:account_id, :challenge_id, :task_id, :reviewer_id, :details, :created_at, :updated_at)
+	ON CONFLICT (account_id, challenge_id, task_id, reviewer_id) DO UPDATE SET
+		details = excluded.details,
+		updated_at = excluded.updated_at
+	`, s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not save scorecard of reviewer %s for challenge %s task %s in account %s", reviewerID, challengeID, taskID, accountID)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not commit changes to scorecard of reviewer %s for challenge %s task %s in account %s", reviewerID, challengeID, taskID, accountID)
+	}
+
+	return s, nil
+}
+
+// ListByChallenge ...
+func (d *DAO) ListByChallenge(ctx context.Context, accountID, challengeID domain.ID) ([]*domain.Scorecard, error) {
+	var ss []*domain.Scorecard
+
+	err := d.db.SelectContext(ctx, &ss, `
+	SELECT * FROM scorecards
+	WHERE account_id = $1 AND challenge_id = $2
+	ORDER BY created_at
+	`, accountID, challengeID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list scorecards of challenge %s in account %s", challengeID, accountID)
+	}
+
+	return ss, nil
+}
+
+// ListByAccount ...
+func (d *DAO) ListByAccount(ctx context.Context, accountID domain.ID) ([]*domain.Scorecard, error) {
+	var ss []*domain.Scorecard
+
+	err := d.db.SelectContext(ctx, &ss, `
+	SELECT * FROM scorecards
+	WHERE account_id = $1
+	ORDER BY created_at
+	`, accountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list scorecards in account %s", accountID)
+	}
+
+	return ss, nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE scorecards (
+		account_id CHAR(20) NOT NULL,
+		challenge_id CHAR(20) NOT NULL,
+		task_id CHAR(20) NOT NULL,
+		reviewer_id CHAR(20) NOT NULL,
+		details JSONB,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (account_id, challenge_id, task_id, reviewer_id)
+	)`)
+
+	return 1
+}
@@ -0,0 +1,248 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.SessionDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, s *domain.UserSession) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO sessions
+		(account_id, id, user_id, token_hash, user_agent, ip, created_at, last_seen_at, expires_at)
+	VALUES
+		(:account_id, :id, :user_id, :token_hash, :user_agent, :ip, :created_at, :last_seen_at, :expires_at)
+	`, s)
+	if err != nil {
+		return errors.Wrapf(err, "could not create session for user %s in account %s", s.UserID, s.AccountID)
+	}
+
+	return nil
+}
+
+// Get ...
+func (d *DAO) Get(ctx context.Context, accountID, id domain.ID) (*domain.UserSession, error) {
+	s := new(domain.UserSession)
+
+	err := d.db.GetContext(ctx, s, "SELECT * FROM sessions WHERE account_id = $1 AND id = $2", accountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get session %s in account %s", id, accountID)
+	}
+
+	return s, nil
+}
+
+// GetByTokenHash ...
+func (d *DAO) GetByTokenHash(ctx context.Context, tokenHash string) (*domain.UserSession, error) {
+	s := new(domain.UserSession)
+
+	err := d.db.GetContext(ctx, s, "SELECT * FROM sessions WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get session by token")
+	}
+
+	return s, nil
+}
+
+// Update ...
+func (d *DAO) Update(ctx context.Context, accountID, id domain.ID, updates []domain.Field) error {
+	q := psql.Update("sessions").Where("account_id = ? AND id = ?", accountID, id)
+
+	for _, u := range updates {
+		q = q.Set(u.Name, u.Value)
+	}
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "could not create update query")
+	}
+
+	_, err = d.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrapf(err, "could not update session %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// Touch ...
+func (d *DAO) Touch(ctx context.Context, accountID, id domain.ID, at time.Time) error {
+	_, err := d.db.ExecContext(ctx, "UPDATE sessions SET last_seen_at = $3 WHERE account_id = $1 AND id = $2", accountID, id, at)
+	if err != nil {
+		return errors.Wrapf(err, "could not touch session %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// ListByUser ...
+func (d *DAO) ListByUser(ctx context.Context, accountID, userID domain.ID) ([]*domain.UserSession, error) {
+	var ss []*domain.UserSession
+
+	err := d.db.SelectContext(ctx, &ss, "SELECT * FROM sessions WHERE account_id = $1 AND user_id = $2 ORDER BY last_seen_at DESC", accountID, userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list sessions of user %s in account %s", userID, accountID)
+	}
+
+	return ss, nil
+}
+
+// Delete ...
+func (d *DAO) Delete(ctx context.Context, accountID, userID, id domain.ID) error {
+	res, err := d.db.ExecContext(ctx, "DELETE FROM sessions WHERE account_id = $1 AND user_id = $2 AND id = $3", accountID, userID, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete session %s in account %s", id, accountID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "could not delete session %s in account %s", id, accountID)
+	}
+	if n == 0 {
+		return errors.Wrapf(sql.ErrNoRows, "could not find session %s of user %s in account %s", id, userID, accountID)
+	}
+
+	_, err = d.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE account_id = $1 AND session_id = $2", accountID, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete refresh tokens of session %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// DeleteByUser ...
+func (d *DAO) DeleteByUser(ctx context.Context, accountID, userID domain.ID, except ...domain.ID) (int, error) {
+	q := psql.Delete("sessions").Where(sq.Eq{"account_id": accountID, "user_id": userID})
+	rq := psql.Delete("refresh_tokens").Where(sq.Eq{"account_id": accountID, "user_id": userID})
+	if len(except) > 0 {
+		q = q.Where(sq.NotEq{"id": except})
+		rq = rq.Where(sq.NotEq{"session_id": except})
+	}
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not create delete query")
+	}
+
+	res, err := d.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not delete sessions of user %s in account %s", userID, accountID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not delete sessions of user %s in account %s", userID, accountID)
+	}
+
+	sql, args, err = rq.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not create delete query")
+	}
+
+	_, err = d.db.ExecContext(ctx, sql, args...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not delete refresh tokens of user %s in account %s", userID, accountID)
+	}
+
+	return int(n), nil
+}
+
+// CreateRefreshToken ...
+func (d *DAO) CreateRefreshToken(ctx context.Context, rt *domain.RefreshToken) error {
+	_, err := d.db.NamedExecContext(ctx, `
+	INSERT INTO refresh_tokens
+		(token_hash, account_id, user_id, session_id, created_at, used_at)
+	VALUES
+		(:token_hash, :account_id, :user_id, :session_id, :created_at, :used_at)
+	`, rt)
+	if err != nil {
+		return errors.Wrapf(err, "could not create refresh token for session %s in account %s", rt.SessionID, rt.AccountID)
+	}
+
+	return nil
+}
+
+// GetRefreshToken ...
+func (d *DAO) GetRefreshToken(ctx context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	rt := new(domain.RefreshToken)
+
+	err := d.db.GetContext(ctx, rt, "SELECT * FROM refresh_tokens WHERE token_hash = $1", tokenHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get refresh token")
+	}
+
+	return rt, nil
+}
+
+// UseRefreshToken ...
+func (d *DAO) UseRefreshToken(ctx context.Context, tokenHash string, at time.Time) (bool, error) {
+	// Only one of several concurrent uses of the same token can win.
+	res, err := d.db.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL", tokenHash, at)
+	if err != nil {
+		return false, errors.Wrap(err, "could not use refresh token")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "could not use refresh token")
+	}
+
+	return n == 1, nil
+}
+
+// CreateTable creates the sessions and refresh tokens tables, unless
+// they already exist.
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE IF NOT EXISTS sessions (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		user_id CHAR(20) NOT NULL,
+		token_hash CHAR(64) NOT NULL,
+		user_agent TEXT,
+		ip VARCHAR(64),
+		created_at TIMESTAMPTZ NOT NULL,
+		last_seen_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE UNIQUE INDEX IF NOT EXISTS sessions_token_hash_key ON sessions (token_hash)`)
+	d.db.MustExec(`CREATE INDEX IF NOT EXISTS sessions_account_id_user_id_idx ON sessions (account_id, user_id)`)
+
+	d.db.MustExec(`
+	CREATE TABLE IF NOT EXISTS refresh_tokens (
+		token_hash CHAR(64) NOT NULL,
+		account_id CHAR(20) NOT NULL,
+		user_id CHAR(20) NOT NULL,
+		session_id CHAR(20) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		used_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (token_hash)
+	)`)
+
+	d.db.MustExec(`CREATE INDEX IF NOT EXISTS refresh_tokens_account_id_user_id_idx ON refresh_tokens (account_id, user_id)`)
+	d.db.MustExec(`CREATE INDEX IF NOT EXISTS refresh_tokens_account_id_session_id_idx ON refresh_tokens (account_id, session_id)`)
+
+	return 2
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+var psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+// DAO ...
+type DAO struct {
+	db *sqlx.DB
+}
+
+var _ domain.TaskDAO = &DAO{}
+
+// New ...
+func New(db *sqlx.DB) *DAO {
+	return &DAO{db}
+}
+
+// Create ...
+func (d *DAO) Create(ctx context.Context, t *domain.Task) error {
+	stmt, err := d.db.PrepareNamed(`
+	INSERT INTO tasks
+		(account_id, id, name, type, details, created_at)
+	VALUES
+		(:account_id, :id, :name, :type, :details, :created_at)
+	RETURNING *
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "could not prepare statement")
+	}
+
+	err = stmt.Get(t, t)
+	if err != nil {
+		return errors.Wrapf(err, "could not create task")
+	}
+
+	return nil
+}
+
+// Get ...
+func (d *DAO) Get(ctx context.Context, accountID, id domain.ID) (*domain.Task, error) {
+	t := new(domain.Task)
+
+	err := d.db.Get(t, "SELECT * FROM tasks WHERE account_id = $1 AND id = $2", accountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get task %s in account %s", id, accountID)
+	}
+
+	return t, nil
+}
+
+// GetAll ...
+func (d *DAO) GetAll(ctx context.Context, accountID domain.ID, ids []domain.ID) ([]*domain.Task, error) {
+	var ts []*domain.Task
+
+	if len(ids) == 0 {
+		return ts, nil
+	}
+
+	sql, args, err := psql.Select("*").From("tasks").
+		Where(sq.Eq{"account_id": accountID, "id": ids}).
+		ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create select query")
+	}
+
+	err = d.db.Select(&ts, sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get tasks with ids %v in account %s", ids, accountID)
+	}
+
+	return ts, nil
+}
+
+// List ...
+func (d *DAO) List(ctx context.Context, accountID domain.ID, types []domain.TaskType) ([]*domain.Task, int, error) {
+	where := sq.Eq{"account_id": accountID}
+	if len(types) > 0 {
+		where["type"] = types
+	}
+
+	sqlC, argsC, err := psql.Select("COUNT(*)").From("tasks").Where(where).ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not create count query")
+	}
+
+	sqlN, argsN, err := psql.Select("*").From("tasks").Where(where).OrderBy("created_at DESC").ToSql()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "could not create select query")
+	}
+
+	var total int
+	err = d.db.Get(&total, sqlC, argsC...)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list tasks (count)")
+	}
+
+	var ts []*domain.Task
+	err = d.db.Select(&ts, sqlN, argsN...)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not list tasks")
+	}
+
+	return ts, total, nil
+}
+
+// Update ...
+func (d *DAO) Update(ctx context.Context, accountID, id domain.ID, updates []domain.Field) (*domain.Task, error) {
+	q := psql.Update("tasks").Where("account_id = ? AND id = ?", accountID, id)
+
+	for _, u := range updates {
+		q = q.Set(u.Name, u.Value)
+	}
+	q = q.Set("updated_at", time.Now())
+
+	sql, args, err := q.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create update query")
+	}
+
+	_, err = d.db.Exec(sql, args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update task %s in account %s", id, accountID)
+	}
+
+	return d.Get(ctx, accountID, id)
+}
+
+// Delete ...
+func (d *DAO) Delete(ctx context.Context, accountID, id domain.ID) error {
+	res, err := d.db.Exec("DELETE FROM tasks WHERE account_id = $1 AND id = $2", accountID, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete task %s in account %s", id, accountID)
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return errors.Errorf("could not find task %s in account %s", id, accountID)
+	}
+
+	return nil
+}
+
+// CreateTable ...
+func (d *DAO) CreateTable() int {
+	d.db.MustExec(`
+	CREATE TABLE tasks (
+		account_id CHAR(20) NOT NULL,
+		id CHAR(20) NOT NULL,
+		name VARCHAR(128),
+		type VARCHAR(32),
+		details JSONB,
+		created_at TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NULL,
+		PRIMARY KEY (account_id, id)
+	)`)
+
+	d.db.MustExec(`CREATE INDEX ON tasks (account_id, type)`)
+
+	return 1
+}
@@ -28,9 +28,9 @@ func New(db *sqlx.DB) *DAO {
 func (d *DAO) Create(ctx context.Context, u *domain.User) error {
 	stmt, err := d.db.PrepareNamed(`
 	INSERT INTO users
-		(account_id, id, github_id, email, password_hash, token, token_expires_at, profile, role, created_at)
+		(account_id, id, github_id, email, password_hash, profile, role, created_at)
 	VALUES
-		(:account_id, :id, :github_id, :email, :password_hash, :token, :token_expires_at, :profile, :role, :created_at)
+		(:account_id, :id, :github_id, :email, :password_hash, :profile, :role, :created_at)
 	RETURNING *
 	`)
 	if err != nil {
@@ -122,18 +122,6 @@ func (d *DAO) GetAll(ctx context.Context, accountID, id domain.ID) ([]*domain.Us
 	return us, total, nil
 }
 
-// GetByToken ...
-func (d *DAO) GetByToken(ctx context.Context, token string) (*domain.User, error) {
-	u := new(domain.User)
-
-	err := d.db.Get(u, "SELECT * FROM users WHERE token = $1", token)
-	if err != nil {
-		return nil, errors.Wrapf(err, "could not get user by token %s", token)
-	}
-
-	return u, nil
-}
-
 // Update ...
 func (d *DAO) Update(ctx context.Context, accountID, id domain.ID, updates []domain.Field) (*domain.User, error) {
 	q := psql.Update("users").Where("account_id = ? AND id = ?", accountID, id)
@@ -165,8 +153,6 @@ func (d *DAO) CreateTable() int {
 		github_id BIGINT,
 		email VARCHAR(255),
 		password_hash VARCHAR(60),
-		token CHAR(60) NOT NULL,
-		token_expires_at TIMESTAMPTZ NULL,
 		profile JSONB,
 		role VARCHAR(32),
 		created_at TIMESTAMPTZ NOT NULL,
@@ -175,7 +161,6 @@ func (d *DAO) CreateTable() int {
 	)`)
 
 	d.db.MustExec(`CREATE UNIQUE INDEX ON users (account_id, email)`)
-	d.db.MustExec(`CREATE INDEX ON users (token)`)
 	d.db.MustExec(`CREATE INDEX ON users (github_id)`)
 
 	return 1
@@ -0,0 +1,120 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pg/dao/session"
+	"github.com/anrid/codecoach/internal/pkg/token"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// MigrateTokens hashes access tokens that earlier versions stored in
+// plain text, either in the sessions table or in the users table from
+// before users could have several sessions. Existing logins keep
+// working, and the plain text token columns are dropped. It's safe to
+// run on every start.
+func MigrateTokens(db *sqlx.DB, secret string) (int, error) {
+	var migrated int
+
+	// Sessions with plain text tokens.
+	found, err := hasColumn(db, "sessions", "token")
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		db.MustExec(`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS token_hash CHAR(64)`)
+
+		var rows []struct {
+			AccountID domain.ID `db:"account_id"`
+			ID        domain.ID `db:"id"`
+			Token     string    `db:"token"`
+		}
+		err = db.Select(&rows, `SELECT account_id, id, token FROM sessions WHERE token_hash IS NULL`)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not get sessions with plain text tokens")
+		}
+
+		for _, r := range rows {
+			_, err = db.Exec(
+				`UPDATE sessions SET token_hash = $3 WHERE account_id = $1 AND id = $2`,
+				r.AccountID, r.ID, token.Hash(secret, r.Token),
+			)
+			if err != nil {
+				return migrated, errors.Wrapf(err, "could not hash token of session %s in account %s", r.ID, r.AccountID)
+			}
+			migrated++
+		}
+
+		db.MustExec(`ALTER TABLE sessions DROP COLUMN token CASCADE`)
+	}
+
+	// Create the sessions and refresh tokens tables and indexes if
+	// they're missing.
+	session.New(db).CreateTable()
+
+	// Users with a single plain text token each.
+	found, err = hasColumn(db, "users", "token")
+	if err != nil {
+		return migrated, err
+	}
+	if found {
+		var us []struct {
+			AccountID      domain.ID  `db:"account_id"`
+			ID             domain.ID  `db:"id"`
+			Token          string     `db:"token"`
+			TokenExpiresAt *time.Time `db:"token_expires_at"`
+		}
+		err = db.Select(&us, `SELECT account_id, id, token, token_expires_at FROM users WHERE token_expires_at > now()`)
+		if err != nil {
+			return migrated, errors.Wrap(err, "could not get users with plain text tokens")
+		}
+
+		sessionDAO := session.New(db)
+
+		for _, u := range us {
+			hash := token.Hash(secret, u.Token)
+
+			// Skip tokens migrated by an earlier run that stopped before
+			// dropping the column.
+			_, err = sessionDAO.GetByTokenHash(context.Background(), hash)
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return migrated, err
+			}
+
+			s := domain.NewUserSession(&domain.User{AccountID: u.AccountID, ID: u.ID}, domain.ClientInfo{})
+			s.SetAccessToken("", hash, s.CreatedAt, u.TokenExpiresAt.Sub(s.CreatedAt), 0)
+
+			err = sessionDAO.Create(context.Background(), s)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+
+		db.MustExec(`ALTER TABLE users DROP COLUMN token CASCADE`)
+		db.MustExec(`ALTER TABLE users DROP COLUMN IF EXISTS token_expires_at`)
+	}
+
+	if migrated > 0 {
+		zap.S().Infof("hashed %d plain text access tokens", migrated)
+	}
+
+	return migrated, nil
+}
+
+func hasColumn(db *sqlx.DB, table, column string) (bool, error) {
+	var n int
+	err := db.Get(&n, `SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = 'public' AND table_name = $1 AND column_name = $2`, table, column)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not look up column %s.%s", table, column)
+	}
+	return n > 0, nil
+}
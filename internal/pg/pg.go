@@ -8,6 +8,15 @@ import (
 
 	"github.com/anrid/codecoach/internal/config"
 	"github.com/anrid/codecoach/internal/pg/dao/account"
+	"github.com/anrid/codecoach/internal/pg/dao/apikey"
+	"github.com/anrid/codecoach/internal/pg/dao/audit"
+	"github.com/anrid/codecoach/internal/pg/dao/challenge"
+	"github.com/anrid/codecoach/internal/pg/dao/fingerprint"
+	"github.com/anrid/codecoach/internal/pg/dao/job"
+	"github.com/anrid/codecoach/internal/pg/dao/passwordreset"
+	"github.com/anrid/codecoach/internal/pg/dao/scorecard"
+	"github.com/anrid/codecoach/internal/pg/dao/session"
+	"github.com/anrid/codecoach/internal/pg/dao/task"
 	"github.com/anrid/codecoach/internal/pg/dao/user"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // Postgres driver.
@@ -56,11 +65,35 @@ func InitDB(c *config.Config, drop bool) *sqlx.DB {
 
 		accountDAO := account.New(db)
 		userDAO := user.New(db)
+		taskDAO := task.New(db)
+		challengeDAO := challenge.New(db)
+		jobDAO := job.New(db)
+		fingerprintDAO := fingerprint.New(db)
+		scorecardDAO := scorecard.New(db)
+		auditDAO := audit.New(db)
+		sessionDAO := session.New(db)
+		apiKeyDAO := apikey.New(db)
+		passwordResetDAO := passwordreset.New(db)
 
 		created += accountDAO.CreateTable()
 		created += userDAO.CreateTable()
+		created += taskDAO.CreateTable()
+		created += challengeDAO.CreateTable()
+		created += jobDAO.CreateTable()
+		created += fingerprintDAO.CreateTable()
+		created += scorecardDAO.CreateTable()
+		created += auditDAO.CreateTable()
+		created += sessionDAO.CreateTable()
+		created += apiKeyDAO.CreateTable()
+		created += passwordResetDAO.CreateTable()
 
 		zap.S().Infof("created database %s and %d tables", c.DBName, created)
+	} else {
+		// Hash any access tokens left in plain text by earlier versions.
+		_, err = MigrateTokens(db, c.TokenSecret)
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	return db
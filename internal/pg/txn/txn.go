@@ -0,0 +1,35 @@
+package txn
+
+import (
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// MaxAttempts is the number of times Retry runs a transaction that
+// was aborted due to a serialization conflict.
+const MaxAttempts = 5
+
+// Retry runs fn, a function that runs a whole transaction, and runs
+// it again if it fails with a serialization conflict.
+func Retry(fn func() error) error {
+	var err error
+
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		err = fn()
+		if err == nil || !IsRetryable(err) {
+			break
+		}
+	}
+
+	return err
+}
+
+// IsRetryable returns true if the error is a serialization failure,
+// which CockroachDB returns when concurrent transactions conflict.
+func IsRetryable(err error) bool {
+	var pe *pq.Error
+	if errors.As(err, &pe) {
+		return pe.Code == "40001"
+	}
+	return false
+}
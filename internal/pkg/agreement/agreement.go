@@ -0,0 +1,66 @@
+// Package agreement measures how much raters agree when they score the
+// same things.
+package agreement
+
+// Alpha returns Krippendorff's alpha for interval data, where each unit
+// holds the values given to one thing by different raters. Units with
+// fewer than two values can't be compared and are left out. Alpha is 1
+// for perfect agreement, 0 for agreement no better than chance and
+// negative for systematic disagreement. It's undefined, and ok is false,
+// if there are fewer than two comparable units' worth of values or all
+// values are the same.
+func Alpha(units [][]float64) (alpha float64, ok bool) {
+	var n, sum, sumSq, observed float64
+
+	for _, u := range units {
+		m := float64(len(u))
+		if m < 2 {
+			continue
+		}
+
+		var s, sq float64
+		for _, v := range u {
+			s += v
+			sq += v * v
+		}
+
+		// The sum of squared differences over all ordered pairs of
+		// values in the unit.
+		observed += 2 * (m*sq - s*s) / (m - 1)
+
+		n += m
+		sum += s
+		sumSq += sq
+	}
+
+	if n < 2 {
+		return 0, false
+	}
+
+	expected := 2 * (n*sumSq - sum*sum) / (n - 1)
+	if expected == 0 {
+		return 0, false
+	}
+
+	return 1 - observed/expected, true
+}
+
+// Variance returns the population variance of values.
+func Variance(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var v float64
+	for _, x := range values {
+		v += (x - mean) * (x - mean)
+	}
+
+	return v / float64(len(values))
+}
@@ -0,0 +1,54 @@
+package agreement
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlpha(t *testing.T) {
+	tests := []struct {
+		name  string
+		units [][]float64
+		alpha float64
+		ok    bool
+	}{
+		{name: "no units", units: nil},
+		{name: "no comparable units", units: [][]float64{{1}, {2}}},
+		{name: "all values the same", units: [][]float64{{2, 2}, {2, 2}}},
+		{name: "perfect agreement", units: [][]float64{{1, 1}, {3, 3}}, alpha: 1, ok: true},
+		{name: "units with one value are left out", units: [][]float64{{1, 1}, {5}, {3, 3}}, alpha: 1, ok: true},
+		{name: "some disagreement", units: [][]float64{{1, 1}, {1, 2}, {3, 3}}, alpha: 1 - 2/11.6, ok: true},
+		{name: "no better than chance", units: [][]float64{{1, 2, 3}}, alpha: 0, ok: true},
+		{name: "systematic disagreement", units: [][]float64{{1, 2}, {2, 1}}, alpha: -0.5, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			alpha, ok := Alpha(tt.units)
+			r.Equal(tt.ok, ok)
+			r.InDelta(tt.alpha, alpha, 1e-9)
+		})
+	}
+}
+
+func TestVariance(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{name: "no values", values: nil, want: 0},
+		{name: "one value", values: []float64{2}, want: 0},
+		{name: "same values", values: []float64{3, 3, 3}, want: 0},
+		{name: "spread values", values: []float64{1, 2, 3, 4}, want: 1.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.InDelta(t, tt.want, Variance(tt.values), 1e-9)
+		})
+	}
+}
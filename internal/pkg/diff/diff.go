@@ -0,0 +1,90 @@
+// Package diff computes line-based unified diffs between two versions
+// of a file.
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// binarySniffLen is the number of leading bytes checked for NUL bytes
+// when telling binary files from text files, as git does.
+const binarySniffLen = 8000
+
+// Patch is the difference between two versions of a file.
+type Patch struct {
+	Additions int
+	Deletions int
+	// Text is the unified diff with 3 lines of context, without file
+	// headers.
+	Text string
+}
+
+// IsBinary returns true if content doesn't look like text.
+func IsBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	return bytes.IndexByte(sniff, 0) >= 0 || !utf8.Valid(content)
+}
+
+// Unified returns the unified diff from a to b. Either may be nil for
+// files that were added or removed.
+func Unified(a, b []byte) (*Patch, error) {
+	al, bl := lines(a), lines(b)
+
+	p := &Patch{}
+	for _, op := range difflib.NewMatcher(al, bl).GetOpCodes() {
+		switch op.Tag {
+		case 'r':
+			p.Deletions += op.I2 - op.I1
+			p.Additions += op.J2 - op.J1
+		case 'd':
+			p.Deletions += op.I2 - op.I1
+		case 'i':
+			p.Additions += op.J2 - op.J1
+		}
+	}
+
+	if p.Additions == 0 && p.Deletions == 0 {
+		return p, nil
+	}
+
+	text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:       al,
+		B:       bl,
+		Context: 3,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write diff")
+	}
+
+	// Without file names there are only empty `---` and `+++` headers.
+	if i := strings.Index(text, "@@"); i > 0 {
+		text = text[i:]
+	}
+	p.Text = text
+
+	return p, nil
+}
+
+// lines splits content into lines, each ending with a newline.
+func lines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+
+	ls := strings.SplitAfter(string(content), "\n")
+	if last := ls[len(ls)-1]; last == "" {
+		ls = ls[:len(ls)-1]
+	} else {
+		ls[len(ls)-1] = last + "\n"
+	}
+
+	return ls
+}
@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    bool
+	}{
+		{name: "empty", content: nil, want: false},
+		{name: "text", content: []byte("package main\n"), want: false},
+		{name: "utf-8", content: []byte("// Grüße, 世界\n"), want: false},
+		{name: "NUL byte", content: []byte{0x89, 'P', 'N', 'G', 0}, want: true},
+		{name: "invalid utf-8", content: []byte{'a', 0xff, 'b'}, want: true},
+		{name: "NUL byte past the sniffed bytes", content: append(bytes.Repeat([]byte("a"), binarySniffLen), 0), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, IsBinary(tt.content))
+		})
+	}
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		additions int
+		deletions int
+		text      string
+	}{
+		{
+			name: "unchanged",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+		},
+		{
+			name:      "added file",
+			b:         "a\nb\n",
+			additions: 2,
+			text:      "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:      "removed file",
+			a:         "a\n",
+			deletions: 1,
+			text:      "@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name:      "changed line",
+			a:         "a\nb\nc\n",
+			b:         "a\nB\nc\n",
+			additions: 1,
+			deletions: 1,
+			text:      "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:      "missing final newline",
+			a:         "a\nb",
+			b:         "a\nb\nc",
+			additions: 1,
+			text:      "@@ -1,2 +1,3 @@\n a\n b\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			var a, b []byte
+			if tt.a != "" {
+				a = []byte(tt.a)
+			}
+			if tt.b != "" {
+				b = []byte(tt.b)
+			}
+
+			p, err := Unified(a, b)
+			r.NoError(err)
+			r.Equal(tt.additions, p.Additions)
+			r.Equal(tt.deletions, p.Deletions)
+			r.Equal(tt.text, p.Text)
+		})
+	}
+}
+
+func TestUnifiedContext(t *testing.T) {
+	r := require.New(t)
+
+	var a []string
+	for _, l := range "abcdefghij" {
+		a = append(a, string(l))
+	}
+	b := append([]string(nil), a...)
+	b[0] = "A"
+	b[9] = "J"
+
+	p, err := Unified([]byte(strings.Join(a, "\n")+"\n"), []byte(strings.Join(b, "\n")+"\n"))
+	r.NoError(err)
+	r.Equal(2, p.Additions)
+	r.Equal(2, p.Deletions)
+
+	// Changes more than 3 lines apart get their own hunks.
+	r.Equal("@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n@@ -7,4 +7,4 @@\n g\n h\n i\n-j\n+J\n", p.Text)
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"path"
 	"path/filepath"
 	"strings"
@@ -53,18 +54,21 @@ type Repo struct {
 	PullsURL         string `json:"pulls_url"`
 	CreatedAt        string `json:"created_at"`
 	UpdatedAt        string `json:"updated_at"`
+	PushedAt         string `json:"pushed_at"`
 	Fork             bool   `json:"fork"`
 	HTMLURL          string `json:"html_url"`
+	DefaultBranch    string `json:"default_branch"`
 }
 
 // PullRequest ...
 type PullRequest struct {
-	ID     int64  `json:"id"`
-	URL    string `json:"url"`
-	State  string `json:"state"`
-	Title  string `json:"title"`
-	Number int    `json:"number"`
-	User   struct {
+	ID      int64  `json:"id"`
+	URL     string `json:"url"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Title   string `json:"title"`
+	Number  int    `json:"number"`
+	User    struct {
 		ID    int64  `json:"id"`
 		Login string `json:"login"`
 	}
@@ -72,6 +76,49 @@ type PullRequest struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
+// Commit ...
+type Commit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+			Date  string `json:"date"`
+		} `json:"author"`
+		Committer struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+			Date  string `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+	// Author is the Github user matching the commit's author email, if
+	// any.
+	Author *struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	} `json:"author"`
+}
+
+// Event is an event in a repo's activity feed. Only the payloads of push
+// events are decoded.
+type Event struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Payload   struct {
+		Ref  string `json:"ref"`
+		Head string `json:"head"`
+		// Commits holds up to 20 of the commits pushed.
+		Commits []struct {
+			SHA string `json:"sha"`
+		} `json:"commits"`
+	} `json:"payload"`
+}
+
+// EventTypePush is the type of push events.
+const EventTypePush = "PushEvent"
+
 // Review ...
 type Review struct {
 	ID    int64  `json:"id"`
@@ -80,6 +127,29 @@ type Review struct {
 		ID    int64  `json:"id"`
 		Login string `json:"login"`
 	}
+	Body        string `json:"body"`
+	CommitID    string `json:"commit_id"`
+	SubmittedAt string `json:"submitted_at"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ReviewComment is a comment on a line in a pull request's diff.
+type ReviewComment struct {
+	ID                  int64 `json:"id"`
+	PullRequestReviewID int64 `json:"pull_request_review_id"`
+	InReplyToID         int64 `json:"in_reply_to_id"`
+	User                struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	StartLine int    `json:"start_line"`
+	Side      string `json:"side"`
+	DiffHunk  string `json:"diff_hunk"`
+	CommitID  string `json:"commit_id"`
+	Body      string `json:"body"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
 }
@@ -136,13 +206,31 @@ func (g *API) User(username string) (*User, error) {
 	return u, err
 }
 
-// NewRepo creates a new Github repo for the given candidate.
-func (g *API) NewRepo(candidate, name, desc string) (repo string, err error) {
+// Repo returns a repo.
+func (g *API) Repo(owner, repo string) (*Repo, error) {
+	r := new(Repo)
+	_, err := g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s", owner, repo), nil, r)
+	return r, err
+}
+
+// MaxRepoNameLen is the longest repo name Github allows.
+const MaxRepoNameLen = 100
+
+// NewRepo creates a new private Github repo for the given candidate.
+// The repo's name is the given name followed by the candidate and a
+// random suffix, with the given name shortened to fit Github's limit.
+func (g *API) NewRepo(candidate, name, desc string) (*Repo, error) {
 	h := md5.New()
 	_, _ = io.WriteString(h, name)
 	_, _ = io.WriteString(h, time.Now().String())
 
-	repo = fmt.Sprintf("%s-candidate-%s-%x", name, candidate, h.Sum(nil))
+	suffix := fmt.Sprintf("-candidate-%s-%x", candidate, h.Sum(nil))
+	if len(suffix) >= MaxRepoNameLen {
+		return nil, errors.Errorf("candidate name '%s' is too long for a repo name", candidate)
+	}
+	if len(name)+len(suffix) > MaxRepoNameLen {
+		name = strings.TrimRight(name[:MaxRepoNameLen-len(suffix)], "-")
+	}
 
 	req := struct {
 		Name        string `json:"name"`
@@ -150,28 +238,21 @@ func (g *API) NewRepo(candidate, name, desc string) (repo string, err error) {
 		Private     bool   `json:"private"`
 		Visibility  string `json:"visibility"`
 	}{
-		Name:        repo,
+		Name:        name + suffix,
 		Description: desc,
 		Private:     true,
 		Visibility:  "private",
 	}
 
-	res := struct {
-		ID         int64
-		Name       string
-		FullName   string
-		Private    bool
-		Visibility string
-		Owner      struct {
-			ID    int64
-			Login string
-		}
-	}{}
+	res := new(Repo)
 
-	_, err = g.requestJSON(http.MethodPost, "/user/repos", req, &res)
+	_, err := g.requestJSON(http.MethodPost, "/user/repos", req, res)
+	if err != nil {
+		return nil, err
+	}
 
 	fmt.Printf("Created new repo: id=%d name=%s private=%t\n", res.ID, res.Name, res.Private)
-	return repo, err
+	return res, nil
 }
 
 // ListRepos lists repos for the current user.
@@ -191,21 +272,204 @@ func (g *API) ListPulls(username, repo string) (prs []PullRequest, res *Response
 	return
 }
 
-// ListReviews lists pull requests for the given repo.
-func (g *API) ListReviews(username, repo string, pullNumber int) (rs []Review, res *Response, err error) {
-	res, err = g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews", username, repo, pullNumber), nil, &rs)
+// ListReviews lists reviews of the given pull request.
+func (g *API) ListReviews(username, repo string, pullNumber int, nextURL string) (rs []Review, res *Response, err error) {
+	url := fmt.Sprintf("/repos/%s/%s/pulls/%d/reviews?per_page=100", username, repo, pullNumber)
+	if nextURL != "" {
+		url = nextURL
+	}
+
+	res, err = g.requestJSON(http.MethodGet, url, nil, &rs)
+	return
+}
+
+// ListCommits lists commits on a repo's default branch, newest first.
+// A repo without commits has none.
+func (g *API) ListCommits(owner, repo string, nextURL string) (cs []Commit, res *Response, err error) {
+	url := fmt.Sprintf("/repos/%s/%s/commits?per_page=100", owner, repo)
+	if nextURL != "" {
+		url = nextURL
+	}
+
+	res, err = g.requestJSON(http.MethodGet, url, nil, &cs)
+
+	// Github responds with 409 Conflict if the repo is empty.
+	var e *APIError
+	if errors.As(err, &e) && e.StatusCode == http.StatusConflict {
+		return nil, &Response{StatusCode: e.StatusCode}, nil
+	}
+
+	return
+}
+
+// ListEvents lists a repo's events, newest first. Github only keeps the
+// last 300 events from the last 90 days.
+func (g *API) ListEvents(owner, repo string, nextURL string) (es []Event, res *Response, err error) {
+	url := fmt.Sprintf("/repos/%s/%s/events?per_page=100", owner, repo)
+	if nextURL != "" {
+		url = nextURL
+	}
+
+	res, err = g.requestJSON(http.MethodGet, url, nil, &es)
+	return
+}
+
+// ListReviewComments lists line comments on the given pull request.
+func (g *API) ListReviewComments(username, repo string, pullNumber int, nextURL string) (cs []ReviewComment, res *Response, err error) {
+	url := fmt.Sprintf("/repos/%s/%s/pulls/%d/comments?per_page=100", username, repo, pullNumber)
+	if nextURL != "" {
+		url = nextURL
+	}
+
+	res, err = g.requestJSON(http.MethodGet, url, nil, &cs)
 	return
 }
 
+// CreatePull opens a pull request that merges head into base.
+func (g *API) CreatePull(owner, repo, title, body, head, base string) (*PullRequest, error) {
+	req := struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+	}{title, body, head, base}
+
+	pr := new(PullRequest)
+
+	_, err := g.requestJSON(http.MethodPost, fmt.Sprintf("/repos/%s/%s/pulls", owner, repo), &req, pr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create pull request in %s/%s", owner, repo)
+	}
+
+	fmt.Printf("Created pull request: repo=%s number=%d\n", repo, pr.Number)
+
+	return pr, nil
+}
+
+// CreateBranch creates a new branch pointing at the given commit.
+func (g *API) CreateBranch(owner, repo, branch, sha string) error {
+	req := struct {
+		Ref string `json:"ref"`
+		Sha string `json:"sha"`
+	}{"refs/heads/" + branch, sha}
+
+	_, err := g.requestJSON(http.MethodPost, fmt.Sprintf("/repos/%s/%s/git/refs", owner, repo), &req, nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not create branch %s in %s/%s", branch, owner, repo)
+	}
+
+	return nil
+}
+
+// CommitFiles commits changes to many files to a branch in a single
+// commit. Files with nil content are deleted.
+func (g *API) CommitFiles(owner, repo, branch string, files []File, message string) (string, error) {
+	base := fmt.Sprintf("/repos/%s/%s/git", owner, repo)
+
+	ref := struct {
+		Object struct {
+			Sha string `json:"sha"`
+		} `json:"object"`
+	}{}
+
+	_, err := g.requestJSON(http.MethodGet, base+"/ref/heads/"+branch, nil, &ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get branch %s in %s/%s", branch, owner, repo)
+	}
+
+	head := struct {
+		Tree struct {
+			Sha string `json:"sha"`
+		} `json:"tree"`
+	}{}
+
+	_, err = g.requestJSON(http.MethodGet, base+"/commits/"+ref.Object.Sha, nil, &head)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not get commit %s in %s/%s", ref.Object.Sha, owner, repo)
+	}
+
+	type treeEntry struct {
+		Path string  `json:"path"`
+		Mode string  `json:"mode"`
+		Type string  `json:"type"`
+		Sha  *string `json:"sha"`
+	}
+
+	var entries []treeEntry
+
+	for _, f := range files {
+		e := treeEntry{Path: f.Path, Mode: "100644", Type: "blob"}
+
+		if f.Content != nil {
+			blob := struct {
+				Sha string `json:"sha"`
+			}{}
+
+			req := struct {
+				Content  string `json:"content"`
+				Encoding string `json:"encoding"`
+			}{base64.StdEncoding.EncodeToString(f.Content), "base64"}
+
+			_, err = g.requestJSON(http.MethodPost, base+"/blobs", &req, &blob)
+			if err != nil {
+				return "", errors.Wrapf(err, "could not create blob for %s in %s/%s", f.Path, owner, repo)
+			}
+
+			e.Sha = &blob.Sha
+		}
+
+		entries = append(entries, e)
+	}
+
+	tree := struct {
+		Sha string `json:"sha"`
+	}{}
+
+	_, err = g.requestJSON(http.MethodPost, base+"/trees", &struct {
+		BaseTree string      `json:"base_tree"`
+		Tree     []treeEntry `json:"tree"`
+	}{head.Tree.Sha, entries}, &tree)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create tree in %s/%s", owner, repo)
+	}
+
+	commit := struct {
+		Sha string `json:"sha"`
+	}{}
+
+	_, err = g.requestJSON(http.MethodPost, base+"/commits", &struct {
+		Message string   `json:"message"`
+		Tree    string   `json:"tree"`
+		Parents []string `json:"parents"`
+	}{message, tree.Sha, []string{ref.Object.Sha}}, &commit)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create commit in %s/%s", owner, repo)
+	}
+
+	_, err = g.requestJSON(http.MethodPatch, base+"/refs/heads/"+branch, &struct {
+		Sha string `json:"sha"`
+	}{commit.Sha}, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not update branch %s in %s/%s", branch, owner, repo)
+	}
+
+	return commit.Sha, nil
+}
+
 // ListBranches lists branches for the given repo.
 func (g *API) ListBranches(username, repo string) (bs []Branch, res *Response, err error) {
 	res, err = g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s/branches", username, repo), nil, &bs)
 	return
 }
 
-// DownloadRepo downloads a zipped branch of a repo.
+// DownloadRepo downloads a zipped branch of a repo. The repo's default
+// branch is downloaded if no branch is given.
 func (g *API) DownloadRepo(username, repo, branch string) (*Response, error) {
-	return g.request(http.MethodGet, fmt.Sprintf("/repos/%s/%s/zipball/%s", username, repo, branch), nil)
+	p := fmt.Sprintf("/repos/%s/%s/zipball", username, repo)
+	if branch != "" {
+		p += "/" + branch
+	}
+	return g.request(http.MethodGet, p, nil)
 }
 
 // DeleteRepo deletes a repo.
@@ -220,45 +484,58 @@ func (g *API) DeleteRepo(username, repo string) (res *Response, err error) {
 func (g *API) Upload(owner, repo string, files []string, message string) error {
 	// Create files in new repo.
 	for _, f := range files {
-		// Get basename.
-		name := path.Base(f)
-
-		// Create Github path.
-		githubPath := fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, name)
-
 		// Read file contents as bytes.
 		content, err := ioutil.ReadFile(f)
 		if err != nil {
 			return errors.Wrapf(err, "could not read file %s", f)
 		}
 
-		req := struct {
-			Message string `json:"message"`
-			Content string `json:"content"`
-		}{
-			Message: message,
-			Content: base64.StdEncoding.EncodeToString(content), // Base64 encode file contents.
-		}
-		res := struct {
-			Content struct {
-				Name string
-				Path string
-				Sha  string
-			}
-			Commit struct {
-				Sha string
-				URL string `json:"url"`
-			}
-		}{}
-
-		_, err = g.requestJSON(http.MethodPut, githubPath, &req, &res)
+		err = g.PutFile(owner, repo, path.Base(f), content, message)
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
+
+// PutFile creates or replaces a single file in a Github repo, which
+// is committed using the given message. The file path may contain
+// directories.
+func (g *API) PutFile(owner, repo, filePath string, content []byte, message string) error {
+	// Create Github path, escaping each path segment.
+	segments := strings.Split(strings.Trim(filePath, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	githubPath := fmt.Sprintf("/repos/%s/%s/contents/%s", owner, repo, strings.Join(segments, "/"))
+
+	req := struct {
+		Message string `json:"message"`
+		Content string `json:"content"`
+	}{
+		Message: message,
+		Content: base64.StdEncoding.EncodeToString(content), // Base64 encode file contents.
+	}
+	res := struct {
+		Content struct {
+			Name string
+			Path string
+			Sha  string
+		}
+		Commit struct {
+			Sha string
+			URL string `json:"url"`
+		}
+	}{}
 
-		fmt.Printf("Uploaded file: %s\n", res.Content.Name)
+	_, err := g.requestJSON(http.MethodPut, githubPath, &req, &res)
+	if err != nil {
+		return errors.Wrapf(err, "could not upload file %s", filePath)
 	}
 
+	fmt.Printf("Uploaded file: %s\n", res.Content.Path)
+
 	return nil
 }
 
@@ -290,6 +567,64 @@ func (g *API) AddCollaborator(owner, repo, candidate string) error {
 	return nil
 }
 
+// RemoveCollaborator removes a collaborator from a repo and cancels
+// any invitations to the repo that the collaborator hasn't accepted yet.
+func (g *API) RemoveCollaborator(owner, repo, candidate string) error {
+	var invites []struct {
+		ID      int64 `json:"id"`
+		Invitee struct {
+			Login string `json:"login"`
+		} `json:"invitee"`
+	}
+
+	_, err := g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s/invitations", owner, repo), nil, &invites)
+	if err != nil {
+		return errors.Wrapf(err, "could not list invitations to %s/%s", owner, repo)
+	}
+
+	for _, i := range invites {
+		if !strings.EqualFold(i.Invitee.Login, candidate) {
+			continue
+		}
+		_, err = g.request(http.MethodDelete, fmt.Sprintf("/repos/%s/%s/invitations/%d", owner, repo, i.ID), nil)
+		if err != nil {
+			return errors.Wrapf(err, "could not cancel invitation %d to %s/%s", i.ID, owner, repo)
+		}
+	}
+
+	_, err = g.request(http.MethodDelete, fmt.Sprintf("/repos/%s/%s/collaborators/%s", owner, repo, candidate), nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove collaborator %s from %s/%s", candidate, owner, repo)
+	}
+
+	fmt.Printf("Removed collaborator: id=%s repo=%s\n", candidate, repo)
+
+	return nil
+}
+
+// HeadCommit returns the name of a repo's default branch and the SHA of
+// its latest commit, which is empty if the repo has no commits.
+func (g *API) HeadCommit(owner, repo string) (branch, sha string, err error) {
+	r := new(Repo)
+
+	_, err = g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s", owner, repo), nil, r)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "could not get repo %s/%s", owner, repo)
+	}
+
+	b := new(Branch)
+
+	_, err = g.requestJSON(http.MethodGet, fmt.Sprintf("/repos/%s/%s/branches/%s", owner, repo, r.DefaultBranch), nil, b)
+	if IsNotFound(err) {
+		return r.DefaultBranch, "", nil
+	}
+	if err != nil {
+		return "", "", errors.Wrapf(err, "could not get branch %s in %s/%s", r.DefaultBranch, owner, repo)
+	}
+
+	return r.DefaultBranch, b.Commit.Sha, nil
+}
+
 // Create a Github API request and unmarshal response JSON into
 // the given struct.
 func (g *API) requestJSON(method, path string, in, out interface{}) (*Response, error) {
@@ -351,7 +686,7 @@ func (g *API) request(method, url string, body io.Reader) (*Response, error) {
 		return nil, errors.Wrap(err, "could not make http client call")
 	}
 	if resp.StatusCode >= http.StatusBadRequest {
-		return nil, errors.Errorf("got Github API error: status_code=%d", resp.StatusCode)
+		return nil, &APIError{resp.StatusCode}
 	}
 
 	data, err := ioutil.ReadAll(resp.Body)
@@ -380,6 +715,23 @@ func (g *API) request(method, url string, body io.Reader) (*Response, error) {
 	return r, nil
 }
 
+// APIError is returned when the Github API responds with an error
+// status code.
+type APIError struct {
+	StatusCode int
+}
+
+// Error ...
+func (e *APIError) Error() string {
+	return fmt.Sprintf("got Github API error: status_code=%d", e.StatusCode)
+}
+
+// IsNotFound returns true if the error is a Github API 404 error.
+func IsNotFound(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
 // Response represents a low-level Github API response.
 type Response struct {
 	Body       []byte
@@ -0,0 +1,71 @@
+package github
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxExtractSize is the max total size of files extracted from a zipball.
+const maxExtractSize = 64 << 20
+
+// File is a file extracted from a repo zipball.
+type File struct {
+	Path    string
+	Content []byte
+}
+
+// ExtractZipball returns all files in a zipball downloaded from Github.
+// Github puts all files in a top-level directory named after the repo
+// and commit, e.g. `owner-repo-1a2b3c4/`, which is stripped from the
+// returned paths.
+func ExtractZipball(data []byte) ([]File, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read zipball")
+	}
+
+	var files []File
+	var total int64
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		// Strip top-level directory.
+		name := f.Name
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[i+1:]
+		}
+
+		name = path.Clean(name)
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, errors.Errorf("invalid file path '%s' in zipball", f.Name)
+		}
+
+		total += int64(f.UncompressedSize64)
+		if total > maxExtractSize {
+			return nil, errors.Errorf("zipball is larger than %d bytes", maxExtractSize)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open %s in zipball", f.Name)
+		}
+
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read %s in zipball", f.Name)
+		}
+
+		files = append(files, File{Path: name, Content: content})
+	}
+
+	return files, nil
+}
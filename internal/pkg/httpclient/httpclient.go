@@ -48,7 +48,8 @@ type Options struct {
 
 // CallWithOptions ...
 func CallWithOptions(o Options) ([]byte, error) {
-	zap.S().Infof("calling url: %s %s  --  token: %s", strings.ToUpper(o.Method), o.URL, o.Token)
+	// Never log the token itself.
+	zap.S().Infof("calling url: %s %s  --  with token: %t", strings.ToUpper(o.Method), o.URL, o.Token != "")
 
 	client := &http.Client{}
 
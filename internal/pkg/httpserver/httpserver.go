@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/anrid/codecoach/internal/domain"
+	token_gen "github.com/anrid/codecoach/internal/pkg/token"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -21,10 +22,13 @@ import (
 type HTTPServer struct {
 	Echo    *echo.Echo
 	Private *echo.Group
+	// scopes are the scopes API keys need to call private routes, by
+	// method and path.
+	scopes map[string]domain.Scope
 }
 
 // New ...
-func New(up UserProvider) *HTTPServer {
+func New(up UserProvider, sp SessionProvider, kp APIKeyProvider, tokenSecret string) *HTTPServer {
 	// Echo instance.
 	e := echo.New()
 
@@ -40,19 +44,45 @@ func New(up UserProvider) *HTTPServer {
 	e.Use(middleware.CORS())
 
 	// Setup private group, i.e. for routes that require a
-	// valid user session.
-	g := e.Group("", NewAuthMiddleware(up))
+	// valid user session or API key.
+	scopes := make(map[string]domain.Scope)
+	g := e.Group("", NewAuthMiddleware(up, sp, kp, tokenSecret, scopes))
 
 	// Set default root endpoint.
 	e.GET("/", getRoot)
 	e.GET("/ace", getRoot)
 
-	return &HTTPServer{e, g}
+	return &HTTPServer{e, g, scopes}
+}
+
+// Scoped lets API keys with the given scope call a private route.
+// API keys can't call private routes that aren't scoped, e.g.
+// `s.Scoped(domain.ScopeUsersRead, s.Private.GET(...))`.
+func (s *HTTPServer) Scoped(scope domain.Scope, r *echo.Route) {
+	s.scopes[routeKey(r.Method, r.Path)] = scope
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
 }
 
 // Start ...
 func (s *HTTPServer) Start(host string) {
-	s.Echo.Logger.Fatal(s.Echo.Start(host))
+	err := s.Echo.Start(host)
+	if err != nil && err != http.ErrServerClosed {
+		s.Echo.Logger.Fatal(err)
+	}
+}
+
+// Shutdown stops the server, waiting up to timeout for requests in
+// flight to finish.
+func (s *HTTPServer) Shutdown(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := s.Echo.Shutdown(ctx); err != nil {
+		zap.S().Warnw("could not shut down http server gracefully", "error", err)
+	}
 }
 
 // getRoot ...
@@ -164,16 +194,38 @@ func UnescapedJSON(c echo.Context, code int, payload interface{}) error {
 	return enc.Encode(payload)
 }
 
+// ClientInfo returns the user agent and IP of the client making
+// the request.
+func ClientInfo(c echo.Context) domain.ClientInfo {
+	return domain.ClientInfo{
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+	}
+}
+
 // UserProvider ...
 type UserProvider interface {
-	GetByToken(ctx context.Context, token string) (*domain.User, error)
+	Get(ctx context.Context, accountID, id domain.ID) (*domain.User, error)
+}
+
+// SessionProvider ...
+type SessionProvider interface {
+	GetByTokenHash(ctx context.Context, tokenHash string) (*domain.UserSession, error)
+	Touch(ctx context.Context, accountID, id domain.ID, at time.Time) error
+}
+
+// APIKeyProvider ...
+type APIKeyProvider interface {
+	GetByKeyHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	Touch(ctx context.Context, accountID, id domain.ID, at time.Time) error
 }
 
 // NewAuthMiddleware creates a new instance of Echo middleware
 // that checks for the presence of a token in the Authorization
 // HTTP header (e.g. `Authorization: Bearer XXX`) and looks up
-// a user by the token.
-func NewAuthMiddleware(up UserProvider) echo.MiddlewareFunc {
+// a user session or, for API keys, the key by the token's hash.
+// API keys are only accepted on routes with a scope the key has.
+func NewAuthMiddleware(up UserProvider, sp SessionProvider, kp APIKeyProvider, tokenSecret string, scopes map[string]domain.Scope) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			ctx := c.Request().Context()
@@ -190,23 +242,100 @@ func NewAuthMiddleware(up UserProvider) echo.MiddlewareFunc {
 
 			token := auth[7:]
 
-			u, err := up.GetByToken(ctx, token)
+			if domain.IsAPIKey(token) {
+				scope, ok := scopes[routeKey(c.Request().Method, c.Path())]
+				if !ok {
+					return NewError(http.StatusForbidden, errors.New("api key used on unscoped route"), "api keys cannot be used here")
+				}
+
+				u, k, err := authenticateAPIKey(ctx, up, kp, token_gen.Hash(tokenSecret, token), domain.ID(accountID))
+				if err != nil {
+					return err
+				}
+
+				if !k.Scopes.Has(scope) {
+					return NewError(http.StatusForbidden, errors.Errorf("api key %s.%s is missing scope %s", k.AccountID, k.ID, scope), "api key is missing scope "+string(scope))
+				}
+
+				c.SetRequest(c.Request().WithContext(domain.ContextWithSession(ctx, u, nil, k)))
+
+				return next(c)
+			}
+
+			us, err := sp.GetByTokenHash(ctx, token_gen.Hash(tokenSecret, token))
 			if err != nil {
 				return errors.Wrap(err, "token invalid")
 			}
 
-			if domain.ID(accountID) != u.AccountID {
+			if domain.ID(accountID) != us.AccountID {
 				return errors.New("account invalid")
 			}
 
-			if u.TokenExpiresAt.Before(time.Now()) {
+			now := time.Now()
+			if us.Expired(now) {
 				return errors.New("token expired")
 			}
 
+			u, err := up.Get(ctx, us.AccountID, us.UserID)
+			if err != nil {
+				return errors.Wrap(err, "token invalid")
+			}
+
+			// Keep track of when the session was last used, without
+			// writing to the DB on every request.
+			if now.Sub(us.LastSeenAt) >= domain.SessionTouchInterval {
+				if err := sp.Touch(ctx, us.AccountID, us.ID, now); err != nil {
+					zap.S().Warnw("could not touch session", "session", us.ID, "error", err)
+				} else {
+					us.LastSeenAt = now
+				}
+			}
+
 			// Replace current request object
-			c.SetRequest(c.Request().WithContext(domain.ContextWithSession(ctx, u)))
+			c.SetRequest(c.Request().WithContext(domain.ContextWithSession(ctx, u, us, nil)))
 
 			return next(c)
 		}
 	}
 }
+
+// authenticateAPIKey looks up an API key by its hash and the admin who
+// created it, who must still be an admin.
+func authenticateAPIKey(ctx context.Context, up UserProvider, kp APIKeyProvider, keyHash string, accountID domain.ID) (*domain.User, *domain.APIKey, error) {
+	k, err := kp.GetByKeyHash(ctx, keyHash)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "api key invalid")
+	}
+
+	if accountID != k.AccountID {
+		return nil, nil, errors.New("account invalid")
+	}
+
+	now := time.Now()
+	if k.Expired(now) {
+		return nil, nil, errors.New("api key expired")
+	}
+
+	u, err := up.Get(ctx, k.AccountID, k.CreatedBy)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "api key invalid")
+	}
+
+	// Keys act as their creator, so they stop working once the creator
+	// is no longer an admin.
+	if !u.HasRole(domain.RoleAdmin) {
+		return nil, nil, errors.Errorf("api key invalid: creator %s.%s is no longer an admin", u.AccountID, u.ID)
+	}
+
+	// Keep track of when the key was last used, without writing to the
+	// DB on every request.
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= domain.APIKeyTouchInterval {
+		if err := kp.Touch(ctx, k.AccountID, k.ID, now); err != nil {
+			zap.S().Warnw("could not touch api key", "api_key", k.ID, "error", err)
+		} else {
+			k.LastUsedAt = &now
+		}
+	}
+
+	return u, k, nil
+}
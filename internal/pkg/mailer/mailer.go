@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Message ...
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Log is a Mailer that only logs who messages are sent to. It's used
+// when no mail server has been configured. Bodies aren't logged, since
+// they may contain secrets such as password reset links.
+type Log struct{}
+
+var _ Mailer = &Log{}
+
+// NewLog ...
+func NewLog() *Log {
+	return &Log{}
+}
+
+// Send ...
+func (l *Log) Send(ctx context.Context, m Message) error {
+	zap.S().Infow("sending email", "to", m.To, "subject", m.Subject)
+	return nil
+}
@@ -0,0 +1,391 @@
+// Package metrics computes static code quality metrics for Go code:
+// cyclomatic complexity, function length, duplication, package coupling
+// and missing doc comments. Test files, vendored code and test data are
+// left out.
+package metrics
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ComplexityThreshold is the cyclomatic complexity above which a
+	// function is considered complex.
+	ComplexityThreshold = 10
+	// LengthThreshold is the number of lines above which a function is
+	// considered long.
+	LengthThreshold = 50
+	// duplicateWindow is the number of tokens in a row that must be
+	// repeated to count as duplication.
+	duplicateWindow = 50
+	// maxHotspots is the number of most complex functions reported.
+	maxHotspots = 5
+)
+
+// Report ...
+type Report struct {
+	Files     int
+	Lines     int
+	Functions int
+	// ParseErrors is the number of files that couldn't be parsed and
+	// were left out.
+	ParseErrors       int
+	AvgComplexity     float64
+	MaxComplexity     int
+	ComplexFunctions  int
+	AvgFunctionLength float64
+	MaxFunctionLength int
+	LongFunctions     int
+	// Duplication is the share of tokens that are part of a sequence of
+	// tokens repeated elsewhere, ignoring identifier names and literal
+	// values.
+	Duplication float64
+	Packages    int
+	// AvgCoupling is the average number of other packages in the same
+	// module, or non-standard library packages if there's no go.mod,
+	// imported by each package.
+	AvgCoupling float64
+	MaxCoupling int
+	// LintFindings is the number of exported declarations without a doc
+	// comment.
+	LintFindings int
+	Hotspots     []Function
+}
+
+// Function ...
+type Function struct {
+	Name       string
+	File       string
+	Line       int
+	Lines      int
+	Complexity int
+}
+
+// Analyze computes metrics for all Go files in files, which maps file
+// paths to their content.
+func Analyze(files map[string][]byte) *Report {
+	r := &Report{}
+
+	module := modulePath(files["go.mod"])
+	fset := token.NewFileSet()
+
+	var funcs []Function
+	imports := make(map[string]map[string]bool)
+	dup := newDuplicates()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !isSource(name) {
+			continue
+		}
+		src := files[name]
+
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			r.ParseErrors++
+			continue
+		}
+
+		r.Files++
+		r.Lines += countLines(src)
+
+		dir := path.Dir(name)
+		if imports[dir] == nil {
+			imports[dir] = make(map[string]bool)
+		}
+		for _, is := range f.Imports {
+			p, _ := strconv.Unquote(is.Path.Value)
+			if isDependency(p, module) {
+				imports[dir][p] = true
+			}
+		}
+
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && fd.Body != nil {
+				funcs = append(funcs, Function{
+					Name:       funcName(fd),
+					File:       name,
+					Line:       fset.Position(fd.Pos()).Line,
+					Lines:      fset.Position(fd.End()).Line - fset.Position(fd.Pos()).Line + 1,
+					Complexity: complexity(fd.Body),
+				})
+			}
+		}
+
+		r.LintFindings += undocumented(f)
+		dup.add(fset, src)
+	}
+
+	r.Functions = len(funcs)
+	if len(funcs) > 0 {
+		var complexity, length int
+		for _, fn := range funcs {
+			complexity += fn.Complexity
+			length += fn.Lines
+			if fn.Complexity > r.MaxComplexity {
+				r.MaxComplexity = fn.Complexity
+			}
+			if fn.Complexity > ComplexityThreshold {
+				r.ComplexFunctions++
+			}
+			if fn.Lines > r.MaxFunctionLength {
+				r.MaxFunctionLength = fn.Lines
+			}
+			if fn.Lines > LengthThreshold {
+				r.LongFunctions++
+			}
+		}
+		r.AvgComplexity = float64(complexity) / float64(len(funcs))
+		r.AvgFunctionLength = float64(length) / float64(len(funcs))
+	}
+
+	sort.SliceStable(funcs, func(i, j int) bool { return funcs[i].Complexity > funcs[j].Complexity })
+	if len(funcs) > maxHotspots {
+		funcs = funcs[:maxHotspots]
+	}
+	r.Hotspots = funcs
+
+	r.Packages = len(imports)
+	if len(imports) > 0 {
+		var total int
+		for _, ps := range imports {
+			total += len(ps)
+			if len(ps) > r.MaxCoupling {
+				r.MaxCoupling = len(ps)
+			}
+		}
+		r.AvgCoupling = float64(total) / float64(len(imports))
+	}
+
+	r.Duplication = dup.ratio()
+
+	return r
+}
+
+// isSource returns true for Go files that aren't tests, vendored code
+// or test data.
+func isSource(name string) bool {
+	if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == "vendor" || dir == "testdata" {
+			return false
+		}
+	}
+	return true
+}
+
+// modulePath returns the module path declared in a go.mod file.
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// isDependency returns true if the import path p counts towards a
+// package's coupling.
+func isDependency(p, module string) bool {
+	if module != "" {
+		return p == module || strings.HasPrefix(p, module+"/")
+	}
+	return strings.Contains(strings.Split(p, "/")[0], ".")
+}
+
+func countLines(src []byte) int {
+	var n int
+	for _, line := range bytes.Split(src, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+
+	t := fd.Recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	if ix, ok := t.(*ast.IndexExpr); ok {
+		t = ix.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+
+	return fd.Name.Name
+}
+
+// complexity returns the cyclomatic complexity of a function body, i.e.
+// one plus the number of branches. Branches in function literals count
+// towards the enclosing function.
+func complexity(body *ast.BlockStmt) int {
+	c := 1
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			c++
+		case *ast.CaseClause:
+			if n.List != nil {
+				c++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				c++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				c++
+			}
+		}
+		return true
+	})
+
+	return c
+}
+
+// undocumented returns the number of exported top-level declarations
+// without a doc comment.
+func undocumented(f *ast.File) int {
+	var n int
+
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Name.IsExported() && d.Doc == nil {
+				n++
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, s := range d.Specs {
+				var exported bool
+				var doc *ast.CommentGroup
+
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					exported, doc = s.Name.IsExported(), s.Doc
+				case *ast.ValueSpec:
+					for _, id := range s.Names {
+						exported = exported || id.IsExported()
+					}
+					doc = s.Doc
+				}
+
+				if exported && doc == nil && d.Doc == nil {
+					n++
+				}
+			}
+		}
+	}
+
+	return n
+}
+
+// duplicates finds sequences of tokens that occur more than once across
+// files.
+type duplicates struct {
+	files  []hashedFile
+	counts map[uint64]int
+}
+
+// hashedFile holds the hashes of every window of tokens in a file.
+type hashedFile struct {
+	hashes []uint64
+	tokens int
+}
+
+func newDuplicates() *duplicates {
+	return &duplicates{counts: make(map[uint64]int)}
+}
+
+// add hashes every window of tokens in a file. Identifiers and literals
+// are normalized so that renamed copies count as duplicates.
+func (d *duplicates) add(fset *token.FileSet, src []byte) {
+	var toks []string
+
+	var s scanner.Scanner
+	s.Init(fset.AddFile("", fset.Base(), len(src)), src, nil, 0)
+	for {
+		_, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		switch {
+		case tok == token.IDENT:
+			toks = append(toks, "id")
+		case tok.IsLiteral():
+			toks = append(toks, "lit")
+		default:
+			toks = append(toks, tok.String())
+		}
+	}
+
+	var hashes []uint64
+	for i := 0; i+duplicateWindow <= len(toks); i++ {
+		h := fnv.New64a()
+		for _, t := range toks[i : i+duplicateWindow] {
+			h.Write([]byte(t))
+			h.Write([]byte{0})
+		}
+		sum := h.Sum64()
+		hashes = append(hashes, sum)
+		d.counts[sum]++
+	}
+
+	d.files = append(d.files, hashedFile{hashes, len(toks)})
+}
+
+// ratio returns the share of tokens covered by a repeated window.
+func (d *duplicates) ratio() float64 {
+	var total, duplicated int
+
+	for _, f := range d.files {
+		total += f.tokens
+
+		covered := make([]bool, f.tokens)
+		for i, h := range f.hashes {
+			if d.counts[h] < 2 {
+				continue
+			}
+			for j := i; j < i+duplicateWindow; j++ {
+				covered[j] = true
+			}
+		}
+		for _, c := range covered {
+			if c {
+				duplicated++
+			}
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(duplicated) / float64(total)
+}
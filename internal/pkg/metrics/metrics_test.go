@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComplexity(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "straight line", body: "x := 1\n_ = x", want: 1},
+		{name: "if else", body: "if a {\n} else if b {\n}", want: 3},
+		{name: "loops", body: "for {\n}\nfor range xs {\n}", want: 3},
+		{name: "boolean operators", body: "_ = a && b || c", want: 3},
+		{name: "switch without default", body: "switch x {\ncase 1:\ncase 2, 3:\ndefault:\n}", want: 3},
+		{name: "select", body: "select {\ncase <-c:\ndefault:\n}", want: 2},
+		{name: "function literals count", body: "f := func() {\nif a {\n}\n}\n_ = f", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			src := "package p\n\nfunc f() {\n" + tt.body + "\n}\n"
+			f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+			r.NoError(err)
+
+			r.Equal(tt.want, complexity(f.Decls[0].(*ast.FuncDecl).Body))
+		})
+	}
+}
+
+func TestIsSource(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: "main.go", want: true},
+		{name: "pkg/util.go", want: true},
+		{name: "main_test.go", want: false},
+		{name: "README.md", want: false},
+		{name: "vendor/example.com/dep/dep.go", want: false},
+		{name: "pkg/testdata/fixture.go", want: false},
+		{name: "vendored/dep.go", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isSource(tt.name))
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	// long is a function of more than LengthThreshold identical lines.
+	long := "func Long() {\n" + strings.Repeat("\tprintln()\n", LengthThreshold) + "}\n"
+
+	// twice holds the same code twice under different names, which is
+	// long enough to count as duplication.
+	twice := "package p\n"
+	for _, name := range []string{"a", "b"} {
+		twice += "\nfunc " + name + "(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tif xs[i] > 10 && xs[i] < 20 {\n\t\t\tn += xs[i] * 2\n\t\t}\n\t}\n\treturn n\n}\n"
+	}
+
+	tests := []struct {
+		name       string
+		files      map[string]string
+		want       Report
+		duplicated bool
+	}{
+		{
+			name:  "no Go files",
+			files: map[string]string{"README.md": "# Hi\n"},
+			want:  Report{},
+		},
+		{
+			name: "parse errors are counted and left out",
+			files: map[string]string{
+				"main.go":   "package main\n\nfunc main() {}\n",
+				"broken.go": "package main\n\nfunc {\n",
+			},
+			want: Report{Files: 1, Lines: 2, Functions: 1, ParseErrors: 1, AvgComplexity: 1, MaxComplexity: 1, AvgFunctionLength: 1, MaxFunctionLength: 1, Packages: 1},
+		},
+		{
+			name: "tests and vendored code are left out",
+			files: map[string]string{
+				"main.go":              "package main\n\nfunc main() {}\n",
+				"main_test.go":         "package main\n\nfunc TestMain() {}\n",
+				"vendor/dep/dep.go":    "package dep\n\nfunc Dep() {}\n",
+				"testdata/fixture.go":  "package fixture\n\nfunc Fixture() {}\n",
+				"internal/util/lib.go": "package util\n\n// Util ...\nfunc Util() {}\n",
+			},
+			want: Report{Files: 2, Lines: 5, Functions: 2, AvgComplexity: 1, MaxComplexity: 1, AvgFunctionLength: 1, MaxFunctionLength: 1, Packages: 2},
+		},
+		{
+			name: "exported declarations without doc comments",
+			files: map[string]string{
+				"lib.go": "package lib\n\nfunc Exported() {}\n\nfunc unexported() {}\n\n// Documented ...\nfunc Documented() {}\n\ntype T int\n\nconst (\n\t// A ...\n\tA = 1\n\tB = 2\n)\n\n// C ...\nvar C, d int\n",
+			},
+			want: Report{Files: 1, Lines: 13, Functions: 3, AvgComplexity: 1, MaxComplexity: 1, AvgFunctionLength: 1, MaxFunctionLength: 1, Packages: 1, LintFindings: 3},
+		},
+		{
+			name: "long functions",
+			files: map[string]string{
+				"long.go": "package p\n\n// Long ...\n" + long,
+			},
+			want:       Report{Files: 1, Lines: LengthThreshold + 4, Functions: 1, AvgComplexity: 1, MaxComplexity: 1, AvgFunctionLength: LengthThreshold + 2, MaxFunctionLength: LengthThreshold + 2, LongFunctions: 1, Packages: 1},
+			duplicated: true,
+		},
+		{
+			name: "coupling counts packages in the module",
+			files: map[string]string{
+				"go.mod":     "module example.com/app\n",
+				"main.go":    "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/a\"\n\t\"example.com/app/b\"\n\t\"github.com/x/y\"\n)\n",
+				"a/a.go":     "package a\n\nimport \"example.com/app/b\"\n",
+				"b/b.go":     "package b\n",
+				"b/other.go": "package b\n\nimport \"example.com/app/a\"\n",
+			},
+			want: Report{Files: 4, Lines: 12, Packages: 3, AvgCoupling: 4.0 / 3, MaxCoupling: 2},
+		},
+		{
+			name: "coupling counts non-standard packages without a go.mod",
+			files: map[string]string{
+				"main.go": "package main\n\nimport (\n\t\"fmt\"\n\t\"github.com/x/y\"\n)\n",
+			},
+			want: Report{Files: 1, Lines: 5, Packages: 1, AvgCoupling: 1, MaxCoupling: 1},
+		},
+		{
+			name:       "renamed copies count as duplication",
+			files:      map[string]string{"p.go": twice},
+			want:       Report{Files: 1, Lines: 19, Functions: 2, AvgComplexity: 4, MaxComplexity: 4, AvgFunctionLength: 9, MaxFunctionLength: 9, Packages: 1},
+			duplicated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			files := make(map[string][]byte)
+			for name, src := range tt.files {
+				files[name] = []byte(src)
+			}
+
+			got := Analyze(files)
+
+			if tt.duplicated {
+				r.Greater(got.Duplication, 0.5)
+			} else {
+				r.Zero(got.Duplication)
+			}
+			got.Duplication = 0
+			got.Hotspots = nil
+
+			r.Equal(tt.want, *got)
+		})
+	}
+}
+
+func TestAnalyzeHotspots(t *testing.T) {
+	r := require.New(t)
+
+	src := "package p\n"
+	for i, body := range []string{"", "if a {}", "if a {}\nif b {}", "for {}", "_ = a && b && c", "switch {\ncase a:\ncase b:\ncase c:\ncase d:\n}"} {
+		src += "\nfunc f" + string(rune('a'+i)) + "() {\n" + body + "\n}\n"
+	}
+
+	got := Analyze(map[string][]byte{"p.go": []byte(src)})
+
+	var names []string
+	for _, fn := range got.Hotspots {
+		names = append(names, fn.Name)
+	}
+	r.Equal([]string{"ff", "fc", "fe", "fb", "fd"}, names)
+	r.Equal(5, got.Hotspots[0].Complexity)
+	r.Equal("p.go", got.Hotspots[0].File)
+}
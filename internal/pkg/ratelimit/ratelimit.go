@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to a number of events per key in a sliding time
+// window. It's kept in memory, so each server has its own limits.
+type Limiter struct {
+	mux     sync.Mutex
+	limit   int
+	window  time.Duration
+	events  map[string][]time.Time
+	cleaned time.Time
+	now     func() time.Time
+}
+
+// New returns a Limiter that allows limit events per key per window.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		events: make(map[string][]time.Time),
+		now:    time.Now,
+	}
+}
+
+// Allow records an event for key and returns true, unless key has
+// used up its events in the current window.
+func (l *Limiter) Allow(key string) bool {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := l.now()
+	since := now.Add(-l.window)
+
+	// Forget keys that haven't been seen for a whole window now and
+	// then, so that the map doesn't keep growing.
+	if now.Sub(l.cleaned) >= l.window {
+		for k, es := range l.events {
+			if !es[len(es)-1].After(since) {
+				delete(l.events, k)
+			}
+		}
+		l.cleaned = now
+	}
+
+	es := l.events[key]
+	for len(es) > 0 && !es[0].After(since) {
+		es = es[1:]
+	}
+
+	if len(es) >= l.limit {
+		l.events[key] = es
+		return false
+	}
+
+	l.events[key] = append(es, now)
+
+	return true
+}
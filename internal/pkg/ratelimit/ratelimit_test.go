@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		calls []time.Duration // since start
+		keys  []string
+		want  []bool
+	}{
+		{
+			name:  "allows up to the limit",
+			calls: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+			keys:  []string{"a", "a", "a", "a"},
+			want:  []bool{true, true, true, false},
+		},
+		{
+			name:  "keys have separate limits",
+			calls: []time.Duration{0, 0, 0, 0},
+			keys:  []string{"a", "a", "a", "b"},
+			want:  []bool{true, true, true, true},
+		},
+		{
+			name:  "events expire after the window",
+			calls: []time.Duration{0, time.Second, 2 * time.Second, time.Minute, time.Minute + time.Second},
+			keys:  []string{"a", "a", "a", "a", "a"},
+			want:  []bool{true, true, true, true, true},
+		},
+		{
+			name:  "denied events don't count",
+			calls: []time.Duration{0, 0, 0, 0, 0, time.Minute},
+			keys:  []string{"a", "a", "a", "a", "a", "a"},
+			want:  []bool{true, true, true, false, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			l := New(3, time.Minute)
+
+			var got []bool
+			for i, d := range tt.calls {
+				now := start.Add(d)
+				l.now = func() time.Time { return now }
+				got = append(got, l.Allow(tt.keys[i]))
+			}
+
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestForgetsIdleKeys(t *testing.T) {
+	r := require.New(t)
+
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l := New(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	r.True(l.Allow("a"))
+	r.True(l.Allow("b"))
+	r.Len(l.events, 2)
+
+	now = now.Add(2 * time.Minute)
+
+	r.True(l.Allow("c"))
+	r.Len(l.events, 1)
+}
@@ -0,0 +1,188 @@
+// Package sandbox runs untrusted commands, e.g. a candidate's tests, in
+// an isolated local process with CPU, memory, process and time limits
+// and no network access.
+//
+// The sandbox is a process in its own user, mount, PID and network
+// namespaces, not a container. Its root file system only holds
+// read-only system and toolchain dirs and the dir the command runs in,
+// so it can't read the server's config or other candidates' code.
+// Commands run as the server's user, or as nobody if the server runs as
+// root.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limits ...
+type Limits struct {
+	// Timeout is the max wall clock time a command can run.
+	Timeout time.Duration
+	// CPUTime is the max CPU time of each process started by a command.
+	CPUTime time.Duration
+	// MemoryMB is the max virtual memory of each process started by a
+	// command.
+	MemoryMB int
+	// Processes is the max number of processes and threads a command
+	// can run at once.
+	Processes int
+	// MaxOutput is the max number of bytes of stdout and stderr kept.
+	MaxOutput int
+	// Toolchain holds absolute paths of dirs, besides the system dirs,
+	// that commands can read, e.g. where Go is installed.
+	Toolchain []string
+}
+
+// Result ...
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	TimedOut bool
+	Duration time.Duration
+}
+
+// Sandbox ...
+type Sandbox struct {
+	l Limits
+}
+
+// New ...
+func New(l Limits) *Sandbox {
+	if l.MaxOutput <= 0 {
+		l.MaxOutput = 1 << 20
+	}
+	return &Sandbox{l}
+}
+
+// Run runs a shell command in dir, which should be a temporary directory
+// holding nothing but the code under test. If out is set, it's another
+// dir the command can write to, e.g. to leave a report that the code
+// under test can't have planted in dir. Its path is in the command's
+// OUT_DIR env var. A command exiting with a non-zero code or running out
+// of time isn't an error; see Result.
+func (s *Sandbox) Run(ctx context.Context, dir, out, command string) (*Result, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid sandbox dir")
+	}
+
+	dirs := []string{dir}
+	if out != "" {
+		out, err = filepath.Abs(out)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid sandbox out dir")
+		}
+		dirs = append(dirs, out)
+	}
+
+	// Limits are set with ulimit in a wrapper shell, which then runs the
+	// command passed as its first argument.
+	script := "ulimit -c 0"
+	if s.l.CPUTime > 0 {
+		script += " && ulimit -t " + strconv.Itoa(int(s.l.CPUTime.Seconds()))
+	}
+	if s.l.MemoryMB > 0 {
+		script += " && ulimit -v " + strconv.Itoa(s.l.MemoryMB*1024)
+	}
+	script += ` && exec /bin/sh -c "$0"`
+
+	// The sandbox's root file system is mounted here, but only inside
+	// the sandbox, so it stays empty.
+	root, err := ioutil.TempDir("", "sandbox-root")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create sandbox root dir")
+	}
+	defer os.RemoveAll(root)
+
+	cmd, err := isolated(root, dirs, s.l, "/bin/sh", "-c", script, command)
+	if err != nil {
+		return nil, err
+	}
+	// Caches go in the sandbox's own /tmp rather than dir, where the
+	// code under test could have planted them.
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=/tmp",
+		"TMPDIR=/tmp",
+		"GOPATH=/tmp/go",
+		"GOCACHE=/tmp/go-build",
+		"GOPROXY=off",
+		"CGO_ENABLED=0",
+		"OUT_DIR=" + out,
+	}
+
+	stdout := &capped{max: s.l.MaxOutput}
+	stderr := &capped{max: s.l.MaxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if s.l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.l.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not start sandbox")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	res := &Result{}
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		kill(cmd.Process)
+		err = <-done
+		res.TimedOut = true
+	}
+
+	res.Duration = time.Since(start)
+	res.Stdout = stdout.Bytes()
+	res.Stderr = stderr.Bytes()
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+
+	var ee *exec.ExitError
+	if err != nil && !errors.As(err, &ee) {
+		return nil, errors.Wrap(err, "could not run sandbox")
+	}
+
+	return res, nil
+}
+
+// capped is a buffer that discards everything written to it after the
+// first max bytes.
+type capped struct {
+	bytes.Buffer
+	max int
+}
+
+// Write ...
+func (c *capped) Write(p []byte) (int, error) {
+	if n := c.max - c.Len(); n > 0 {
+		if len(p) > n {
+			c.Buffer.Write(p[:n])
+		} else {
+			c.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
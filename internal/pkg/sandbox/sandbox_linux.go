@@ -0,0 +1,334 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// initArg is the name the server re-runs itself under to set up the
+// sandbox from inside its namespaces before running a command.
+const initArg = "codecoach-sandbox-init"
+
+// Constants package syscall doesn't define.
+const (
+	prSetNoNewPrivs = 38
+	rlimitNproc     = 6
+)
+
+// nobody is the user and group commands run as if the server runs as
+// root.
+const nobody = 65534
+
+// systemDirs are bound read-only into every sandbox, if they exist, so
+// that shells, compilers and the libraries they link to work.
+var systemDirs = []string{
+	"/bin",
+	"/sbin",
+	"/usr",
+	"/lib",
+	"/lib32",
+	"/lib64",
+	"/libx32",
+	"/etc/alternatives",
+	"/etc/ssl",
+	"/etc/ca-certificates",
+	"/etc/ld.so.cache",
+	"/etc/passwd",
+	"/etc/group",
+	"/etc/nsswitch.conf",
+}
+
+// devices are bound into every sandbox, if they exist.
+var devices = []string{
+	"/dev/null",
+	"/dev/zero",
+	"/dev/full",
+	"/dev/random",
+	"/dev/urandom",
+}
+
+func init() {
+	if len(os.Args) == 0 || os.Args[0] != initArg {
+		return
+	}
+
+	// Capabilities are per thread, so they must be dropped on the thread
+	// that runs the command.
+	runtime.LockOSThread()
+
+	err := enter(os.Args[1:])
+	fmt.Fprintln(os.Stderr, "sandbox:", err)
+	os.Exit(126)
+}
+
+// isolated returns a command that re-runs the server in new user, mount,
+// PID, network, IPC and UTS namespaces, in its own process group so that
+// it can be killed along with all of its children. There it builds a new
+// root file system in root, holding read-only binds of the system and
+// toolchain dirs, writable binds of dirs and a private /tmp, switches to
+// it, limits the number of processes and runs argv in the first of
+// dirs. The command can't see any other files on the machine and has no
+// network, since its loopback interface is down.
+func isolated(root string, dirs []string, l Limits, argv ...string) (*exec.Cmd, error) {
+	for _, d := range l.Toolchain {
+		if !filepath.IsAbs(d) {
+			return nil, errors.Errorf("sandbox toolchain dir '%s' is not an absolute path", d)
+		}
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+
+	// The command runs as root in the user namespace, so that it may set
+	// up mounts in the mount namespace. It drops all capabilities before
+	// running argv.
+	cred := &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true}
+
+	// Root isn't held to the process limit, even in a user namespace, so
+	// a server running as root runs commands as nobody, without root's
+	// groups. Nobody then needs to own the dirs the sandbox is set up in.
+	if uid == 0 {
+		uid, gid = nobody, nobody
+		cred = &syscall.Credential{Uid: 0, Gid: 0, Groups: []uint32{}}
+
+		if err := os.Chown(root, uid, gid); err != nil {
+			return nil, errors.Wrap(err, "could not hand sandbox root dir to nobody")
+		}
+		for _, d := range dirs {
+			err := filepath.Walk(d, func(path string, _ os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				return os.Lchown(path, uid, gid)
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not hand sandbox dir %s to nobody", d)
+			}
+		}
+	}
+
+	cmd := exec.Command("/proc/self/exe")
+	cmd.Args = append([]string{
+		initArg,
+		root,
+		strings.Join(dirs, string(os.PathListSeparator)),
+		strings.Join(l.Toolchain, string(os.PathListSeparator)),
+		strconv.Itoa(l.Processes),
+	}, argv...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		Credential: cred,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: uid, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: gid, Size: 1},
+		},
+		GidMappingsEnableSetgroups: !cred.NoSetGroups,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	return cmd, nil
+}
+
+// enter sets up the sandbox and runs a command in it. It only returns if
+// that fails.
+func enter(args []string) error {
+	if len(args) < 5 {
+		return errors.Errorf("expected root, dirs, toolchain, processes and command args")
+	}
+	root, dirs, toolchain, argv := args[0], filepath.SplitList(args[1]), filepath.SplitList(args[2]), args[4:]
+	if len(dirs) == 0 {
+		return errors.Errorf("missing dir")
+	}
+
+	processes, err := strconv.Atoi(args[3])
+	if err != nil {
+		return errors.Wrap(err, "invalid processes arg")
+	}
+
+	// Keep mounts from propagating back to the server's namespace.
+	err = syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+	if err != nil {
+		return errors.Wrap(err, "could not make mounts private")
+	}
+
+	err = syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755")
+	if err != nil {
+		return errors.Wrap(err, "could not mount root")
+	}
+
+	err = mount(root, "/tmp", "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777")
+	if err != nil {
+		return err
+	}
+
+	for _, d := range append(systemDirs, toolchain...) {
+		if err := bind(root, d, true); err != nil {
+			return err
+		}
+	}
+	for _, d := range devices {
+		if err := bind(root, d, false); err != nil {
+			return err
+		}
+	}
+	for _, d := range dirs {
+		if err := bind(root, d, false); err != nil {
+			return err
+		}
+	}
+
+	err = mount(root, "/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+	if err != nil {
+		return err
+	}
+
+	// Switch to the new root and detach the old one, so that nothing
+	// outside the sandbox can be reached anymore.
+	old := filepath.Join(root, ".old")
+	if err := os.Mkdir(old, 0700); err != nil {
+		return errors.Wrap(err, "could not create old root dir")
+	}
+	if err := syscall.PivotRoot(root, old); err != nil {
+		return errors.Wrap(err, "could not pivot root")
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return errors.Wrap(err, "could not change to new root")
+	}
+	if err := syscall.Unmount("/.old", syscall.MNT_DETACH); err != nil {
+		return errors.Wrap(err, "could not unmount old root")
+	}
+	if err := os.Remove("/.old"); err != nil {
+		return errors.Wrap(err, "could not remove old root dir")
+	}
+
+	err = syscall.Mount("", "/", "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|syscall.MS_NOSUID|syscall.MS_NODEV, "")
+	if err != nil {
+		return errors.Wrap(err, "could not make root read-only")
+	}
+
+	if err := syscall.Chdir(dirs[0]); err != nil {
+		return errors.Wrapf(err, "could not change to dir %s", dirs[0])
+	}
+
+	// The limit counts the processes of the sandbox's user namespace
+	// only, and root in it has no capabilities left to get around it.
+	if processes > 0 {
+		lim := &syscall.Rlimit{Cur: uint64(processes), Max: uint64(processes)}
+		if err := syscall.Setrlimit(rlimitNproc, lim); err != nil {
+			return errors.Wrap(err, "could not limit processes")
+		}
+	}
+
+	// Drop all capabilities from the bounding set, so that the command
+	// runs without any, even as root in the user namespace.
+	for c := 0; ; c++ {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_CAPBSET_DROP, uintptr(c), 0)
+		if errno == syscall.EINVAL {
+			break
+		}
+		if errno != 0 {
+			return errors.Wrapf(errno, "could not drop capability %d", c)
+		}
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0)
+	if errno != 0 {
+		return errors.Wrap(errno, "could not set no new privileges")
+	}
+
+	err = syscall.Exec(argv[0], argv, os.Environ())
+	return errors.Wrapf(err, "could not run %s", argv[0])
+}
+
+// mount mounts a file system at path in root.
+func mount(root, path, fstype string, flags uintptr, data string) error {
+	dst := filepath.Join(root, path)
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return errors.Wrapf(err, "could not create mount point %s", path)
+	}
+	if err := syscall.Mount(fstype, dst, fstype, flags, data); err != nil {
+		return errors.Wrapf(err, "could not mount %s", path)
+	}
+
+	return nil
+}
+
+// bind binds path to the same path in root, unless it doesn't exist.
+// Symlinks are copied rather than bound.
+func bind(root, path string, readOnly bool) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not stat %s", path)
+	}
+
+	dst := filepath.Join(root, path)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "could not create parent dir of %s", path)
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return errors.Wrapf(err, "could not read symlink %s", path)
+		}
+		return errors.Wrapf(os.Symlink(target, dst), "could not copy symlink %s", path)
+	case fi.IsDir():
+		err = os.MkdirAll(dst, 0755)
+	default:
+		var f *os.File
+		f, err = os.OpenFile(dst, os.O_CREATE, 0644)
+		if err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not create mount point %s", path)
+	}
+
+	err = syscall.Mount(path, dst, "", syscall.MS_BIND|syscall.MS_REC, "")
+	if err != nil {
+		return errors.Wrapf(err, "could not bind %s", path)
+	}
+
+	if !readOnly {
+		return nil
+	}
+
+	// Flags the server's own mount has set must be kept when remounting,
+	// or the kernel refuses to remount from a user namespace.
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return errors.Wrapf(err, "could not stat file system of %s", path)
+	}
+	keep := uintptr(st.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC |
+		syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME)
+
+	err = syscall.Mount("", dst, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|keep, "")
+	return errors.Wrapf(err, "could not make %s read-only", path)
+}
+
+// kill kills a command's whole process group, since commands like
+// `go test` start child processes of their own. Killing the command's
+// first process also kills everything else in its PID namespace.
+func kill(p *os.Process) {
+	_ = syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,115 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	r := require.New(t)
+
+	// A secret next to the dir the command runs in, like a server's
+	// .env or another candidate's code.
+	parent, err := ioutil.TempDir("", "sandbox-test")
+	r.NoError(err)
+	defer os.RemoveAll(parent)
+	// Commands run as nobody if the test runs as root.
+	r.NoError(os.Chmod(parent, 0755))
+
+	secret := filepath.Join(parent, "secret")
+	r.NoError(ioutil.WriteFile(secret, []byte("hunter2"), 0600))
+
+	dir := filepath.Join(parent, "work")
+	r.NoError(os.Mkdir(dir, 0700))
+
+	out := filepath.Join(parent, "out")
+	r.NoError(os.Mkdir(out, 0700))
+
+	s := New(Limits{Timeout: 10 * time.Second, Processes: 16})
+
+	run := func(command string) *Result {
+		res, err := s.Run(context.Background(), dir, out, command)
+		if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+			t.Skip("user namespaces are not available:", err)
+		}
+		r.NoError(err)
+		return res
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		exitCode int
+		stdout   string
+		stderr   string
+	}{
+		{name: "runs in dir", command: "pwd", stdout: dir + "\n"},
+		{name: "can write dir", command: "echo hi > out && cat out", stdout: "hi\n"},
+		{name: "can use tmp", command: "echo hi > /tmp/out && cat /tmp/out", stdout: "hi\n"},
+		{name: "can write out dir", command: "echo hi > $OUT_DIR/report && cat " + out + "/report", stdout: "hi\n"},
+		{name: "keeps home out of dir", command: "echo $HOME", stdout: "/tmp\n"},
+		{name: "can't read outside dir", command: "cat " + secret, exitCode: 1, stderr: "No such file"},
+		{name: "can't list outside dir", command: "ls " + parent, stdout: "out\nwork\n"},
+		{name: "can't write system dirs", command: "touch /usr/bin/x", exitCode: 1, stderr: "Read-only"},
+		{name: "can't write root", command: "touch /x", exitCode: 1, stderr: "Read-only"},
+		{name: "can't remount", command: "mount -o remount,rw /usr", exitCode: 32},
+		{name: "has no network", command: "cat /sys/class/net/eth0/address", exitCode: 1},
+		{name: "is pid 1", command: "echo $$", stdout: "1\n"},
+		{name: "has process limit", command: "grep processes /proc/self/limits", stdout: "Max processes             16                   16                   processes \n"},
+		{
+			name:     "can't fork past process limit",
+			command:  "for i in $(seq 32); do sleep 5 & done; wait",
+			exitCode: 2,
+			stderr:   "fork",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			res := run(tt.command)
+
+			r.Equal(tt.exitCode, res.ExitCode, string(res.Stderr))
+			r.False(res.TimedOut)
+			if tt.stdout != "" {
+				r.Equal(tt.stdout, string(res.Stdout))
+			}
+			if tt.stderr != "" {
+				r.Contains(string(res.Stderr), tt.stderr)
+			}
+		})
+	}
+
+	report, err := ioutil.ReadFile(filepath.Join(out, "report"))
+	r.NoError(err)
+	r.Equal("hi\n", string(report))
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := require.New(t)
+
+	dir, err := ioutil.TempDir("", "sandbox-test")
+	r.NoError(err)
+	defer os.RemoveAll(dir)
+
+	s := New(Limits{Timeout: 500 * time.Millisecond})
+
+	res, err := s.Run(context.Background(), dir, "", "sleep 10 & sleep 10")
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skip("user namespaces are not available:", err)
+	}
+	r.NoError(err)
+	r.True(res.TimedOut)
+	r.Less(int64(res.Duration), int64(5*time.Second))
+}
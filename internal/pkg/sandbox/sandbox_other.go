@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// isolated fails on platforms without the namespaces used to isolate
+// commands.
+func isolated(root string, dirs []string, l Limits, argv ...string) (*exec.Cmd, error) {
+	return nil, errors.Errorf("sandbox is not supported on %s", runtime.GOOS)
+}
+
+// kill ...
+func kill(p *os.Process) {
+	_ = p.Kill()
+}
@@ -0,0 +1,338 @@
+// Package similarity fingerprints source code with winnowing, as in
+// Schleimer, Wilkerson and Aiken's "Winnowing: Local Algorithms for
+// Document Fingerprinting", and compares fingerprints to find code that
+// was copied. Code is compared token by token with identifiers and
+// literals normalized, so that reformatting code or renaming variables
+// doesn't hide copying.
+package similarity
+
+import (
+	"go/scanner"
+	"go/token"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// gramSize is the number of tokens in a row that are hashed.
+	gramSize = 10
+	// windowSize is the number of hashes in a row of which the smallest
+	// is kept. Together with gramSize it guarantees that every match of
+	// at least gramSize+windowSize-1 tokens is found.
+	windowSize = 10
+	// maxFileSize is the max size of a file that's fingerprinted.
+	maxFileSize = 1 << 20
+	// regionGap is the max number of lines between matches that are
+	// merged into one region.
+	regionGap = 2
+)
+
+// sourceExts are the extensions of files that are fingerprinted.
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".java": true, ".kt": true, ".scala": true, ".rb": true, ".rs": true, ".php": true,
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".swift": true, ".sql": true, ".sh": true,
+}
+
+// keywords are kept as they are when tokenizing files other than Go
+// files, while all other identifiers are normalized.
+var keywords = map[string]bool{
+	"if": true, "else": true, "elif": true, "for": true, "while": true, "do": true,
+	"switch": true, "case": true, "default": true, "break": true, "continue": true,
+	"return": true, "func": true, "function": true, "def": true, "fn": true,
+	"class": true, "struct": true, "interface": true, "enum": true, "new": true,
+	"try": true, "catch": true, "except": true, "finally": true, "throw": true,
+	"raise": true, "import": true, "from": true, "package": true, "var": true,
+	"let": true, "const": true, "static": true, "public": true, "private": true,
+	"in": true, "of": true, "and": true, "or": true, "not": true, "match": true,
+	"yield": true, "async": true, "await": true, "lambda": true, "with": true,
+	"select": true, "where": true, "join": true, "insert": true, "update": true,
+}
+
+// genericToken matches a token in files other than Go files.
+var genericToken = regexp.MustCompile("[A-Za-z_][A-Za-z0-9_]*|[0-9][0-9A-Za-z_.]*|\"(?:[^\"\\\\\\n]|\\\\.)*\"|'(?:[^'\\\\\\n]|\\\\.)*'|\\S")
+
+// Mark is a fingerprint of a sequence of tokens.
+type Mark struct {
+	Hash      uint64
+	File      string
+	StartLine int
+	EndLine   int
+}
+
+// Region is a range of lines in one fingerprinted set of files that
+// matches a range of lines in another.
+type Region struct {
+	File           string
+	StartLine      int
+	EndLine        int
+	OtherFile      string
+	OtherStartLine int
+	OtherEndLine   int
+}
+
+type tok struct {
+	text string
+	line int
+}
+
+// Fingerprint returns the fingerprints of all source files in files,
+// which maps file paths to their content. Vendored code is left out.
+func Fingerprint(files map[string][]byte) []Mark {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if isSource(name, files[name]) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var marks []Mark
+	for _, name := range names {
+		marks = append(marks, winnow(name, tokenize(name, files[name]))...)
+	}
+
+	return marks
+}
+
+// Subtract returns the marks whose hashes don't occur in base, e.g. to
+// leave out code that all candidates were given.
+func Subtract(marks, base []Mark) []Mark {
+	seen := make(map[uint64]bool, len(base))
+	for _, m := range base {
+		seen[m.Hash] = true
+	}
+
+	var res []Mark
+	for _, m := range marks {
+		if !seen[m.Hash] {
+			res = append(res, m)
+		}
+	}
+
+	return res
+}
+
+// Compare returns the share of distinct hashes in the smaller of a and
+// b that also occur in the other, and the regions of a that match b,
+// largest first.
+func Compare(a, b []Mark) (float64, []Region) {
+	ah, bh := hashes(a), hashes(b)
+	if len(ah) == 0 || len(bh) == 0 {
+		return 0, nil
+	}
+
+	byHash := make(map[uint64]Mark, len(b))
+	for _, m := range b {
+		if _, found := byHash[m.Hash]; !found {
+			byHash[m.Hash] = m
+		}
+	}
+
+	var common int
+	for h := range ah {
+		if bh[h] {
+			common++
+		}
+	}
+
+	smaller := len(ah)
+	if len(bh) < smaller {
+		smaller = len(bh)
+	}
+
+	sorted := append([]Mark(nil), a...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].File != sorted[j].File {
+			return sorted[i].File < sorted[j].File
+		}
+		return sorted[i].StartLine < sorted[j].StartLine
+	})
+
+	var regions []Region
+	for _, ma := range sorted {
+		mb, found := byHash[ma.Hash]
+		if !found {
+			continue
+		}
+
+		if n := len(regions); n > 0 && regions[n-1].extends(ma, mb) {
+			r := &regions[n-1]
+			r.EndLine = maxInt(r.EndLine, ma.EndLine)
+			r.OtherStartLine = minInt(r.OtherStartLine, mb.StartLine)
+			r.OtherEndLine = maxInt(r.OtherEndLine, mb.EndLine)
+			continue
+		}
+
+		regions = append(regions, Region{
+			File:           ma.File,
+			StartLine:      ma.StartLine,
+			EndLine:        ma.EndLine,
+			OtherFile:      mb.File,
+			OtherStartLine: mb.StartLine,
+			OtherEndLine:   mb.EndLine,
+		})
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].EndLine-regions[i].StartLine > regions[j].EndLine-regions[j].StartLine
+	})
+
+	return float64(common) / float64(smaller), regions
+}
+
+// extends returns true if a match of ma in a and mb in b continues the
+// region.
+func (r *Region) extends(ma, mb Mark) bool {
+	return ma.File == r.File && mb.File == r.OtherFile &&
+		ma.StartLine <= r.EndLine+regionGap &&
+		mb.StartLine <= r.OtherEndLine+regionGap && mb.EndLine >= r.OtherStartLine-regionGap
+}
+
+func hashes(marks []Mark) map[uint64]bool {
+	hs := make(map[uint64]bool, len(marks))
+	for _, m := range marks {
+		hs[m.Hash] = true
+	}
+	return hs
+}
+
+// isSource returns true for source files that aren't vendored or too
+// large.
+func isSource(name string, content []byte) bool {
+	if !sourceExts[strings.ToLower(path.Ext(name))] || len(content) > maxFileSize {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == "vendor" || dir == "node_modules" {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenize splits a file into tokens, with identifiers and literals
+// normalized and comments left out in Go files.
+func tokenize(name string, src []byte) []tok {
+	if path.Ext(name) == ".go" {
+		return tokenizeGo(src)
+	}
+
+	var toks []tok
+	for i, line := range strings.Split(string(src), "\n") {
+		for _, t := range genericToken.FindAllString(line, -1) {
+			switch c := t[0]; {
+			case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+				if !keywords[t] {
+					t = "id"
+				}
+			case c >= '0' && c <= '9' || c == '"' || c == '\'':
+				t = "lit"
+			}
+			toks = append(toks, tok{t, i + 1})
+		}
+	}
+
+	return toks
+}
+
+func tokenizeGo(src []byte) []tok {
+	fset := token.NewFileSet()
+	f := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(f, src, nil, 0)
+
+	var toks []tok
+	for {
+		pos, t, lit := s.Scan()
+		if t == token.EOF {
+			break
+		}
+		// Skip semicolons inserted at line ends, so that formatting
+		// doesn't matter.
+		if t == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+
+		text := t.String()
+		switch {
+		case t == token.IDENT:
+			text = "id"
+		case t.IsLiteral():
+			text = "lit"
+		}
+		toks = append(toks, tok{text, f.Line(pos)})
+	}
+
+	return toks
+}
+
+// winnow hashes every sequence of gramSize tokens and keeps the
+// smallest hash in every window of windowSize hashes.
+func winnow(file string, toks []tok) []Mark {
+	if len(toks) < gramSize {
+		return nil
+	}
+
+	grams := make([]uint64, len(toks)-gramSize+1)
+	for i := range grams {
+		h := fnv.New64a()
+		for _, t := range toks[i : i+gramSize] {
+			h.Write([]byte(t.text))
+			h.Write([]byte{0})
+		}
+		grams[i] = h.Sum64()
+	}
+
+	mark := func(i int) Mark {
+		return Mark{Hash: grams[i], File: file, StartLine: toks[i].line, EndLine: toks[i+gramSize-1].line}
+	}
+
+	if len(grams) < windowSize {
+		// Keep the smallest hash of a file too short for a full window.
+		best := 0
+		for i, h := range grams {
+			if h < grams[best] {
+				best = i
+			}
+		}
+		return []Mark{mark(best)}
+	}
+
+	var marks []Mark
+	last := -1
+	for start := 0; start+windowSize <= len(grams); start++ {
+		// Pick the rightmost smallest hash in the window.
+		best := start
+		for i := start; i < start+windowSize; i++ {
+			if grams[i] <= grams[best] {
+				best = i
+			}
+		}
+		if best != last {
+			marks = append(marks, mark(best))
+			last = best
+		}
+	}
+
+	return marks
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,227 @@
+package similarity
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sumGo is a Go function long enough to be fingerprinted.
+const sumGo = `package p
+
+// Sum returns the sum of the even numbers in xs.
+func Sum(xs []int) int {
+	total := 0
+	for i := 0; i < len(xs); i++ {
+		if xs[i]%2 == 0 {
+			total += xs[i]
+		}
+	}
+	return total
+}
+`
+
+// renamedGo is sumGo reformatted, with its comments changed and its
+// identifiers renamed.
+const renamedGo = `package q
+
+func Add(values []int) int {
+	acc := 0
+	for j := 0; j < len(values); j++ { if values[j]%2 == 0 { acc += values[j] } }
+	return acc
+}
+`
+
+// otherGo is unrelated Go code.
+const otherGo = `package p
+
+import "strings"
+
+type Greeter struct {
+	Name string
+}
+
+func (g *Greeter) Greet(names ...string) string {
+	var b strings.Builder
+	switch len(names) {
+	case 0:
+		b.WriteString("Hello, world")
+	default:
+		b.WriteString("Hello, " + strings.Join(names, " and "))
+	}
+	return b.String() + " from " + g.Name
+}
+`
+
+func TestIsSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "main.go", want: true},
+		{name: "src/App.TSX", want: true},
+		{name: "db/schema.sql", want: true},
+		{name: "README.md", want: false},
+		{name: "Makefile", want: false},
+		{name: "vendor/example.com/dep/dep.go", want: false},
+		{name: "web/node_modules/left-pad/index.js", want: false},
+		{name: "big.go", content: strings.Repeat("x", maxFileSize+1), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isSource(tt.name, []byte(tt.content)))
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		src  string
+		want []string
+	}{
+		{
+			name: "go",
+			file: "a.go",
+			src:  "x := foo(1, \"a\") // call foo\nreturn x",
+			want: []string{"id", ":=", "id", "(", "lit", ",", "lit", ")", "return", "id"},
+		},
+		{
+			name: "python",
+			file: "a.py",
+			src:  "def foo(x):\n    return x + 1.5 # add\n",
+			want: []string{"def", "id", "(", "id", ")", ":", "return", "id", "+", "lit", "#", "id"},
+		},
+		{
+			name: "javascript strings",
+			file: "a.js",
+			src:  `let s = 'it\'s' + "a \"b\"";`,
+			want: []string{"let", "id", "=", "lit", "+", "lit", ";"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range tokenize(tt.file, []byte(tt.src)) {
+				got = append(got, tok.text)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{name: "no files"},
+		{
+			name:  "too few tokens",
+			files: map[string]string{"a.go": "package p"},
+		},
+		{
+			name:  "shorter than a window",
+			files: map[string]string{"a.go": "package p\n\nvar x, y, z = 1, 2, 3\n"},
+			want:  []string{"a.go"},
+		},
+		{
+			name: "vendored and non-source files are left out",
+			files: map[string]string{
+				"vendor/dep/sum.go": sumGo,
+				"sum.txt":           sumGo,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make(map[string][]byte)
+			for name, src := range tt.files {
+				files[name] = []byte(src)
+			}
+
+			var got []string
+			for _, m := range Fingerprint(files) {
+				got = append(got, m.File)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	fingerprint := func(name, src string) []Mark {
+		return Fingerprint(map[string][]byte{name: []byte(src)})
+	}
+
+	tests := []struct {
+		name    string
+		a, b    []Mark
+		score   float64
+		regions []Region
+	}{
+		{
+			name: "nothing to compare",
+			a:    fingerprint("sum.go", sumGo),
+		},
+		{
+			name:  "identical",
+			a:     fingerprint("sum.go", sumGo),
+			b:     fingerprint("sum.go", sumGo),
+			score: 1,
+			regions: []Region{
+				{File: "sum.go", StartLine: 4, EndLine: 8, OtherFile: "sum.go", OtherStartLine: 4, OtherEndLine: 8},
+			},
+		},
+		{
+			name:  "renamed and reformatted",
+			a:     fingerprint("add.go", renamedGo),
+			b:     fingerprint("sum.go", sumGo),
+			score: 1,
+			regions: []Region{
+				{File: "add.go", StartLine: 3, EndLine: 5, OtherFile: "sum.go", OtherStartLine: 4, OtherEndLine: 8},
+			},
+		},
+		{
+			name: "unrelated",
+			a:    fingerprint("greet.go", otherGo),
+			b:    fingerprint("sum.go", sumGo),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			score, regions := Compare(tt.a, tt.b)
+			r.InDelta(tt.score, score, 0.001)
+			r.Equal(tt.regions, regions)
+		})
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	r := require.New(t)
+
+	starter := Fingerprint(map[string][]byte{"sum.go": []byte(sumGo)})
+	marks := Fingerprint(map[string][]byte{
+		"sum.go":   []byte(sumGo),
+		"greet.go": []byte(otherGo),
+	})
+
+	got := Subtract(marks, starter)
+	r.NotEmpty(got)
+	for _, m := range got {
+		r.Equal("greet.go", m.File)
+	}
+
+	r.Empty(Subtract(starter, starter))
+	r.Equal(starter, Subtract(starter, nil))
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Storage stores blobs, e.g. repo snapshots, by key.
+type Storage interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// Local stores blobs as files in a local directory.
+type Local struct {
+	dir string
+}
+
+var _ Storage = &Local{}
+
+// NewLocal ...
+func NewLocal(dir string) *Local {
+	return &Local{dir}
+}
+
+// Put writes data to the given key, replacing any existing data. The
+// file is written in full before it's moved into place so that readers
+// never see a partial file.
+func (l *Local) Put(key string, data []byte) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0o755)
+	if err != nil {
+		return errors.Wrapf(err, "could not create dir for %s", key)
+	}
+
+	tmp := p + ".tmp"
+
+	err = ioutil.WriteFile(tmp, data, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "could not write %s", key)
+	}
+
+	err = os.Rename(tmp, p)
+	if err != nil {
+		return errors.Wrapf(err, "could not move %s into place", key)
+	}
+
+	return nil
+}
+
+// Get ...
+func (l *Local) Get(key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", key)
+	}
+
+	return data, nil
+}
+
+// Delete removes the data at the given key. Deleting a missing key
+// isn't an error.
+func (l *Local) Delete(key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "could not delete %s", key)
+	}
+
+	return nil
+}
+
+// path returns the file path for a key, making sure it stays within
+// the storage dir.
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.Errorf("invalid storage key '%s'", key)
+	}
+	return filepath.Join(l.dir, clean), nil
+}
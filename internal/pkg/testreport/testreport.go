@@ -0,0 +1,227 @@
+// Package testreport parses the results of test runs, either as output
+// by `go test -json` or as a JUnit XML report.
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// maxOutput is the max number of bytes of output kept per test.
+const maxOutput = 64 << 10
+
+// Status ...
+type Status string
+
+const (
+	// StatusPass ...
+	StatusPass Status = "pass"
+	// StatusFail ...
+	StatusFail Status = "fail"
+	// StatusSkip ...
+	StatusSkip Status = "skip"
+)
+
+// Test is the result of a single test. Failures that aren't tied to a
+// test, e.g. a package that doesn't build, are reported as tests with
+// an empty name.
+type Test struct {
+	Package string
+	Name    string
+	Status  Status
+	Elapsed float64
+	Output  string
+}
+
+// event is a line of `go test -json` output.
+type event struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// ParseGoTestJSON parses the output of `go test -json`. Lines that
+// aren't JSON, e.g. from commands run before the tests, are ignored.
+func ParseGoTestJSON(data []byte) ([]*Test, error) {
+	type key struct{ pkg, name string }
+
+	var tests []*Test
+	byKey := make(map[key]*Test)
+	output := make(map[key]*strings.Builder)
+	failed := make(map[string]bool)
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		e := new(event)
+		if err := json.Unmarshal(line, e); err != nil {
+			continue
+		}
+
+		k := key{e.Package, e.Test}
+
+		switch e.Action {
+		case "output":
+			b := output[k]
+			if b == nil {
+				b = new(strings.Builder)
+				output[k] = b
+			}
+			if b.Len() < maxOutput {
+				b.WriteString(e.Output)
+			}
+
+		case "pass", "fail", "skip":
+			if e.Test == "" {
+				// Only report package failures that no test accounts
+				// for, e.g. build failures and panics.
+				if e.Action != "fail" || failed[e.Package] {
+					continue
+				}
+			} else if e.Action == "fail" {
+				failed[e.Package] = true
+			}
+
+			t := byKey[k]
+			if t == nil {
+				t = &Test{Package: e.Package, Name: e.Test}
+				byKey[k] = t
+				tests = append(tests, t)
+			}
+			t.Status = Status(e.Action)
+			t.Elapsed = e.Elapsed
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read go test output")
+	}
+
+	for k, t := range byKey {
+		if b := output[k]; b != nil {
+			t.Output = truncate(b.String())
+		}
+	}
+
+	return tests, nil
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	XMLName xml.Name     `xml:"testsuite"`
+	Name    string       `xml:"name,attr"`
+	Cases   []junitCase  `xml:"testcase"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ParseJUnit parses a JUnit XML report with either a `testsuites` or a
+// single `testsuite` root element.
+func ParseJUnit(data []byte) ([]*Test, error) {
+	var suites []junitSuite
+
+	root := junitSuites{}
+	if err := xml.Unmarshal(data, &root); err == nil {
+		suites = root.Suites
+	} else {
+		s := junitSuite{}
+		if err := xml.Unmarshal(data, &s); err != nil {
+			return nil, errors.Wrap(err, "could not parse JUnit report")
+		}
+		suites = []junitSuite{s}
+	}
+
+	var tests []*Test
+
+	var walk func(ss []junitSuite)
+	walk = func(ss []junitSuite) {
+		for _, s := range ss {
+			for _, c := range s.Cases {
+				t := &Test{
+					Package: c.ClassName,
+					Name:    c.Name,
+					Status:  StatusPass,
+					Elapsed: c.Time,
+				}
+				if t.Package == "" {
+					t.Package = s.Name
+				}
+
+				var out strings.Builder
+				switch {
+				case c.Failure != nil:
+					t.Status = StatusFail
+					out.WriteString(message(c.Failure))
+				case c.Error != nil:
+					t.Status = StatusFail
+					out.WriteString(message(c.Error))
+				case c.Skipped != nil:
+					t.Status = StatusSkip
+					out.WriteString(message(c.Skipped))
+				}
+				out.WriteString(c.SystemOut)
+				out.WriteString(c.SystemErr)
+
+				t.Output = truncate(out.String())
+				tests = append(tests, t)
+			}
+			walk(s.Suites)
+		}
+	}
+	walk(suites)
+
+	return tests, nil
+}
+
+func message(m *junitMessage) string {
+	s := strings.TrimSpace(m.Message)
+	if text := strings.TrimSpace(m.Text); text != "" {
+		if s != "" {
+			s += "\n"
+		}
+		s += text
+	}
+	if s != "" {
+		s += "\n"
+	}
+	return s
+}
+
+func truncate(s string) string {
+	if len(s) > maxOutput {
+		return s[:maxOutput]
+	}
+	return s
+}
@@ -0,0 +1,156 @@
+package testreport
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []*Test
+	}{
+		{
+			name:   "empty",
+			output: "",
+			want:   nil,
+		},
+		{
+			name: "passing, failing and skipped tests",
+			output: `{"Action":"run","Package":"acme","Test":"TestA"}
+{"Action":"output","Package":"acme","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"pass","Package":"acme","Test":"TestA","Elapsed":0.5}
+{"Action":"output","Package":"acme","Test":"TestB","Output":"b_test.go:3: nope\n"}
+{"Action":"fail","Package":"acme","Test":"TestB","Elapsed":1}
+{"Action":"skip","Package":"acme","Test":"TestC"}
+{"Action":"fail","Package":"acme","Elapsed":1.5}
+`,
+			want: []*Test{
+				{Package: "acme", Name: "TestA", Status: StatusPass, Elapsed: 0.5, Output: "=== RUN   TestA\n"},
+				{Package: "acme", Name: "TestB", Status: StatusFail, Elapsed: 1, Output: "b_test.go:3: nope\n"},
+				{Package: "acme", Name: "TestC", Status: StatusSkip},
+			},
+		},
+		{
+			name: "package that doesn't build",
+			output: `{"Action":"output","Package":"acme","Output":"a.go:1: syntax error\n"}
+{"Action":"fail","Package":"acme","Elapsed":0}
+`,
+			want: []*Test{
+				{Package: "acme", Status: StatusFail, Output: "a.go:1: syntax error\n"},
+			},
+		},
+		{
+			name: "passing package isn't a test",
+			output: `{"Action":"pass","Package":"acme","Test":"TestA"}
+{"Action":"pass","Package":"acme"}
+`,
+			want: []*Test{
+				{Package: "acme", Name: "TestA", Status: StatusPass},
+			},
+		},
+		{
+			name: "lines that aren't JSON are ignored",
+			output: `go: downloading example.com/dep v1.0.0
+{"Action":"pass","Package":"acme","Test":"TestA"}
+{not json
+`,
+			want: []*Test{
+				{Package: "acme", Name: "TestA", Status: StatusPass},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			got, err := ParseGoTestJSON([]byte(tt.output))
+			r.NoError(err)
+			r.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestParseGoTestJSONTruncatesOutput(t *testing.T) {
+	r := require.New(t)
+
+	line := `{"Action":"output","Package":"acme","Test":"TestA","Output":"` + strings.Repeat("x", 1000) + `"}` + "\n"
+	output := strings.Repeat(line, 2*maxOutput/1000) + `{"Action":"fail","Package":"acme","Test":"TestA"}`
+
+	got, err := ParseGoTestJSON([]byte(output))
+	r.NoError(err)
+	r.Len(got, 1)
+	r.Len(got[0].Output, maxOutput)
+}
+
+func TestParseJUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []*Test
+		err    string
+	}{
+		{
+			name: "testsuites root",
+			report: `<testsuites>
+  <testsuite name="suite">
+    <testcase name="passes" classname="acme.A" time="0.5"/>
+    <testcase name="fails" classname="acme.A" time="1">
+      <failure message="expected 1">got 2</failure>
+    </testcase>
+    <testcase name="errors">
+      <error message="boom"/>
+      <system-out>some output
+</system-out>
+    </testcase>
+    <testcase name="skipped" classname="acme.B">
+      <skipped/>
+    </testcase>
+  </testsuite>
+</testsuites>`,
+			want: []*Test{
+				{Package: "acme.A", Name: "passes", Status: StatusPass, Elapsed: 0.5},
+				{Package: "acme.A", Name: "fails", Status: StatusFail, Elapsed: 1, Output: "expected 1\ngot 2\n"},
+				{Package: "suite", Name: "errors", Status: StatusFail, Output: "boom\nsome output\n"},
+				{Package: "acme.B", Name: "skipped", Status: StatusSkip},
+			},
+		},
+		{
+			name: "single testsuite root with nested suites",
+			report: `<testsuite name="outer">
+  <testcase name="a"/>
+  <testsuite name="inner">
+    <testcase name="b"/>
+  </testsuite>
+</testsuite>`,
+			want: []*Test{
+				{Package: "outer", Name: "a", Status: StatusPass},
+				{Package: "inner", Name: "b", Status: StatusPass},
+			},
+		},
+		{
+			name:   "not XML",
+			report: "PASS",
+			err:    "could not parse JUnit report",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+
+			got, err := ParseJUnit([]byte(tt.report))
+			if tt.err != "" {
+				r.Error(err)
+				r.Contains(err.Error(), tt.err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tt.want, got)
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package token
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"math"
@@ -12,6 +14,15 @@ func New() string {
 	return randomBase64String(60)
 }
 
+// Hash returns the hex encoded HMAC-SHA256 of a token keyed with
+// the given secret. Tokens are only ever stored hashed, so that a
+// leaked database can't be used to log in.
+func Hash(secret, token string) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	_, _ = m.Write([]byte(token))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
 // NewCode ...
 func NewCode(l int) string {
 	return randomBase16String(l)
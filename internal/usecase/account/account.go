@@ -2,6 +2,7 @@ package account
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/anrid/codecoach/internal/domain"
 	"github.com/pkg/errors"
@@ -9,14 +10,15 @@ import (
 
 // UseCase ...
 type UseCase struct {
-	a domain.AccountDAO
+	a  domain.AccountDAO
+	au domain.AuditDAO
 }
 
 var _ domain.AccountUseCases = &UseCase{}
 
 // New ...
-func New(a domain.AccountDAO) *UseCase {
-	return &UseCase{a}
+func New(a domain.AccountDAO, au domain.AuditDAO) *UseCase {
+	return &UseCase{a, au}
 }
 
 // Update ...
@@ -45,11 +47,41 @@ func (uc *UseCase) Update(ctx context.Context, id domain.ID, a domain.UpdateAcco
 		old.Profile.Logo = a.Logo
 		updates = append(updates, domain.Field{Name: "profile", Value: old.Profile})
 	}
+	if a.BlindReview != nil {
+		old.Settings.BlindReview = *a.BlindReview
+		updates = append(updates, domain.Field{Name: "settings", Value: old.Settings})
+	}
 
 	up, err := uc.a.Update(ctx, id, updates)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not update account %s", id)
 	}
 
+	if a.BlindReview != nil {
+		err = uc.au.Create(ctx, domain.NewAuditEntry(id, se.User.ID, domain.AuditActionSetBlindReview, id, strconv.FormatBool(*a.BlindReview)))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not log blind review change in account %s", id)
+		}
+	}
+
 	return up, nil
 }
+
+// AuditLog ...
+func (uc *UseCase) AuditLog(ctx context.Context) ([]*domain.AuditEntry, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if se.User.Role != domain.RoleAdmin {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot see the audit log", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	es, err := uc.au.List(ctx, se.User.AccountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list audit log of account %s", se.User.AccountID)
+	}
+
+	return es, nil
+}
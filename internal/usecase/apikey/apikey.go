@@ -0,0 +1,115 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+
+	"github.com/anrid/codecoach/internal/config"
+	"github.com/anrid/codecoach/internal/domain"
+	token_gen "github.com/anrid/codecoach/internal/pkg/token"
+	"github.com/pkg/errors"
+)
+
+// UseCase ...
+type UseCase struct {
+	c  *config.Config
+	k  domain.APIKeyDAO
+	au domain.AuditDAO
+}
+
+var _ domain.APIKeyUseCases = &UseCase{}
+
+// New ...
+func New(c *config.Config, k domain.APIKeyDAO, au domain.AuditDAO) *UseCase {
+	return &UseCase{c, k, au}
+}
+
+// Create ...
+func (uc *UseCase) Create(ctx context.Context, a domain.CreateAPIKeyArgs) (*domain.APIKey, error) {
+	se, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := domain.NewAPIKey(domain.NewAPIKeyArgs{
+		AccountID: se.User.AccountID,
+		CreatedBy: se.User.ID,
+		Name:      a.Name,
+		Scopes:    a.Scopes,
+		ExpiresAt: a.ExpiresAt,
+		Code:      token_gen.NewCode(8),
+		Secret:    token_gen.New(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create api key")
+	}
+	k.KeyHash = token_gen.Hash(uc.c.TokenSecret, k.Key)
+
+	err = uc.k.Create(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+
+	err = uc.au.Create(ctx, domain.NewAuditEntry(se.User.AccountID, se.User.ID, domain.AuditActionCreateAPIKey, k.ID, scopesString(k.Scopes)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not log creation of api key %s.%s", k.AccountID, k.ID)
+	}
+
+	return k, nil
+}
+
+// List ...
+func (uc *UseCase) List(ctx context.Context) ([]*domain.APIKey, error) {
+	se, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.k.List(ctx, se.User.AccountID)
+}
+
+// Revoke ...
+func (uc *UseCase) Revoke(ctx context.Context, id domain.ID) error {
+	se, err := requireAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = uc.k.Delete(ctx, se.User.AccountID, id)
+	if err != nil {
+		return err
+	}
+
+	err = uc.au.Create(ctx, domain.NewAuditEntry(se.User.AccountID, se.User.ID, domain.AuditActionRevokeAPIKey, id, ""))
+	if err != nil {
+		return errors.Wrapf(err, "could not log revocation of api key %s.%s", se.User.AccountID, id)
+	}
+
+	return nil
+}
+
+// requireAdmin returns the current session if it belongs to an admin.
+// API keys can't be used to manage API keys.
+func requireAdmin(ctx context.Context) (*domain.Session, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot manage api keys", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+	if se.APIKey != nil {
+		return nil, errors.Errorf("api key %s.%s cannot manage api keys", se.APIKey.AccountID, se.APIKey.ID)
+	}
+
+	return se, nil
+}
+
+func scopesString(s domain.Scopes) string {
+	var ss []string
+	for _, x := range s {
+		ss = append(ss, string(x))
+	}
+	return strings.Join(ss, ",")
+}
@@ -0,0 +1,634 @@
+package challenge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/mailer"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// sweepBatchSize is the max number of challenges expired per sweep.
+const sweepBatchSize = 100
+
+// UseCase ...
+type UseCase struct {
+	c domain.ChallengeDAO
+	t domain.TaskDAO
+	u domain.UserDAO
+	j domain.JobUseCases
+	i domain.IdentityUseCases
+	m mailer.Mailer
+}
+
+var _ domain.ChallengeUseCases = &UseCase{}
+
+// New ...
+func New(c domain.ChallengeDAO, t domain.TaskDAO, u domain.UserDAO, j domain.JobUseCases, i domain.IdentityUseCases, m mailer.Mailer) *UseCase {
+	return &UseCase{c, t, u, j, i, m}
+}
+
+// Create ...
+func (uc *UseCase) Create(ctx context.Context, a domain.CreateChallengeArgs) (*domain.Challenge, error) {
+	se, err := requireChallengeManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Challenges can only be created for candidates in the same account.
+	candidate, err := uc.u.Get(ctx, se.User.AccountID, a.ForUserID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find candidate %s.%s", se.User.AccountID, a.ForUserID)
+	}
+	if candidate.Role != domain.RoleCandidate {
+		return nil, errors.Errorf("user %s.%s (role: %s) is not a candidate", candidate.AccountID, candidate.ID, candidate.Role)
+	}
+
+	if a.ExpiresAt != nil && a.ExpiresAt.Before(time.Now()) {
+		return nil, errors.Errorf("expires_at %s is in the past", a.ExpiresAt)
+	}
+
+	tasks, err := uc.getTasks(ctx, se.User.AccountID, a.TaskIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := domain.NewChallenge(domain.NewChallengeArgs{
+		AccountID:       se.User.AccountID,
+		ForUserID:       candidate.ID,
+		CreatedByUserID: se.User.ID,
+		Tasks:           tasks,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create challenge")
+	}
+
+	if a.ExpiresAt != nil {
+		// A challenge with a deadline is scheduled right away.
+		c.ExpiresAt = a.ExpiresAt
+		if err = c.Transition(domain.StatusScheduled, se.User.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	err = uc.c.Create(ctx, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create challenge")
+	}
+
+	return c, nil
+}
+
+// getTasks returns all tasks with the given ids, in the given order.
+func (uc *UseCase) getTasks(ctx context.Context, accountID domain.ID, ids []domain.ID) ([]*domain.Task, error) {
+	var unique []domain.ID
+	seen := make(map[domain.ID]bool)
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	ts, err := uc.t.GetAll(ctx, accountID, unique)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get tasks %v", unique)
+	}
+
+	byID := make(map[domain.ID]*domain.Task)
+	for _, t := range ts {
+		byID[t.ID] = t
+	}
+
+	var tasks []*domain.Task
+	for _, id := range unique {
+		t, found := byID[id]
+		if !found {
+			return nil, errors.Errorf("could not find task %s.%s", accountID, id)
+		}
+		tasks = append(tasks, t)
+	}
+
+	return tasks, nil
+}
+
+// Get ...
+func (uc *UseCase) Get(ctx context.Context, id domain.ID) (*domain.Challenge, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find challenge %s.%s", se.User.AccountID, id)
+	}
+
+	// Candidates can only see their own challenges.
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) && c.ForUserID != se.User.ID {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot access challenge %s", se.User.AccountID, se.User.ID, se.User.Role, id)
+	}
+
+	redact(se, c)
+
+	err = uc.blind(ctx, se.User, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// List ...
+func (uc *UseCase) List(ctx context.Context, a domain.ListChallengesArgs) (*domain.ListChallengesResult, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Candidates can only list their own challenges.
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		a.ForUserID = se.User.ID
+	}
+
+	cs, total, err := uc.c.List(ctx, se.User.AccountID, a)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list challenges in account %s", se.User.AccountID)
+	}
+
+	redact(se, cs...)
+
+	err = uc.blind(ctx, se.User, cs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ListChallengesResult{
+		Challenges: cs,
+		Total:      total,
+	}, nil
+}
+
+// SetExpiresAt sets a challenge's deadline. New challenges are
+// scheduled once they have a deadline.
+func (uc *UseCase) SetExpiresAt(ctx context.Context, id domain.ID, expiresAt time.Time) (*domain.Challenge, error) {
+	se, err := requireChallengeManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Before(time.Now()) {
+		return nil, errors.Errorf("expires_at %s is in the past", expiresAt)
+	}
+
+	c, err := uc.c.Modify(ctx, se.User.AccountID, id, func(c *domain.Challenge) error {
+		if c.IsFinal() {
+			return errors.Wrapf(domain.ErrChallengeFinal, "cannot change the deadline of a %s challenge", c.Status)
+		}
+		if c.Status == domain.StatusNew {
+			if err := c.Transition(domain.StatusScheduled, se.User.ID); err != nil {
+				return err
+			}
+		}
+
+		// Archive repos at their tasks' new deadlines. Archive jobs
+		// scheduled for the old deadlines do nothing if they run before
+		// the new ones.
+		for _, tp := range c.SetExpiresAt(expiresAt, time.Now()) {
+			c.AddPendingJob(domain.JobTypeArchiveRepo, tp.TaskID, tp.DeadlineAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not set expires_at of challenge %s.%s", se.User.AccountID, id)
+	}
+
+	return uc.enqueuePending(ctx, c), nil
+}
+
+// Cancel cancels a challenge and revokes the candidate's access to its
+// task repos.
+func (uc *UseCase) Cancel(ctx context.Context, id domain.ID) (*domain.Challenge, error) {
+	se, err := requireChallengeManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.transition(ctx, se, id, domain.StatusCanceled)
+}
+
+// archiveNow archives all of a challenge's task repos right away,
+// e.g. once the challenge has been canceled or has expired.
+func archiveNow(c *domain.Challenge) {
+	for _, tp := range c.Details.Progress {
+		c.AddPendingJob(domain.JobTypeArchiveRepo, tp.TaskID, nil)
+	}
+}
+
+// ListMine lists all challenges assigned to the current user.
+func (uc *UseCase) ListMine(ctx context.Context, statuses []domain.Status) (*domain.ListChallengesResult, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, total, err := uc.c.List(ctx, se.User.AccountID, domain.ListChallengesArgs{
+		Statuses:  statuses,
+		ForUserID: se.User.ID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list challenges for user %s.%s", se.User.AccountID, se.User.ID)
+	}
+
+	for _, c := range cs {
+		c.HideGrading()
+	}
+
+	return &domain.ListChallengesResult{
+		Challenges: cs,
+		Total:      total,
+	}, nil
+}
+
+// Start activates a challenge and starts the clock for all of its
+// tasks. Only the candidate the challenge was created for can start it.
+func (uc *UseCase) Start(ctx context.Context, id domain.ID) (*domain.Challenge, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Modify(ctx, se.User.AccountID, id, func(c *domain.Challenge) error {
+		if c.ForUserID != se.User.ID {
+			return errors.Errorf("current user %s.%s cannot start challenge %s", se.User.AccountID, se.User.ID, id)
+		}
+		err := c.Start(se.User.ID, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// Provision a repo for each task in the background, and archive
+		// it once the task's deadline has passed.
+		for _, tp := range c.Details.Progress {
+			c.AddPendingJob(domain.JobTypeProvisionRepo, tp.TaskID, nil)
+			if tp.DeadlineAt != nil {
+				c.AddPendingJob(domain.JobTypeArchiveRepo, tp.TaskID, tp.DeadlineAt)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not start challenge %s.%s", se.User.AccountID, id)
+	}
+
+	c = uc.enqueuePending(ctx, c)
+
+	c.HideGrading()
+
+	return c, nil
+}
+
+// Progress returns the remaining time and submission status of
+// each of a challenge's tasks.
+func (uc *UseCase) Progress(ctx context.Context, id domain.ID) (*domain.ChallengeProgress, error) {
+	c, err := uc.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	p := &domain.ChallengeProgress{
+		ChallengeID: c.ID,
+		Status:      c.Status,
+		StartedAt:   c.StartedAt,
+		ExpiresAt:   c.ExpiresAt,
+	}
+
+	for _, t := range c.Details.Tasks {
+		info := &domain.TaskProgressInfo{
+			TaskID: t.ID,
+			Name:   t.Name,
+			Type:   t.Type,
+		}
+
+		if tp, err := c.TaskProgress(t.ID); err == nil {
+			info.DeadlineAt = tp.DeadlineAt
+			info.SubmittedAt = tp.SubmittedAt
+			info.Repo = tp.Repo
+			if r := tp.Remaining(now); r != nil {
+				secs := int64(r.Seconds())
+				info.RemainingSeconds = &secs
+			}
+		}
+
+		p.Tasks = append(p.Tasks, info)
+	}
+
+	return p, nil
+}
+
+// SubmitTask submits a challenge task. Submissions are rejected once
+// the task's time limit or the challenge's deadline has passed.
+func (uc *UseCase) SubmitTask(ctx context.Context, id, taskID domain.ID) (*domain.Challenge, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Modify(ctx, se.User.AccountID, id, func(c *domain.Challenge) error {
+		if c.ForUserID != se.User.ID {
+			return errors.Errorf("current user %s.%s cannot submit tasks in challenge %s", se.User.AccountID, se.User.ID, id)
+		}
+		err := c.SubmitTask(taskID, se.User.ID, time.Now())
+		if err != nil {
+			return err
+		}
+
+		// Grade the submission in the background, by collecting the
+		// candidate's review in code review tasks, running the hidden
+		// tests and comparing code metrics in refactoring tasks.
+		tp, err := c.TaskProgress(taskID)
+		if err != nil {
+			return err
+		}
+
+		ref := domain.TaskRef{AccountID: c.AccountID, ChallengeID: c.ID, TaskID: taskID}
+
+		for _, t := range c.Details.Tasks {
+			if t.ID != taskID {
+				continue
+			}
+			for _, a := range domain.GradingJobs(ref, t, tp, false) {
+				c.AddPendingJob(a.Payload.JobType(), taskID, a.RunAt)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not submit task %s in challenge %s.%s", taskID, se.User.AccountID, id)
+	}
+
+	c = uc.enqueuePending(ctx, c)
+
+	c.HideGrading()
+
+	return c, nil
+}
+
+// Review returns the candidate's review of the pull request in a code
+// review task. Only admins and hiring managers can see reviews here.
+func (uc *UseCase) Review(ctx context.Context, id, taskID domain.ID) (*domain.CodeReview, error) {
+	se, err := requireChallengeManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find challenge %s.%s", se.User.AccountID, id)
+	}
+
+	tp, err := c.TaskProgress(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tp.Review == nil {
+		return nil, errors.Errorf("review of task %s in challenge %s has not been collected", taskID, id)
+	}
+
+	b, err := uc.i.Blind(ctx, se.User, c.ForUserID)
+	if err != nil {
+		return nil, err
+	}
+	b.Review(c.ForUserID, tp.Review)
+
+	return tp.Review, nil
+}
+
+// ExpireOverdue moves challenges that weren't completed before their
+// expiry date to expired and notifies their creators. The notification
+// is saved along with the expired challenge, so it's sent even if it
+// can't be enqueued right away. It's safe to run on several servers at
+// once: each challenge is locked while it's being expired, and a
+// challenge that's already been expired by another server is skipped.
+func (uc *UseCase) ExpireOverdue(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	cs, err := uc.c.ListOverdue(ctx, now, sweepBatchSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not find overdue challenges")
+	}
+
+	var expired int
+
+	for _, c := range cs {
+		up, err := uc.c.Modify(ctx, c.AccountID, c.ID, func(c *domain.Challenge) error {
+			if c.ExpiresAt == nil || c.ExpiresAt.After(now) {
+				return errors.Errorf("challenge is no longer overdue")
+			}
+			err := c.Transition(domain.StatusExpired, "")
+			if err != nil {
+				return err
+			}
+			archiveNow(c)
+			c.AddPendingJob(domain.JobTypeNotifyChallengeExpired, "", nil)
+			return nil
+		})
+		if err != nil {
+			zap.S().Infow("skipping overdue challenge", "account", c.AccountID, "challenge", c.ID, "reason", err.Error())
+			continue
+		}
+
+		expired++
+
+		uc.enqueuePending(ctx, up)
+
+		zap.S().Infow("expired challenge", "account", c.AccountID, "challenge", c.ID, "expires_at", c.ExpiresAt)
+	}
+
+	return expired, nil
+}
+
+// EnqueuePendingJobs ...
+func (uc *UseCase) EnqueuePendingJobs(ctx context.Context) (int, error) {
+	cs, err := uc.c.ListWithPendingJobs(ctx, sweepBatchSize)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not find challenges with pending jobs")
+	}
+
+	var enqueued int
+
+	for _, c := range cs {
+		n := len(c.Details.PendingJobs)
+		c = uc.enqueuePending(ctx, c)
+		enqueued += n - len(c.Details.PendingJobs)
+	}
+
+	return enqueued, nil
+}
+
+// enqueuePending enqueues a challenge's pending jobs and removes them
+// from the challenge. Jobs that can't be enqueued are left for
+// EnqueuePendingJobs to retry. It returns the updated challenge.
+func (uc *UseCase) enqueuePending(ctx context.Context, c *domain.Challenge) *domain.Challenge {
+	done := make(map[domain.ID]bool)
+
+	for _, pj := range c.Details.PendingJobs {
+		a, err := c.PendingJobArgs(pj)
+		if err == nil {
+			_, err = uc.j.Enqueue(ctx, a)
+		}
+		if err != nil {
+			zap.S().Warnw("could not enqueue pending job", "account", c.AccountID, "challenge", c.ID, "job", pj.ID, "type", pj.Type, "error", err.Error())
+			continue
+		}
+		done[pj.ID] = true
+	}
+
+	if len(done) == 0 {
+		return c
+	}
+
+	up, err := uc.c.Modify(ctx, c.AccountID, c.ID, func(c *domain.Challenge) error {
+		c.RemovePendingJobs(done)
+		return nil
+	})
+	if err != nil {
+		// The jobs are enqueued again, which is a no-op, on the next sweep.
+		zap.S().Warnw("could not remove enqueued jobs from challenge", "account", c.AccountID, "challenge", c.ID, "error", err.Error())
+		c.RemovePendingJobs(done)
+		return c
+	}
+
+	return up
+}
+
+// NotifyExpired ...
+func (uc *UseCase) NotifyExpired(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.ChallengeExpiredPayload)
+
+	c, err := uc.c.Get(ctx, p.AccountID, p.ChallengeID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find challenge %s.%s", p.AccountID, p.ChallengeID)
+	}
+
+	creator, err := uc.u.Get(ctx, c.AccountID, c.CreatedByUserID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find creator %s.%s", c.AccountID, c.CreatedByUserID)
+	}
+
+	candidate, err := uc.u.Get(ctx, c.AccountID, c.ForUserID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find candidate %s.%s", c.AccountID, c.ForUserID)
+	}
+
+	b, err := uc.i.Blind(ctx, creator, candidate.ID)
+	if err != nil {
+		return err
+	}
+	b.User(candidate)
+
+	var submitted int
+	for _, tp := range c.Details.Progress {
+		if tp.SubmittedAt != nil {
+			submitted++
+		}
+	}
+
+	body := fmt.Sprintf(
+		"Hi %s,\n\nThe challenge you created for %s %s (%s) expired on %s.\n",
+		creator.Profile.GivenName,
+		candidate.Profile.GivenName,
+		candidate.Profile.FamilyName,
+		candidate.Email,
+		c.ExpiresAt.Format(time.RFC1123),
+	)
+	if c.StartedAt == nil {
+		body += "The candidate never started the challenge.\n"
+	} else {
+		body += fmt.Sprintf("The candidate submitted %d of %d tasks.\n", submitted, len(c.Details.Tasks))
+	}
+
+	err = uc.m.Send(ctx, mailer.Message{
+		To:      creator.Email,
+		Subject: fmt.Sprintf("Challenge for %s %s has expired", candidate.Profile.GivenName, candidate.Profile.FamilyName),
+		Body:    body,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not send email to %s", creator.Email)
+	}
+
+	return nil
+}
+
+// transition moves a challenge to a new status, provided that the
+// move is legal according to the challenge state machine. The task
+// repos of challenges that reach a final status are archived.
+func (uc *UseCase) transition(ctx context.Context, se *domain.Session, id domain.ID, to domain.Status) (*domain.Challenge, error) {
+	c, err := uc.c.Modify(ctx, se.User.AccountID, id, func(c *domain.Challenge) error {
+		err := c.Transition(to, se.User.ID)
+		if err != nil {
+			return err
+		}
+		if c.IsFinal() {
+			archiveNow(c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not move challenge %s.%s to status %s", se.User.AccountID, id, to)
+	}
+
+	return uc.enqueuePending(ctx, c), nil
+}
+
+// redact hides how challenges are graded from candidates.
+func redact(se *domain.Session, cs ...*domain.Challenge) {
+	if se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		return
+	}
+	for _, c := range cs {
+		c.HideGrading()
+	}
+}
+
+// blind hides candidates' identities from reviewers in blind review
+// mode.
+func (uc *UseCase) blind(ctx context.Context, viewer *domain.User, cs ...*domain.Challenge) error {
+	var ids []domain.ID
+	for _, c := range cs {
+		ids = append(ids, c.ForUserID)
+	}
+
+	b, err := uc.i.Blind(ctx, viewer, ids...)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		b.Challenge(c)
+	}
+
+	return nil
+}
+
+// requireChallengeManager returns the current session if the current
+// user is allowed to manage challenges, i.e. is an admin or a hiring
+// manager.
+func requireChallengeManager(ctx context.Context) (*domain.Session, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot manage challenges", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	return se, nil
+}
@@ -0,0 +1,947 @@
+package grading
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anrid/codecoach/internal/config"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/diff"
+	"github.com/anrid/codecoach/internal/pkg/github"
+	"github.com/anrid/codecoach/internal/pkg/metrics"
+	"github.com/anrid/codecoach/internal/pkg/sandbox"
+	"github.com/anrid/codecoach/internal/pkg/similarity"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	"github.com/anrid/codecoach/internal/pkg/testreport"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxLog is the max number of bytes of a test command's output kept
+	// in a grade's log.
+	maxLog = 16 << 10
+	// vetCommand is run to find problems in refactoring tasks.
+	vetCommand = "go vet ./..."
+	// maxDiffFileSize is the max size of a file that's diffed.
+	maxDiffFileSize = 1 << 20
+	// maxDiffSize is the max total size of the patches in a diff.
+	maxDiffSize = 4 << 20
+	// maxMatchRegions is the max number of matching regions kept per
+	// similarity match.
+	maxMatchRegions = 20
+)
+
+// vetFinding matches a problem reported by `go vet`.
+var vetFinding = regexp.MustCompile(`(?m)^(vet: )?\S+\.go:\d+(:\d+)?: `)
+
+// GithubAPI is the part of the Github API used to fetch the code to
+// grade.
+type GithubAPI interface {
+	CurrentUser() (*github.User, error)
+	DownloadRepo(username, repo, branch string) (*github.Response, error)
+	HeadCommit(owner, repo string) (branch, sha string, err error)
+	ListCommits(owner, repo string, nextURL string) ([]github.Commit, *github.Response, error)
+	ListEvents(owner, repo string, nextURL string) ([]github.Event, *github.Response, error)
+	Repo(owner, repo string) (*github.Repo, error)
+}
+
+// Sandbox runs a shell command in a directory in isolation. The command
+// can also write to the out directory, if set, which it finds in its
+// OUT_DIR env var.
+type Sandbox interface {
+	Run(ctx context.Context, dir, out, command string) (*sandbox.Result, error)
+}
+
+// UseCase ...
+type UseCase struct {
+	cfg *config.Config
+	c   domain.ChallengeDAO
+	f   domain.FingerprintDAO
+	i   domain.IdentityUseCases
+	g   GithubAPI
+	s   storage.Storage
+	sb  Sandbox
+}
+
+var _ domain.GradingUseCases = &UseCase{}
+
+// New ...
+func New(cfg *config.Config, c domain.ChallengeDAO, f domain.FingerprintDAO, i domain.IdentityUseCases, g GithubAPI, s storage.Storage, sb Sandbox) *UseCase {
+	return &UseCase{cfg, c, f, i, g, s, sb}
+}
+
+// RunTests downloads the latest commit in the candidate's repo, or uses
+// the repo's snapshot once it's been archived, runs the task's hidden
+// tests against it in a sandbox and saves the results as the task's
+// grade. Running the tests again replaces the grade.
+func (uc *UseCase) RunTests(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.RunTestsPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if t.Details.TestCommand == "" {
+		return nil
+	}
+
+	sha, zipball, err := uc.code(tp.Repo)
+	if err != nil {
+		return err
+	}
+
+	g := &domain.TestGrade{Commit: sha, Tests: []*domain.TestResult{}}
+
+	if zipball == nil {
+		g.Log = "repo has no commits"
+	} else {
+		err = uc.run(ctx, zipball, t, g)
+		if err != nil {
+			return err
+		}
+	}
+
+	g.Tally()
+	g.GradedAt = time.Now()
+
+	_, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		tp.Grade = g
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save grade for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	zap.S().Infow(
+		"graded task",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"commit", sha,
+		"status", g.Status,
+		"passed", g.Passed,
+		"failed", g.Failed,
+	)
+
+	return nil
+}
+
+// run runs the task's test command against the code in a zipball and
+// adds the results to the grade.
+func (uc *UseCase) run(ctx context.Context, zipball []byte, t *domain.Task, g *domain.TestGrade) error {
+	files, err := github.ExtractZipball(zipball)
+	if err != nil {
+		return domain.Permanent(errors.Wrapf(err, "could not extract commit %s", g.Commit))
+	}
+
+	dir, err := write(files)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// The report is written outside the candidate's code, so that a
+	// report committed to the repo can't be mistaken for it.
+	reportDir, err := ioutil.TempDir("", "codecoach-report-")
+	if err != nil {
+		return errors.Wrap(err, "could not create report dir")
+	}
+	defer os.RemoveAll(reportDir)
+
+	out, err := uc.sb.Run(ctx, dir, reportDir, t.Details.TestCommand)
+	if err != nil {
+		return errors.Wrap(err, "could not run tests")
+	}
+
+	g.ExitCode = out.ExitCode
+	g.TimedOut = out.TimedOut
+	g.Duration = out.Duration
+	g.Log = tail(string(out.Stdout)+string(out.Stderr), maxLog)
+
+	var tests []*testreport.Test
+
+	if report := t.Details.TestReport; report != "" {
+		report = path.Clean("/" + report)[1:]
+
+		data, err := ioutil.ReadFile(filepath.Join(reportDir, filepath.FromSlash(report)))
+		if err != nil {
+			g.Log = tail(g.Log+"\ncould not read test report "+report, maxLog)
+			return nil
+		}
+
+		tests, err = testreport.ParseJUnit(data)
+		if err != nil {
+			g.Log = tail(g.Log+"\n"+err.Error(), maxLog)
+			return nil
+		}
+	} else {
+		tests, err = testreport.ParseGoTestJSON(out.Stdout)
+		if err != nil {
+			return err
+		}
+		// The JSON events are already broken down by test, so only keep
+		// everything else in the log.
+		g.Log = tail(nonJSON(string(out.Stdout))+string(out.Stderr), maxLog)
+	}
+
+	for _, rt := range tests {
+		g.Tests = append(g.Tests, &domain.TestResult{
+			Package: rt.Package,
+			Name:    rt.Name,
+			Status:  domain.TestStatus(rt.Status),
+			Elapsed: rt.Elapsed,
+			Output:  rt.Output,
+		})
+	}
+
+	return nil
+}
+
+// AnalyzeRefactor computes static code metrics for the starter code of
+// a refactoring task and the latest commit in the candidate's repo, or
+// the repo's snapshot once it's been archived, and saves both and their
+// difference on the challenge. Analyzing the repo again replaces the
+// report.
+func (uc *UseCase) AnalyzeRefactor(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.AnalyzeRefactorPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if t.Type != domain.TaskTypeRefactor {
+		return nil
+	}
+
+	var starter []github.File
+	if t.Details.GithubRepoName != "" {
+		starter, err = uc.starter(t, tp.Repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	sha, zipball, err := uc.code(tp.Repo)
+	if err != nil {
+		return err
+	}
+
+	var final []github.File
+	if zipball != nil {
+		final, err = github.ExtractZipball(zipball)
+		if err != nil {
+			return domain.Permanent(errors.Wrapf(err, "could not extract commit %s", sha))
+		}
+	}
+
+	before, err := uc.measure(ctx, starter)
+	if err != nil {
+		return err
+	}
+	after, err := uc.measure(ctx, final)
+	if err != nil {
+		return err
+	}
+
+	m := &domain.RefactorReport{
+		Before:     before,
+		After:      after,
+		Delta:      after.Sub(before),
+		Commit:     sha,
+		AnalyzedAt: time.Now(),
+	}
+
+	_, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		tp.Metrics = m
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save metrics for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	zap.S().Infow(
+		"analyzed refactor",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"commit", sha,
+		"complexity", m.Delta.AvgComplexity,
+		"duplication", m.Delta.Duplication,
+	)
+
+	return nil
+}
+
+// CheckSimilarity fingerprints the latest commit in the candidate's
+// repo, or the repo's snapshot once it's been archived, leaving out the
+// task's starter code, and compares it to other candidates' code in the
+// same task in the account. Matches above the threshold are saved on
+// both challenges. Checking the repo again replaces its matches.
+func (uc *UseCase) CheckSimilarity(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.CheckSimilarityPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if t.Type == domain.TaskTypeCodeReview {
+		return nil
+	}
+
+	var starter []github.File
+	if t.Details.GithubRepoName != "" {
+		starter, err = uc.starter(t, tp.Repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	sha, zipball, err := uc.code(tp.Repo)
+	if err != nil {
+		return err
+	}
+
+	var final []github.File
+	if zipball != nil {
+		final, err = github.ExtractZipball(zipball)
+		if err != nil {
+			return domain.Permanent(errors.Wrapf(err, "could not extract commit %s", sha))
+		}
+	}
+
+	marks := similarity.Subtract(similarity.Fingerprint(fileMap(final)), similarity.Fingerprint(fileMap(starter)))
+
+	now := time.Now()
+
+	f := &domain.Fingerprint{
+		AccountID:   p.AccountID,
+		TaskID:      p.TaskID,
+		ChallengeID: p.ChallengeID,
+		UserID:      c.ForUserID,
+		Commit:      sha,
+		CreatedAt:   now,
+	}
+	for _, m := range marks {
+		f.Details.Marks = append(f.Details.Marks, &domain.FingerprintMark{
+			Hash:      m.Hash,
+			File:      m.File,
+			StartLine: m.StartLine,
+			EndLine:   m.EndLine,
+		})
+	}
+
+	err = uc.f.Put(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	others, err := uc.f.ListByTask(ctx, p.AccountID, p.TaskID)
+	if err != nil {
+		return err
+	}
+
+	r := &domain.SimilarityReport{Commit: sha, Matches: []*domain.SimilarityMatch{}, CheckedAt: now}
+
+	// Matches to add to or remove from the other challenges.
+	theirMatches := make(map[domain.ID]*domain.SimilarityMatch)
+	if tp.Similarity != nil {
+		for _, m := range tp.Similarity.Matches {
+			theirMatches[m.ChallengeID] = nil
+		}
+	}
+
+	for _, o := range others {
+		// Candidates can't copy from themselves.
+		if o.ChallengeID == c.ID || o.UserID == c.ForUserID {
+			continue
+		}
+
+		theirs := make([]similarity.Mark, 0, len(o.Details.Marks))
+		for _, m := range o.Details.Marks {
+			theirs = append(theirs, similarity.Mark{Hash: m.Hash, File: m.File, StartLine: m.StartLine, EndLine: m.EndLine})
+		}
+
+		score, regions := similarity.Compare(marks, theirs)
+		if score == 0 || score < uc.cfg.SimilarityThreshold {
+			continue
+		}
+		_, theirRegions := similarity.Compare(theirs, marks)
+
+		r.SetMatch(&domain.SimilarityMatch{
+			ChallengeID: o.ChallengeID,
+			UserID:      o.UserID,
+			Commit:      o.Commit,
+			Score:       score,
+			Regions:     matchRegions(regions),
+			FoundAt:     now,
+		})
+		theirMatches[o.ChallengeID] = &domain.SimilarityMatch{
+			ChallengeID: c.ID,
+			UserID:      c.ForUserID,
+			Commit:      sha,
+			Score:       score,
+			Regions:     matchRegions(theirRegions),
+			FoundAt:     now,
+		}
+	}
+
+	_, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		tp.Similarity = r
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save similarity report for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	for id, m := range theirMatches {
+		_, err = uc.c.Modify(ctx, p.AccountID, id, func(oc *domain.Challenge) error {
+			otp, err := oc.TaskProgress(p.TaskID)
+			if err != nil {
+				return err
+			}
+			if otp.Similarity == nil {
+				if m == nil {
+					return nil
+				}
+				otp.Similarity = &domain.SimilarityReport{Matches: []*domain.SimilarityMatch{}}
+			}
+			if m == nil {
+				otp.Similarity.RemoveMatch(c.ID)
+			} else {
+				otp.Similarity.SetMatch(m)
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not save similarity report for challenge %s.%s task %s", p.AccountID, id, p.TaskID)
+		}
+	}
+
+	zap.S().Infow(
+		"checked similarity",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"commit", sha,
+		"fingerprints", len(marks),
+		"matches", len(r.Matches),
+	)
+
+	return nil
+}
+
+// matchRegions returns the largest matching regions.
+func matchRegions(regions []similarity.Region) []*domain.MatchRegion {
+	if len(regions) > maxMatchRegions {
+		regions = regions[:maxMatchRegions]
+	}
+
+	res := []*domain.MatchRegion{}
+	for _, mr := range regions {
+		res = append(res, &domain.MatchRegion{
+			File:           mr.File,
+			StartLine:      mr.StartLine,
+			EndLine:        mr.EndLine,
+			OtherFile:      mr.OtherFile,
+			OtherStartLine: mr.OtherStartLine,
+			OtherEndLine:   mr.OtherEndLine,
+		})
+	}
+
+	return res
+}
+
+// AnalyzeTimeline lists the commits the candidate pushed to their repo
+// and saves a timeline of them, with estimated working time and commits
+// pushed after the deadline, on the challenge. Push times come from
+// Github, since commit dates are set by the candidate. Commits pushed
+// before the repo was handed to the candidate, i.e. the starter code,
+// are left out. Analyzing the repo again replaces the timeline.
+func (uc *UseCase) AnalyzeTimeline(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.AnalyzeTimelinePayload)
+
+	c, tp, _, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	repo := tp.Repo
+	if repo.Status == domain.RepoStatusDeleted {
+		return domain.Permanent(errors.Errorf("repo %s/%s has been deleted", repo.Owner, repo.Name))
+	}
+
+	var commits []*domain.TimelineCommit
+
+	var next string
+	for {
+		cs, res, err := uc.g.ListCommits(repo.Owner, repo.Name, next)
+		if err != nil {
+			return errors.Wrapf(err, "could not list commits in %s/%s", repo.Owner, repo.Name)
+		}
+
+		for _, gc := range cs {
+			// Committer dates are only shown, so a bad one isn't a reason
+			// to leave a commit out.
+			at, _ := time.Parse(time.RFC3339, gc.Commit.Committer.Date)
+
+			author := gc.Commit.Author.Name
+			if gc.Author != nil {
+				author = gc.Author.Login
+			}
+
+			commits = append(commits, &domain.TimelineCommit{
+				SHA:         gc.SHA,
+				Message:     strings.SplitN(gc.Commit.Message, "\n", 2)[0],
+				Author:      author,
+				CommittedAt: at,
+			})
+		}
+
+		if !res.HasNext() {
+			break
+		}
+		next = res.Next
+	}
+
+	// Github lists commits newest first.
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	pushes, lastPushAt, err := uc.pushes(repo)
+	if err != nil {
+		return err
+	}
+
+	domain.SetPushTimes(commits, pushes, lastPushAt)
+
+	var work []*domain.TimelineCommit
+	for _, tc := range commits {
+		if repo.ProvisionedAt != nil && tc.PushedAt != nil && !tc.PushedAt.After(*repo.ProvisionedAt) {
+			continue
+		}
+		work = append(work, tc)
+	}
+
+	deadlineAt := tp.DeadlineAt
+	if deadlineAt == nil {
+		deadlineAt = c.ExpiresAt
+	}
+
+	tl := domain.NewCommitTimeline(work, deadlineAt, time.Now())
+
+	_, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		tp.Timeline = tl
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save timeline for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	zap.S().Infow(
+		"analyzed timeline",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"commits", len(tl.Commits),
+		"active_time", tl.ActiveTime,
+		"late_commits", tl.LateCommits,
+	)
+
+	return nil
+}
+
+// pushes lists the pushes to a repo that Github still has events for,
+// and returns them with the time of the repo's latest push, if any.
+func (uc *UseCase) pushes(repo *domain.TaskRepo) ([]*domain.Push, *time.Time, error) {
+	var pushes []*domain.Push
+
+	var next string
+	for {
+		es, res, err := uc.g.ListEvents(repo.Owner, repo.Name, next)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "could not list events in %s/%s", repo.Owner, repo.Name)
+		}
+
+		for _, e := range es {
+			if e.Type != github.EventTypePush {
+				continue
+			}
+			at, err := time.Parse(time.RFC3339, e.CreatedAt)
+			if err != nil {
+				continue
+			}
+
+			p := &domain.Push{At: at, Head: e.Payload.Head}
+			for _, ec := range e.Payload.Commits {
+				p.SHAs = append(p.SHAs, ec.SHA)
+			}
+			pushes = append(pushes, p)
+		}
+
+		if !res.HasNext() {
+			break
+		}
+		next = res.Next
+	}
+
+	r, err := uc.g.Repo(repo.Owner, repo.Name)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not get repo %s/%s", repo.Owner, repo.Name)
+	}
+
+	var lastPushAt *time.Time
+	if at, err := time.Parse(time.RFC3339, r.PushedAt); err == nil {
+		lastPushAt = &at
+	}
+
+	return pushes, lastPushAt, nil
+}
+
+// Diff compares the latest commit in the candidate's repo, or the repo's
+// snapshot once it's been archived, to the task's starter code. Only
+// admins and hiring managers can see diffs.
+func (uc *UseCase) Diff(ctx context.Context, id, taskID domain.ID) (*domain.SubmissionDiff, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot see submissions", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	c, tp, t, err := uc.task(ctx, domain.TaskRef{AccountID: se.User.AccountID, ChallengeID: id, TaskID: taskID})
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := uc.i.Blind(ctx, se.User, c.ForUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var starter []github.File
+	if t.Details.GithubRepoName != "" {
+		starter, err = uc.starter(t, tp.Repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sha, zipball, err := uc.code(tp.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var final []github.File
+	if zipball != nil {
+		final, err = github.ExtractZipball(zipball)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not extract commit %s", sha)
+		}
+	}
+
+	d, err := diffFiles(sha, starter, final)
+	if err != nil {
+		return nil, err
+	}
+	b.Diff(c.ForUserID, d)
+
+	return d, nil
+}
+
+// diffFiles compares two sets of files. Files that are too large are
+// left undiffed, and patches are left out once the diff gets too large.
+func diffFiles(sha string, before, after []github.File) (*domain.SubmissionDiff, error) {
+	d := &domain.SubmissionDiff{Commit: sha, Files: []*domain.FileDiff{}}
+
+	old, cur := fileMap(before), fileMap(after)
+
+	var paths []string
+	for p := range old {
+		paths = append(paths, p)
+	}
+	for p := range cur {
+		if _, found := old[p]; !found {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var size int
+
+	for _, p := range paths {
+		a, inOld := old[p]
+		b, inCur := cur[p]
+
+		fd := &domain.FileDiff{Path: p}
+		switch {
+		case !inOld:
+			fd.Status = domain.FileDiffStatusAdded
+			d.Added++
+		case !inCur:
+			fd.Status = domain.FileDiffStatusRemoved
+			d.Removed++
+		case string(a) != string(b):
+			fd.Status = domain.FileDiffStatusModified
+			d.Modified++
+		default:
+			continue
+		}
+		d.Files = append(d.Files, fd)
+
+		fd.Binary = diff.IsBinary(a) || diff.IsBinary(b)
+		fd.TooLarge = len(a) > maxDiffFileSize || len(b) > maxDiffFileSize
+		if fd.Binary || fd.TooLarge {
+			continue
+		}
+
+		patch, err := diff.Unified(a, b)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not diff %s", p)
+		}
+
+		fd.Additions = patch.Additions
+		fd.Deletions = patch.Deletions
+		d.Additions += patch.Additions
+		d.Deletions += patch.Deletions
+
+		if size+len(patch.Text) > maxDiffSize {
+			d.Truncated = true
+			continue
+		}
+		size += len(patch.Text)
+		fd.Patch = patch.Text
+	}
+
+	return d, nil
+}
+
+// starter returns the starter code copied into a task repo: the files
+// in the task's source repo, given as `owner/name` or just `name` for
+// repos owned by the token owner, at the commit they were copied from.
+// Repos provisioned before the commit was recorded fall back to the
+// source repo's default branch.
+func (uc *UseCase) starter(t *domain.Task, repo *domain.TaskRepo) ([]github.File, error) {
+	src := t.Details.GithubRepoName
+
+	owner, name := "", src
+	if i := strings.Index(src, "/"); i >= 0 {
+		owner, name = src[:i], src[i+1:]
+	} else {
+		u, err := uc.g.CurrentUser()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get current Github user")
+		}
+		owner = u.Login
+	}
+
+	ref := ""
+	if repo != nil {
+		ref = repo.StarterCommit
+	}
+
+	res, err := uc.g.DownloadRepo(owner, name, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download %s/%s", owner, name)
+	}
+
+	files, err := github.ExtractZipball(res.Body)
+	if err != nil {
+		return nil, domain.Permanent(errors.Wrapf(err, "could not extract %s/%s", owner, name))
+	}
+
+	return files, nil
+}
+
+// measure computes static code metrics for files and runs `go vet` on
+// them in a sandbox.
+func (uc *UseCase) measure(ctx context.Context, files []github.File) (*domain.CodeMetrics, error) {
+	r := metrics.Analyze(fileMap(files))
+
+	m := &domain.CodeMetrics{
+		Files:             r.Files,
+		Lines:             r.Lines,
+		Functions:         r.Functions,
+		AvgComplexity:     r.AvgComplexity,
+		MaxComplexity:     r.MaxComplexity,
+		ComplexFunctions:  r.ComplexFunctions,
+		AvgFunctionLength: r.AvgFunctionLength,
+		MaxFunctionLength: r.MaxFunctionLength,
+		LongFunctions:     r.LongFunctions,
+		Duplication:       r.Duplication,
+		Packages:          r.Packages,
+		AvgCoupling:       r.AvgCoupling,
+		MaxCoupling:       r.MaxCoupling,
+		LintFindings:      r.LintFindings,
+		Hotspots:          []*domain.FunctionMetrics{},
+	}
+	for _, fn := range r.Hotspots {
+		m.Hotspots = append(m.Hotspots, &domain.FunctionMetrics{
+			Name:       fn.Name,
+			File:       fn.File,
+			Line:       fn.Line,
+			Lines:      fn.Lines,
+			Complexity: fn.Complexity,
+		})
+	}
+
+	if r.Files == 0 {
+		return m, nil
+	}
+
+	dir, err := write(files)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	out, err := uc.sb.Run(ctx, dir, "", vetCommand)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not run go vet")
+	}
+
+	// go vet exits with an error both when it finds problems and when it
+	// can't run at all, e.g. because dependencies can't be downloaded.
+	n := len(vetFinding.FindAll(append(out.Stdout, out.Stderr...), -1))
+	if !out.TimedOut && (out.ExitCode == 0 || n > 0) {
+		m.VetFindings = &n
+	}
+
+	return m, nil
+}
+
+// task returns a challenge with one of its tasks and the candidate's
+// progress on it, which must have a repo.
+func (uc *UseCase) task(ctx context.Context, ref domain.TaskRef) (*domain.Challenge, *domain.TaskProgress, *domain.Task, error) {
+	c, err := uc.c.Get(ctx, ref.AccountID, ref.ChallengeID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "could not find challenge %s.%s", ref.AccountID, ref.ChallengeID)
+	}
+
+	tp, err := c.TaskProgress(ref.TaskID)
+	if err != nil {
+		return nil, nil, nil, domain.Permanent(err)
+	}
+
+	var t *domain.Task
+	for _, ct := range c.Details.Tasks {
+		if ct.ID == ref.TaskID {
+			t = ct
+		}
+	}
+	if t == nil {
+		return nil, nil, nil, domain.Permanent(errors.Errorf("could not find task %s in challenge %s", ref.TaskID, c.ID))
+	}
+
+	if tp.Repo == nil || tp.Repo.Name == "" {
+		return nil, nil, nil, domain.Permanent(errors.Errorf("task %s in challenge %s has no repo to grade", ref.TaskID, c.ID))
+	}
+
+	return c, tp, t, nil
+}
+
+// code returns the latest commit in a candidate's repo as a zipball, or
+// the repo's snapshot once it's been archived. The zipball is nil if the
+// repo has no commits.
+func (uc *UseCase) code(repo *domain.TaskRepo) (sha string, zipball []byte, err error) {
+	if repo.Status == domain.RepoStatusArchived || repo.Status == domain.RepoStatusDeleted {
+		if repo.SnapshotKey != "" {
+			zipball, err = uc.s.Get(repo.SnapshotKey)
+			if err != nil {
+				return "", nil, errors.Wrapf(err, "could not get snapshot of repo %s/%s", repo.Owner, repo.Name)
+			}
+		}
+		return repo.HeadCommit, zipball, nil
+	}
+
+	_, sha, err = uc.g.HeadCommit(repo.Owner, repo.Name)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "could not get head commit of repo %s/%s", repo.Owner, repo.Name)
+	}
+	if sha == "" {
+		return "", nil, nil
+	}
+
+	res, err := uc.g.DownloadRepo(repo.Owner, repo.Name, sha)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "could not download repo %s/%s at %s", repo.Owner, repo.Name, sha)
+	}
+
+	return sha, res.Body, nil
+}
+
+// fileMap maps the paths of files to their content.
+func fileMap(files []github.File) map[string][]byte {
+	m := make(map[string][]byte, len(files))
+	for _, f := range files {
+		m[f.Path] = f.Content
+	}
+	return m
+}
+
+// write writes files to a new temp dir, which the caller must remove.
+func write(files []github.File) (string, error) {
+	dir, err := ioutil.TempDir("", "codecoach-grading-")
+	if err != nil {
+		return "", errors.Wrap(err, "could not create grading dir")
+	}
+
+	for _, f := range files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Path))
+
+		err = os.MkdirAll(filepath.Dir(name), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(name, f.Content, 0644)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", errors.Wrapf(err, "could not write %s to grading dir", f.Path)
+		}
+	}
+
+	return dir, nil
+}
+
+// nonJSON returns all lines in s that aren't JSON objects.
+func nonJSON(s string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// tail returns the last max bytes of s.
+func tail(s string, max int) string {
+	if len(s) > max {
+		return s[len(s)-max:]
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// UseCase ...
+type UseCase struct {
+	a  domain.AccountDAO
+	u  domain.UserDAO
+	au domain.AuditDAO
+}
+
+var _ domain.IdentityUseCases = &UseCase{}
+
+// New ...
+func New(a domain.AccountDAO, u domain.UserDAO, au domain.AuditDAO) *UseCase {
+	return &UseCase{a, u, au}
+}
+
+// Reveal ...
+func (uc *UseCase) Reveal(ctx context.Context, userID domain.ID) (*domain.User, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot reveal identities", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	u, err := uc.u.Get(ctx, se.User.AccountID, userID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find user %s.%s", se.User.AccountID, userID)
+	}
+	if u.Role != domain.RoleCandidate {
+		return nil, errors.Errorf("user %s.%s (role: %s) is not a candidate", u.AccountID, u.ID, u.Role)
+	}
+
+	err = uc.au.Create(ctx, domain.NewAuditEntry(se.User.AccountID, se.User.ID, domain.AuditActionRevealIdentity, u.ID, ""))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not log reveal of candidate %s.%s", u.AccountID, u.ID)
+	}
+
+	return u, nil
+}
+
+// Blind ...
+func (uc *UseCase) Blind(ctx context.Context, viewer *domain.User, candidateIDs ...domain.ID) (*domain.Blind, error) {
+	// Admins see everything, and candidates only ever see themselves.
+	if viewer.Role != domain.RoleHiringManager || len(candidateIDs) == 0 {
+		return nil, nil
+	}
+
+	a, err := uc.a.Get(ctx, viewer.AccountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find account %s", viewer.AccountID)
+	}
+	if !a.Settings.BlindReview {
+		return nil, nil
+	}
+
+	reveals, err := uc.au.List(ctx, viewer.AccountID, domain.AuditActionRevealIdentity)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list revealed candidates in account %s", viewer.AccountID)
+	}
+
+	revealed := make(map[domain.ID]bool)
+	for _, e := range reveals {
+		revealed[e.TargetID] = true
+	}
+
+	b := domain.NewBlind()
+	for _, id := range candidateIDs {
+		if revealed[id] || b.Hides(id) {
+			continue
+		}
+
+		u, err := uc.u.Get(ctx, viewer.AccountID, id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not find candidate %s.%s", viewer.AccountID, id)
+		}
+		b.Add(u)
+	}
+
+	return b, nil
+}
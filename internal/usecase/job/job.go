@@ -0,0 +1,114 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"github.com/anrid/codecoach/internal/config"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// UseCase ...
+type UseCase struct {
+	c *config.Config
+	j domain.JobDAO
+}
+
+var _ domain.JobUseCases = &UseCase{}
+
+// New ...
+func New(c *config.Config, j domain.JobDAO) *UseCase {
+	return &UseCase{c, j}
+}
+
+// Enqueue adds a new job to the queue. The job runs as soon as a
+// worker is available, or at RunAt if given.
+func (uc *UseCase) Enqueue(ctx context.Context, a domain.EnqueueJobArgs) (*domain.Job, error) {
+	j, err := domain.NewJob(a.Name, a.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create job")
+	}
+
+	if a.ID != "" {
+		j.ID = a.ID
+	}
+	j.AccountID = a.AccountID
+
+	j.MaxAttempts = a.MaxAttempts
+	if j.MaxAttempts < 1 {
+		j.MaxAttempts = uc.c.JobMaxAttempts
+	}
+
+	if a.RunAt != nil && a.RunAt.After(time.Now()) {
+		j.Schedule(*a.RunAt)
+	}
+
+	err = uc.j.Create(ctx, j)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not enqueue job")
+	}
+
+	zap.S().Infow(
+		"enqueued job",
+		"job", j.ID,
+		"type", j.Type,
+		"name", j.Name,
+		"run_at", j.RunAt,
+	)
+
+	return j, nil
+}
+
+// ListDead lists all jobs in the current account that have used up
+// all their attempts.
+func (uc *UseCase) ListDead(ctx context.Context) (*domain.ListJobsResult, error) {
+	se, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	js, total, err := uc.j.List(ctx, se.User.AccountID, domain.StatusDead)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list dead jobs in account %s", se.User.AccountID)
+	}
+
+	return &domain.ListJobsResult{Jobs: js, Total: total}, nil
+}
+
+// Retry puts a dead job back in the queue with a fresh set of attempts.
+func (uc *UseCase) Retry(ctx context.Context, id domain.ID) (*domain.Job, error) {
+	se, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := uc.j.Requeue(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not retry job %s.%s", se.User.AccountID, id)
+	}
+
+	zap.S().Infow(
+		"retrying dead job",
+		"job", j.ID,
+		"type", j.Type,
+		"name", j.Name,
+		"by_user", se.User.ID,
+	)
+
+	return j, nil
+}
+
+func requireAdmin(ctx context.Context) (*domain.Session, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot manage jobs", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	return se, nil
+}
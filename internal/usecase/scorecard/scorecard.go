@@ -0,0 +1,348 @@
+package scorecard
+
+import (
+	"context"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/agreement"
+	"github.com/pkg/errors"
+)
+
+// UseCase ...
+type UseCase struct {
+	c domain.ChallengeDAO
+	t domain.TaskDAO
+	s domain.ScorecardDAO
+}
+
+var _ domain.ScorecardUseCases = &UseCase{}
+
+// New ...
+func New(c domain.ChallengeDAO, t domain.TaskDAO, s domain.ScorecardDAO) *UseCase {
+	return &UseCase{c, t, s}
+}
+
+// Submit ...
+func (uc *UseCase) Submit(ctx context.Context, id, taskID domain.ID, a domain.SubmitScorecardArgs) (*domain.Scorecard, error) {
+	se, err := requireReviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find challenge %s.%s", se.User.AccountID, id)
+	}
+
+	_, err = c.TaskProgress(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scores are given against the task's current rubric, since rubrics
+	// are often written after challenges have been sent out.
+	t, err := uc.t.Get(ctx, se.User.AccountID, taskID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find task %s.%s", se.User.AccountID, taskID)
+	}
+	if t.Details.Rubric == nil {
+		return nil, errors.Errorf("task %s has no rubric", taskID)
+	}
+
+	overall, err := t.Details.Rubric.Overall(a.Scores)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid scores")
+	}
+
+	s, err := uc.s.Modify(ctx, se.User.AccountID, id, taskID, se.User.ID, func(s *domain.Scorecard) error {
+		if !s.CreatedAt.IsZero() {
+			editedAt := s.CreatedAt
+			if s.UpdatedAt != nil {
+				editedAt = *s.UpdatedAt
+			}
+			s.Details.History = append(s.Details.History, &domain.ScorecardRevision{
+				Rubric:   s.Details.Rubric,
+				Scores:   s.Details.Scores,
+				Comment:  s.Details.Comment,
+				Overall:  s.Details.Overall,
+				EditedAt: editedAt,
+			})
+		}
+
+		s.Details.Rubric = t.Details.Rubric
+		s.Details.Scores = a.Scores
+		s.Details.Comment = a.Comment
+		s.Details.Overall = overall
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not save scorecard for challenge %s.%s task %s", se.User.AccountID, id, taskID)
+	}
+
+	return s, nil
+}
+
+// List ...
+func (uc *UseCase) List(ctx context.Context, id domain.ID) (*domain.ChallengeScorecards, error) {
+	se, err := requireReviewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := uc.c.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find challenge %s.%s", se.User.AccountID, id)
+	}
+
+	ss, err := uc.s.ListByChallenge(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list scorecards of challenge %s.%s", se.User.AccountID, id)
+	}
+
+	return summarize(c, ss, se.User.ID), nil
+}
+
+// summarize averages the reviewers' scores per task, in the challenge's
+// task order, and across the scored tasks. Other reviewers' scores on
+// tasks the viewer hasn't scored yet are left out.
+func summarize(c *domain.Challenge, ss []*domain.Scorecard, viewerID domain.ID) *domain.ChallengeScorecards {
+	res := &domain.ChallengeScorecards{Scorecards: []*domain.Scorecard{}, Tasks: []*domain.TaskScore{}}
+
+	var total float64
+	var scored int
+	for _, t := range c.Details.Tasks {
+		ts := &domain.TaskScore{TaskID: t.ID, Blind: true}
+
+		var tss []*domain.Scorecard
+		for _, s := range ss {
+			if s.TaskID != t.ID {
+				continue
+			}
+			tss = append(tss, s)
+			if s.ReviewerID == viewerID {
+				ts.Blind = false
+			}
+		}
+		if len(tss) == 0 {
+			continue
+		}
+
+		ts.Reviewers = len(tss)
+		res.Tasks = append(res.Tasks, ts)
+
+		if ts.Blind {
+			continue
+		}
+
+		res.Scorecards = append(res.Scorecards, tss...)
+
+		var sum float64
+		for _, s := range tss {
+			sum += s.Details.Overall
+		}
+		ts.Overall = sum / float64(len(tss))
+		ts.Criteria = aggregate(tss)
+
+		total += ts.Overall
+		scored++
+	}
+
+	if scored > 0 {
+		res.Overall = total / float64(scored)
+	}
+
+	return res
+}
+
+// aggregate sums up the scores on each criterion, in rubric order.
+func aggregate(ss []*domain.Scorecard) []*domain.CriterionAggregate {
+	var res []*domain.CriterionAggregate
+	byName := make(map[string]*domain.CriterionAggregate)
+	counts := make(map[string]int)
+
+	for _, s := range ss {
+		for _, cs := range s.Details.Scores {
+			a := byName[cs.Criterion]
+			if a == nil {
+				a = &domain.CriterionAggregate{Criterion: cs.Criterion, Min: cs.Score, Max: cs.Score}
+				byName[cs.Criterion] = a
+				res = append(res, a)
+			}
+			if cs.Score < a.Min {
+				a.Min = cs.Score
+			}
+			if cs.Score > a.Max {
+				a.Max = cs.Score
+			}
+			a.Mean += float64(cs.Score)
+			counts[cs.Criterion]++
+		}
+	}
+
+	for _, a := range res {
+		a.Mean /= float64(counts[a.Criterion])
+	}
+
+	return res
+}
+
+// Calibration ...
+func (uc *UseCase) Calibration(ctx context.Context) (*domain.CalibrationReport, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot see reviewer calibration", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	ss, err := uc.s.ListByAccount(ctx, se.User.AccountID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list scorecards in account %s", se.User.AccountID)
+	}
+
+	return calibrate(ss, time.Now()), nil
+}
+
+// calibrate compares the scores that different reviewers gave on the
+// same challenge tasks.
+func calibrate(ss []*domain.Scorecard, now time.Time) *domain.CalibrationReport {
+	res := &domain.CalibrationReport{
+		Scorecards: len(ss),
+		Criteria:   []*domain.CriterionAgreement{},
+		Reviewers:  []*domain.ReviewerCalibration{},
+		CreatedAt:  now,
+	}
+
+	// Group scorecards by challenge task, keeping the order in which
+	// tasks were first scored.
+	type sample struct {
+		challengeID, taskID domain.ID
+	}
+	var samples []sample
+	groups := make(map[sample][]*domain.Scorecard)
+	for _, s := range ss {
+		k := sample{s.ChallengeID, s.TaskID}
+		if groups[k] == nil {
+			samples = append(samples, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+
+	reviewers := make(map[domain.ID]*domain.ReviewerCalibration)
+	for _, s := range ss {
+		rc := reviewers[s.ReviewerID]
+		if rc == nil {
+			rc = &domain.ReviewerCalibration{ReviewerID: s.ReviewerID}
+			reviewers[s.ReviewerID] = rc
+			res.Reviewers = append(res.Reviewers, rc)
+		}
+		rc.Scorecards++
+	}
+
+	var overall [][]float64
+	var criteria []string
+	units := make(map[string][][]float64)
+
+	for _, k := range samples {
+		g := groups[k]
+		if len(g) < 2 {
+			continue
+		}
+		res.Samples++
+
+		var values []float64
+		var sum float64
+		for _, s := range g {
+			values = append(values, s.Details.Overall)
+			sum += s.Details.Overall
+		}
+		overall = append(overall, values)
+
+		for _, s := range g {
+			others := (sum - s.Details.Overall) / float64(len(g)-1)
+			rc := reviewers[s.ReviewerID]
+			rc.Bias += s.Details.Overall - others
+			rc.Samples++
+		}
+
+		scores := make(map[string][]float64)
+		for _, s := range g {
+			for _, cs := range s.Details.Scores {
+				if units[cs.Criterion] == nil && scores[cs.Criterion] == nil {
+					criteria = append(criteria, cs.Criterion)
+				}
+				scores[cs.Criterion] = append(scores[cs.Criterion], float64(cs.Score))
+			}
+		}
+		for name, values := range scores {
+			units[name] = append(units[name], values)
+		}
+	}
+
+	res.Alpha = alpha(overall)
+
+	for _, name := range criteria {
+		ca := &domain.CriterionAgreement{Criterion: name, Alpha: alpha(units[name])}
+
+		var variance float64
+		for _, u := range units[name] {
+			if len(u) < 2 {
+				continue
+			}
+			ca.Samples++
+			variance += agreement.Variance(u)
+		}
+		if ca.Samples > 0 {
+			ca.Variance = variance / float64(ca.Samples)
+		}
+
+		res.Criteria = append(res.Criteria, ca)
+	}
+
+	for _, rc := range res.Reviewers {
+		if rc.Samples > 0 {
+			rc.Bias /= float64(rc.Samples)
+		}
+
+		switch {
+		case rc.Samples < domain.MinBiasSamples:
+			rc.Tendency = domain.TendencyUnknown
+		case rc.Bias <= -domain.BiasThreshold:
+			rc.Tendency = domain.TendencyHarsh
+		case rc.Bias >= domain.BiasThreshold:
+			rc.Tendency = domain.TendencyLenient
+		default:
+			rc.Tendency = domain.TendencyNeutral
+		}
+	}
+
+	return res
+}
+
+// alpha returns Krippendorff's alpha of units, or nil if it's undefined.
+func alpha(units [][]float64) *float64 {
+	a, ok := agreement.Alpha(units)
+	if !ok {
+		return nil
+	}
+	return &a
+}
+
+// requireReviewer returns the current session if the current user is
+// allowed to score candidates, i.e. is an admin or a hiring manager.
+func requireReviewer(ctx context.Context) (*domain.Session, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot score candidates", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	return se, nil
+}
@@ -0,0 +1,202 @@
+package task
+
+import (
+	"context"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/pkg/errors"
+)
+
+// UseCase ...
+type UseCase struct {
+	t domain.TaskDAO
+}
+
+var _ domain.TaskUseCases = &UseCase{}
+
+// New ...
+func New(t domain.TaskDAO) *UseCase {
+	return &UseCase{t}
+}
+
+// Create ...
+func (uc *UseCase) Create(ctx context.Context, a domain.CreateTaskArgs) (*domain.Task, error) {
+	se, err := requireTaskManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := domain.NewTask(domain.NewTaskArgs{
+		AccountID: se.User.AccountID,
+		Name:      a.Name,
+		Type:      a.Type,
+		Details: domain.TaskDetails{
+			GithubRepoName:   a.GithubRepoName,
+			TimeLimit:        a.TimeLimit,
+			ReviewBranch:     a.ReviewBranch,
+			ExpectedFindings: a.ExpectedFindings,
+			TestCommand:      a.TestCommand,
+			TestReport:       a.TestReport,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create task")
+	}
+
+	err = uc.t.Create(ctx, t)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create task")
+	}
+
+	return t, nil
+}
+
+// Get ...
+func (uc *UseCase) Get(ctx context.Context, id domain.ID) (*domain.Task, error) {
+	se, err := requireTaskManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := uc.t.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find task %s.%s", se.User.AccountID, id)
+	}
+
+	return t, nil
+}
+
+// Update ...
+func (uc *UseCase) Update(ctx context.Context, id domain.ID, a domain.UpdateTaskArgs) (*domain.Task, error) {
+	se, err := requireTaskManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := uc.t.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find task %s.%s", se.User.AccountID, id)
+	}
+
+	var updates []domain.Field
+
+	if a.Name != "" {
+		updates = append(updates, domain.Field{Name: "name", Value: a.Name})
+	}
+	if a.GithubRepoName != "" || a.TimeLimit != 0 || a.ReviewBranch != "" || a.ExpectedFindings != nil || a.TestCommand != "" || a.TestReport != "" {
+		if a.GithubRepoName != "" {
+			old.Details.GithubRepoName = a.GithubRepoName
+		}
+		if a.TimeLimit != 0 {
+			old.Details.TimeLimit = a.TimeLimit
+		}
+		if a.ReviewBranch != "" {
+			old.Details.ReviewBranch = a.ReviewBranch
+		}
+		if a.ExpectedFindings != nil {
+			if len(a.ExpectedFindings) > 0 && old.Type != domain.TaskTypeCodeReview {
+				return nil, errors.Errorf("only %s tasks can have expected findings", domain.TaskTypeCodeReview)
+			}
+			old.Details.ExpectedFindings = a.ExpectedFindings
+		}
+		if a.TestCommand != "" {
+			if old.Type == domain.TaskTypeCodeReview {
+				return nil, errors.Errorf("%s tasks cannot have a test command", domain.TaskTypeCodeReview)
+			}
+			old.Details.TestCommand = a.TestCommand
+		}
+		if a.TestReport != "" {
+			old.Details.TestReport = a.TestReport
+		}
+		updates = append(updates, domain.Field{Name: "details", Value: old.Details})
+	}
+
+	up, err := uc.t.Update(ctx, se.User.AccountID, id, updates)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update task %s.%s", se.User.AccountID, id)
+	}
+
+	return up, nil
+}
+
+// Delete ...
+func (uc *UseCase) Delete(ctx context.Context, id domain.ID) error {
+	se, err := requireTaskManager(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = uc.t.Delete(ctx, se.User.AccountID, id)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete task %s.%s", se.User.AccountID, id)
+	}
+
+	return nil
+}
+
+// List ...
+func (uc *UseCase) List(ctx context.Context, a domain.ListTasksArgs) (*domain.ListTasksResult, error) {
+	se, err := requireTaskManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, total, err := uc.t.List(ctx, se.User.AccountID, a.Types)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list tasks in account %s", se.User.AccountID)
+	}
+
+	return &domain.ListTasksResult{
+		Tasks: ts,
+		Total: total,
+	}, nil
+}
+
+// SetRubric ...
+func (uc *UseCase) SetRubric(ctx context.Context, id domain.ID, r *domain.Rubric) (*domain.Task, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot define rubrics", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	if r != nil {
+		err = r.Validate()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid rubric")
+		}
+	}
+
+	old, err := uc.t.Get(ctx, se.User.AccountID, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not find task %s.%s", se.User.AccountID, id)
+	}
+
+	old.Details.Rubric = r
+
+	up, err := uc.t.Update(ctx, se.User.AccountID, id, []domain.Field{{Name: "details", Value: old.Details}})
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not update task %s.%s", se.User.AccountID, id)
+	}
+
+	return up, nil
+}
+
+// requireTaskManager returns the current session if the current user
+// is allowed to manage the account's task library, i.e. is an admin
+// or a hiring manager.
+func requireTaskManager(ctx context.Context) (*domain.Session, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !se.User.HasRole(domain.RoleAdmin, domain.RoleHiringManager) {
+		return nil, errors.Errorf("current user %s.%s (role: %s) cannot manage tasks", se.User.AccountID, se.User.ID, se.User.Role)
+	}
+
+	return se, nil
+}
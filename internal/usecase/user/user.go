@@ -2,10 +2,16 @@ package user
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/anrid/codecoach/internal/config"
 	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/mailer"
+	"github.com/anrid/codecoach/internal/pkg/ratelimit"
 	token_gen "github.com/anrid/codecoach/internal/pkg/token"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -13,16 +19,21 @@ import (
 
 // UseCase ...
 type UseCase struct {
-	c *config.Config
-	a domain.AccountDAO
-	u domain.UserDAO
+	c  *config.Config
+	a  domain.AccountDAO
+	u  domain.UserDAO
+	se domain.SessionDAO
+	pr domain.PasswordResetDAO
+	j  domain.JobUseCases
+	m  mailer.Mailer
+	rl *ratelimit.Limiter
 }
 
 var _ domain.UserUseCases = &UseCase{}
 
 // New ...
-func New(c *config.Config, a domain.AccountDAO, u domain.UserDAO) *UseCase {
-	return &UseCase{c, a, u}
+func New(c *config.Config, a domain.AccountDAO, u domain.UserDAO, se domain.SessionDAO, pr domain.PasswordResetDAO, j domain.JobUseCases, m mailer.Mailer) *UseCase {
+	return &UseCase{c, a, u, se, pr, j, m, ratelimit.New(c.PasswordResetRateLimit, time.Hour)}
 }
 
 // Signup ...
@@ -66,36 +77,36 @@ func (uc *UseCase) Signup(ctx context.Context, sa domain.SignupArgs) (*domain.Si
 		return nil, errors.Wrap(err, "could not create account")
 	}
 
-	// Generate access token.
-	u.Token = token_gen.New()
-
-	// Make sure token expires at some point in the future.
-	expiresAt := time.Now().Add(uc.c.TokenExpires)
-	u.TokenExpiresAt = &expiresAt
-
 	err = uc.u.Create(ctx, u)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create user")
 	}
 
+	us, rt, err := uc.createSession(ctx, u, sa.Client)
+	if err != nil {
+		return nil, err
+	}
+
 	// Log successful signup.
 	zap.S().Infow(
 		"signup successful",
 		"account", a.ID,
 		"user", u.ID,
 		"email", u.Email,
-		"token_expires", u.TokenExpiresAt,
+		"session", us.ID,
+		"token_expires", us.ExpiresAt.String(),
 	)
 
 	return &domain.SignupResult{
-		Account: a,
-		User:    u,
-		Token:   u.Token,
+		Account:      a,
+		User:         u,
+		Token:        us.Token,
+		RefreshToken: rt.Token,
 	}, nil
 }
 
 // Login ...
-func (uc *UseCase) Login(ctx context.Context, accountCode, email, password string) (*domain.LoginResult, error) {
+func (uc *UseCase) Login(ctx context.Context, accountCode, email, password string, ci domain.ClientInfo) (*domain.LoginResult, error) {
 	// Get account by account code.
 	accountCode = domain.CreateCode(accountCode)
 	if len(accountCode) < 2 {
@@ -120,19 +131,10 @@ func (uc *UseCase) Login(ctx context.Context, accountCode, email, password strin
 		return nil, errors.Wrap(err, "invalid account, email or password")
 	}
 
-	// Generate access token.
-	token := token_gen.New()
-
-	// Make sure token expires at some point in the future.
-	tokenExpires := time.Now().Add(uc.c.TokenExpires)
-
-	// Update user's token.
-	_, err = uc.u.Update(ctx, u.AccountID, u.ID, []domain.Field{
-		{Name: "token", Value: token},
-		{Name: "token_expires_at", Value: tokenExpires},
-	})
+	// Start a new session, leaving the user's other sessions be.
+	us, rt, err := uc.createSession(ctx, u, ci)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not update user's token")
+		return nil, err
 	}
 
 	// Log successful login.
@@ -141,18 +143,20 @@ func (uc *UseCase) Login(ctx context.Context, accountCode, email, password strin
 		"account", u.AccountID,
 		"user", u.ID,
 		"email", u.Email,
-		"token_expires", tokenExpires.String(),
+		"session", us.ID,
+		"token_expires", us.ExpiresAt.String(),
 	)
 
 	return &domain.LoginResult{
-		Account: a,
-		User:    u,
-		Token:   token,
+		Account:      a,
+		User:         u,
+		Token:        us.Token,
+		RefreshToken: rt.Token,
 	}, nil
 }
 
 // GithubLogin ...
-func (uc *UseCase) GithubLogin(ctx context.Context, accountCode string, githubID int64) (*domain.LoginResult, error) {
+func (uc *UseCase) GithubLogin(ctx context.Context, accountCode string, githubID int64, ci domain.ClientInfo) (*domain.LoginResult, error) {
 	// Get account by account code.
 	accountCode = domain.CreateCode(accountCode)
 	if len(accountCode) < 2 {
@@ -171,19 +175,10 @@ func (uc *UseCase) GithubLogin(ctx context.Context, accountCode string, githubID
 		return nil, errors.Wrap(err, "invalid account or github id")
 	}
 
-	// Generate access token.
-	token := token_gen.New()
-
-	// Make sure token expires at some point in the future.
-	tokenExpires := time.Now().Add(uc.c.TokenExpires)
-
-	// Update user's token.
-	_, err = uc.u.Update(ctx, u.AccountID, u.ID, []domain.Field{
-		{Name: "token", Value: token},
-		{Name: "token_expires_at", Value: tokenExpires},
-	})
+	// Start a new session, leaving the user's other sessions be.
+	us, rt, err := uc.createSession(ctx, u, ci)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not update user's token")
+		return nil, err
 	}
 
 	// Log successful login.
@@ -192,16 +187,53 @@ func (uc *UseCase) GithubLogin(ctx context.Context, accountCode string, githubID
 		"account", u.AccountID,
 		"user", u.ID,
 		"email", u.Email,
-		"token_expires", tokenExpires.String(),
+		"session", us.ID,
+		"token_expires", us.ExpiresAt.String(),
 	)
 
 	return &domain.LoginResult{
-		Account: a,
-		User:    u,
-		Token:   token,
+		Account:      a,
+		User:         u,
+		Token:        us.Token,
+		RefreshToken: rt.Token,
 	}, nil
 }
 
+// createSession starts a new session for a user with a fresh
+// access token and refresh token.
+func (uc *UseCase) createSession(ctx context.Context, u *domain.User, ci domain.ClientInfo) (*domain.UserSession, *domain.RefreshToken, error) {
+	us := domain.NewUserSession(u, ci)
+
+	token := token_gen.New()
+	us.SetAccessToken(token, token_gen.Hash(uc.c.TokenSecret, token), us.CreatedAt, uc.c.TokenExpires, uc.c.SessionAbsoluteTimeout)
+
+	err := uc.se.Create(ctx, us)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not create session for user %s.%s", u.AccountID, u.ID)
+	}
+
+	rt, err := uc.createRefreshToken(ctx, us)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return us, rt, nil
+}
+
+// createRefreshToken issues the next refresh token in a session's
+// family.
+func (uc *UseCase) createRefreshToken(ctx context.Context, us *domain.UserSession) (*domain.RefreshToken, error) {
+	token := token_gen.New()
+	rt := domain.NewRefreshToken(us, token, token_gen.Hash(uc.c.TokenSecret, token))
+
+	err := uc.se.CreateRefreshToken(ctx, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	return rt, nil
+}
+
 // GithubGetAvailableAccounts returns all available accounts
 // for an authenticated Github user.
 func (uc *UseCase) GithubGetAvailableAccounts(ctx context.Context, githubID int64) ([]*domain.AccountInfo, error) {
@@ -232,6 +264,11 @@ func (uc *UseCase) Create(ctx context.Context, a domain.CreateUserArgs) (*domain
 		return nil, err
 	}
 
+	// API keys act as an admin, but mustn't be able to make more.
+	if se.APIKey != nil && a.Role == domain.RoleAdmin {
+		return nil, errors.Errorf("api key %s.%s cannot create admins", se.APIKey.AccountID, se.APIKey.ID)
+	}
+
 	u, err := domain.NewUser(domain.NewUserArgs{
 		AccountID:  se.User.AccountID,
 		GivenName:  a.GivenName,
@@ -244,6 +281,8 @@ func (uc *UseCase) Create(ctx context.Context, a domain.CreateUserArgs) (*domain
 		return nil, errors.Wrap(err, "could not create user")
 	}
 
+	u.Profile.GithubLogin = a.GithubLogin
+
 	err = uc.u.Create(ctx, u)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create user")
@@ -266,6 +305,12 @@ func (uc *UseCase) Update(ctx context.Context, accountID, id domain.ID, a domain
 		}
 	}
 
+	// Changing a user's email or password lets you log in as them, so
+	// API keys can't do it.
+	if se.APIKey != nil && (a.Email != "" || a.Password != "") {
+		return nil, errors.Errorf("api key %s.%s cannot change emails or passwords", se.APIKey.AccountID, se.APIKey.ID)
+	}
+
 	old, err := uc.u.Get(ctx, se.User.AccountID, id)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could find user %s.%s", se.User.AccountID, id)
@@ -312,3 +357,333 @@ func (uc *UseCase) List(ctx context.Context, page int) (*domain.ListUsersResult,
 		Total: total,
 	}, nil
 }
+
+// ListSessions ...
+func (uc *UseCase) ListSessions(ctx context.Context) ([]*domain.UserSession, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ss, err := uc.se.ListByUser(ctx, se.User.AccountID, se.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range ss {
+		s.Current = se.UserSession != nil && s.ID == se.UserSession.ID
+	}
+
+	return ss, nil
+}
+
+// Logout ...
+func (uc *UseCase) Logout(ctx context.Context) error {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return err
+	}
+	if se.UserSession == nil {
+		return errors.New("current user has no session to log out of")
+	}
+
+	err = uc.se.Delete(ctx, se.User.AccountID, se.User.ID, se.UserSession.ID)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infow(
+		"logout successful",
+		"account", se.User.AccountID,
+		"user", se.User.ID,
+		"session", se.UserSession.ID,
+	)
+
+	return nil
+}
+
+// RevokeSession ...
+func (uc *UseCase) RevokeSession(ctx context.Context, id domain.ID) error {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Users can only revoke their own sessions.
+	return uc.se.Delete(ctx, se.User.AccountID, se.User.ID, id)
+}
+
+// RevokeOtherSessions ...
+func (uc *UseCase) RevokeOtherSessions(ctx context.Context) (int, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var except []domain.ID
+	if se.UserSession != nil {
+		except = append(except, se.UserSession.ID)
+	}
+
+	return uc.se.DeleteByUser(ctx, se.User.AccountID, se.User.ID, except...)
+}
+
+// RevokeUserSessions ...
+func (uc *UseCase) RevokeUserSessions(ctx context.Context, userID domain.ID) (int, error) {
+	se, err := domain.RequireSession(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only admins can revoke other users' sessions.
+	if !se.User.HasRole(domain.RoleAdmin) {
+		return 0, errors.Errorf("current user %s.%s (role: %s) cannot revoke sessions of user %s", se.User.AccountID, se.User.ID, se.User.Role, userID)
+	}
+
+	// Make sure the user is in the admin's account.
+	u, err := uc.u.Get(ctx, se.User.AccountID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := uc.se.DeleteByUser(ctx, u.AccountID, u.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	zap.S().Infow(
+		"revoked user sessions",
+		"account", u.AccountID,
+		"user", u.ID,
+		"admin", se.User.ID,
+		"sessions", n,
+	)
+
+	return n, nil
+}
+
+// Refresh ...
+func (uc *UseCase) Refresh(ctx context.Context, refreshToken string) (*domain.RefreshResult, error) {
+	hash := token_gen.Hash(uc.c.TokenSecret, refreshToken)
+
+	rt, err := uc.se.GetRefreshToken(ctx, hash)
+	if err != nil {
+		return nil, errors.Wrap(domain.ErrRefreshTokenInvalid, err.Error())
+	}
+
+	// A refresh token that's been used before has leaked, so we can't
+	// tell the user from the attacker. Revoke the whole family.
+	used := rt.UsedAt != nil
+	if !used {
+		ok, err := uc.se.UseRefreshToken(ctx, hash, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		used = !ok
+	}
+	if used {
+		err = uc.se.Delete(ctx, rt.AccountID, rt.UserID, rt.SessionID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		zap.S().Warnw(
+			"refresh token reused, session revoked",
+			"account", rt.AccountID,
+			"user", rt.UserID,
+			"session", rt.SessionID,
+		)
+
+		return nil, domain.ErrRefreshTokenReused
+	}
+
+	us, err := uc.se.Get(ctx, rt.AccountID, rt.SessionID)
+	if err != nil {
+		return nil, errors.Wrap(domain.ErrRefreshTokenInvalid, err.Error())
+	}
+
+	now := time.Now()
+	if us.Ended(now, uc.c.SessionIdleTimeout, uc.c.SessionAbsoluteTimeout) {
+		err = uc.se.Delete(ctx, us.AccountID, us.UserID, us.ID)
+		if err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrSessionEnded
+	}
+
+	// Rotate both tokens.
+	token := token_gen.New()
+	us.SetAccessToken(token, token_gen.Hash(uc.c.TokenSecret, token), now, uc.c.TokenExpires, uc.c.SessionAbsoluteTimeout)
+
+	err = uc.se.Update(ctx, us.AccountID, us.ID, []domain.Field{
+		{Name: "token_hash", Value: us.TokenHash},
+		{Name: "last_seen_at", Value: us.LastSeenAt},
+		{Name: "expires_at", Value: us.ExpiresAt},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := uc.createRefreshToken(ctx, us)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.RefreshResult{
+		Token:        us.Token,
+		RefreshToken: next.Token,
+		ExpiresAt:    us.ExpiresAt,
+	}, nil
+}
+
+// RequestPasswordReset ...
+func (uc *UseCase) RequestPasswordReset(ctx context.Context, a domain.RequestPasswordResetArgs) error {
+	// Never tell the caller whether the account or email exists, or
+	// that they've been rate limited. The email is sent by a job, so
+	// every request returns the same response in about the same time.
+	// Log why no email was sent instead.
+	accountCode := domain.CreateCode(a.AccountCode)
+	email := strings.TrimSpace(a.Email)
+
+	if !uc.rl.Allow(accountCode + "/" + strings.ToLower(email)) {
+		zap.S().Infow("password reset request rate limited", "account_code", accountCode)
+		return nil
+	}
+
+	if len(accountCode) < 2 || email == "" {
+		zap.S().Infow("password reset requested with invalid args", "account_code", accountCode)
+		return nil
+	}
+
+	acc, err := uc.a.GetByCode(ctx, accountCode)
+	if err != nil {
+		zap.S().Infow("password reset requested for unknown account", "account_code", accountCode, "error", err)
+		return nil
+	}
+
+	_, err = uc.j.Enqueue(ctx, domain.EnqueueJobArgs{
+		AccountID: acc.ID,
+		Name:      "send password reset",
+		Payload: &domain.SendPasswordResetPayload{
+			AccountID: acc.ID,
+			Email:     email,
+		},
+	})
+	if err != nil {
+		zap.S().Errorw("could not enqueue password reset", "account", acc.ID, "error", err)
+	}
+
+	return nil
+}
+
+// SendPasswordReset ...
+func (uc *UseCase) SendPasswordReset(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.SendPasswordResetPayload)
+
+	u, err := uc.u.GetByEmail(ctx, p.AccountID, p.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		zap.S().Infow("password reset requested for unknown user", "account", p.AccountID, "job", j.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only the latest link works.
+	err = uc.pr.DeleteByUser(ctx, u.AccountID, u.ID)
+	if err != nil {
+		return err
+	}
+
+	token := token_gen.New()
+	r := domain.NewPasswordReset(u, token, token_gen.Hash(uc.c.TokenSecret, token), uc.c.PasswordResetExpires)
+
+	err = uc.pr.Create(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	link := uc.c.PasswordResetURL + "?token=" + url.QueryEscape(r.Token)
+
+	err = uc.m.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset your password. If it was you, set a new one here:\n\n%s\n\nThe link works once and expires at %s. If it wasn't you, you can ignore this email.\n",
+			u.Profile.GivenName, link, r.ExpiresAt.Format(time.RFC1123),
+		),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not send password reset email to user %s.%s", u.AccountID, u.ID)
+	}
+
+	zap.S().Infow(
+		"password reset sent",
+		"account", u.AccountID,
+		"user", u.ID,
+		"reset", r.ID,
+		"job", j.ID,
+	)
+
+	return nil
+}
+
+// ResetPassword ...
+func (uc *UseCase) ResetPassword(ctx context.Context, a domain.ResetPasswordArgs) error {
+	if len(a.Password) < 8 {
+		return errors.Errorf("missing or invalid password arg")
+	}
+
+	hash := token_gen.Hash(uc.c.TokenSecret, a.Token)
+
+	r, err := uc.pr.GetByTokenHash(ctx, hash)
+	if err != nil {
+		return errors.Wrap(domain.ErrPasswordResetInvalid, err.Error())
+	}
+	if r.UsedAt != nil || r.Expired(time.Now()) {
+		return domain.ErrPasswordResetInvalid
+	}
+
+	ok, err := uc.pr.Use(ctx, hash, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrPasswordResetInvalid
+	}
+
+	u, err := uc.u.Get(ctx, r.AccountID, r.UserID)
+	if err != nil {
+		return errors.Wrap(domain.ErrPasswordResetInvalid, err.Error())
+	}
+
+	u.SetPassword(a.Password)
+
+	_, err = uc.u.Update(ctx, u.AccountID, u.ID, []domain.Field{
+		{Name: "password_hash", Value: u.PasswordHash},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not update password of user %s.%s", u.AccountID, u.ID)
+	}
+
+	// Whoever knew the old password may still be logged in.
+	n, err := uc.se.DeleteByUser(ctx, u.AccountID, u.ID)
+	if err != nil {
+		return err
+	}
+
+	err = uc.pr.DeleteByUser(ctx, u.AccountID, u.ID)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infow(
+		"password reset",
+		"account", u.AccountID,
+		"user", u.ID,
+		"reset", r.ID,
+		"sessions_revoked", n,
+	)
+
+	return nil
+}
@@ -0,0 +1,726 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anrid/codecoach/internal/config"
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/github"
+	"github.com/anrid/codecoach/internal/pkg/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// GithubAPI is the part of the Github API used to manage task repos.
+type GithubAPI interface {
+	CurrentUser() (*github.User, error)
+	NewRepo(candidate, name, desc string) (*github.Repo, error)
+	DeleteRepo(username, repo string) (*github.Response, error)
+	DownloadRepo(username, repo, branch string) (*github.Response, error)
+	HeadCommit(owner, repo string) (branch, sha string, err error)
+	PutFile(owner, repo, filePath string, content []byte, message string) error
+	AddCollaborator(owner, repo, candidate string) error
+	RemoveCollaborator(owner, repo, candidate string) error
+	CreateBranch(owner, repo, branch, sha string) error
+	CommitFiles(owner, repo, branch string, files []github.File, message string) (string, error)
+	CreatePull(owner, repo, title, body, head, base string) (*github.PullRequest, error)
+	ListReviews(username, repo string, pullNumber int, nextURL string) ([]github.Review, *github.Response, error)
+	ListReviewComments(username, repo string, pullNumber int, nextURL string) ([]github.ReviewComment, *github.Response, error)
+}
+
+// reviewBranch is the branch in task repos that holds the changes
+// candidates review in code review tasks.
+const reviewBranch = "review"
+
+// UseCase ...
+type UseCase struct {
+	cfg *config.Config
+	c   domain.ChallengeDAO
+	u   domain.UserDAO
+	j   domain.JobUseCases
+	g   GithubAPI
+	s   storage.Storage
+}
+
+var _ domain.WorkspaceUseCases = &UseCase{}
+
+// New ...
+func New(cfg *config.Config, c domain.ChallengeDAO, u domain.UserDAO, j domain.JobUseCases, g GithubAPI, s storage.Storage) *UseCase {
+	return &UseCase{cfg, c, u, j, g, s}
+}
+
+// ProvisionRepo creates a private repo for a challenge task, uploads
+// the task's starter code and invites the candidate as collaborator.
+// Code review tasks also get a pull request with the changes to review.
+// Progress is recorded on the challenge after each step so that a
+// retried job picks up where the last attempt left off.
+func (uc *UseCase) ProvisionRepo(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.ProvisionRepoPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if c.IsFinal() {
+		zap.S().Infow("skipping repo provisioning for finished challenge", "challenge", c.ID, "task", t.ID, "status", c.Status)
+		return nil
+	}
+
+	repo := &domain.TaskRepo{Status: domain.RepoStatusPending}
+	if tp.Repo != nil {
+		r := *tp.Repo
+		repo = &r
+	}
+
+	switch repo.Status {
+	case domain.RepoStatusReady, domain.RepoStatusArchived, domain.RepoStatusDeleted:
+		return nil
+	}
+
+	if tp.DeadlineAt != nil && time.Now().After(*tp.DeadlineAt) {
+		return uc.fail(ctx, j, p.TaskRef, repo, domain.Permanent(domain.ErrDeadlinePassed))
+	}
+
+	cand, err := uc.u.Get(ctx, c.AccountID, c.ForUserID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find candidate %s.%s", c.AccountID, c.ForUserID)
+	}
+
+	login := cand.Profile.GithubLogin
+	if login == "" {
+		err = errors.Errorf("candidate %s.%s has no Github login", cand.AccountID, cand.ID)
+		return uc.fail(ctx, j, p.TaskRef, repo, domain.Permanent(err))
+	}
+
+	if repo.Status == domain.RepoStatusPending {
+		// Name the repo after the candidate's pseudonym rather than their
+		// login, so the name doesn't give away who the candidate is.
+		pseudonym := strings.ToLower(string(domain.NewPseudonym(c.AccountID, c.ForUserID)))
+
+		r, err := uc.g.NewRepo(pseudonym, repoName(t.Name), "CodeCoach challenge task: "+t.Name)
+		if err != nil {
+			return uc.fail(ctx, j, p.TaskRef, repo, errors.Wrap(err, "could not create repo"))
+		}
+
+		repo.Owner = r.Owner.Login
+		repo.Name = r.Name
+		repo.URL = r.HTMLURL
+		repo.Status = domain.RepoStatusCreated
+
+		err = uc.save(ctx, p.TaskRef, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if repo.Status == domain.RepoStatusCreated {
+		err = uc.upload(repo, t)
+		if err == nil && t.Type == domain.TaskTypeCodeReview {
+			err = uc.seedPull(repo, t)
+		}
+		if err != nil {
+			// Files can't be uploaded twice, so start over with a new
+			// repo on the next attempt.
+			if _, derr := uc.g.DeleteRepo(repo.Owner, repo.Name); derr != nil {
+				zap.S().Infow("could not delete partially uploaded repo", "repo", repo.Owner+"/"+repo.Name, "error", derr.Error())
+			}
+			repo = &domain.TaskRepo{Status: domain.RepoStatusPending}
+
+			return uc.fail(ctx, j, p.TaskRef, repo, err)
+		}
+
+		repo.Status = domain.RepoStatusUploaded
+
+		err = uc.save(ctx, p.TaskRef, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = uc.g.AddCollaborator(repo.Owner, repo.Name, login)
+	if err != nil {
+		return uc.fail(ctx, j, p.TaskRef, repo, errors.Wrapf(err, "could not invite %s to repo", login))
+	}
+
+	now := time.Now()
+	repo.Status = domain.RepoStatusReady
+	repo.Error = ""
+	repo.FailedAt = nil
+	repo.ProvisionedAt = &now
+
+	err = uc.save(ctx, p.TaskRef, repo)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infow(
+		"provisioned repo",
+		"challenge", c.ID,
+		"task", t.ID,
+		"repo", repo.Owner+"/"+repo.Name,
+		"collaborator", login,
+	)
+
+	return nil
+}
+
+// upload copies the starter code from the task's source repo. It records
+// the source repo's commit it copied, so that the candidate's work is
+// later compared to the same starter code even if the source repo
+// changes.
+func (uc *UseCase) upload(repo *domain.TaskRepo, t *domain.Task) error {
+	if t.Details.GithubRepoName == "" {
+		return nil
+	}
+
+	owner, name, err := uc.source(t)
+	if err != nil {
+		return err
+	}
+
+	_, sha, err := uc.g.HeadCommit(owner, name)
+	if err != nil {
+		return errors.Wrapf(err, "could not get head commit of %s/%s", owner, name)
+	}
+	if sha == "" {
+		return domain.Permanent(errors.Errorf("source repo %s/%s has no commits", owner, name))
+	}
+
+	files, err := uc.download(t, sha)
+	if err != nil {
+		return err
+	}
+
+	repo.StarterCommit = sha
+
+	for _, f := range files {
+		err = uc.g.PutFile(repo.Owner, repo.Name, f.Path, f.Content, "Add starter code")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedPull opens a pull request in a code review task's repo with the
+// changes between the source repo's starter commit and review branch.
+func (uc *UseCase) seedPull(repo *domain.TaskRepo, t *domain.Task) error {
+	if t.Details.GithubRepoName == "" || t.Details.ReviewBranch == "" {
+		return domain.Permanent(errors.Errorf("code review task %s has no source repo or review branch", t.ID))
+	}
+
+	base, err := uc.download(t, repo.StarterCommit)
+	if err != nil {
+		return err
+	}
+
+	head, err := uc.download(t, t.Details.ReviewBranch)
+	if err != nil {
+		return err
+	}
+
+	changes := diffFiles(base, head)
+	if len(changes) == 0 {
+		return domain.Permanent(errors.Errorf("review branch %s of %s has no changes", t.Details.ReviewBranch, t.Details.GithubRepoName))
+	}
+
+	branch, sha, err := uc.g.HeadCommit(repo.Owner, repo.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not get head commit of repo %s/%s", repo.Owner, repo.Name)
+	}
+
+	err = uc.g.CreateBranch(repo.Owner, repo.Name, reviewBranch, sha)
+	if err != nil {
+		return err
+	}
+
+	_, err = uc.g.CommitFiles(repo.Owner, repo.Name, reviewBranch, changes, "Add changes to review")
+	if err != nil {
+		return err
+	}
+
+	pr, err := uc.g.CreatePull(repo.Owner, repo.Name, t.Name, "Please review the changes in this pull request.", reviewBranch, branch)
+	if err != nil {
+		return err
+	}
+
+	repo.PullNumber = pr.Number
+	repo.PullURL = pr.HTMLURL
+
+	return nil
+}
+
+// source returns the owner and name of the task's source repo, given as
+// `owner/name` or just `name` for repos owned by the token owner.
+func (uc *UseCase) source(t *domain.Task) (owner, name string, err error) {
+	src := t.Details.GithubRepoName
+
+	if i := strings.Index(src, "/"); i >= 0 {
+		return src[:i], src[i+1:], nil
+	}
+
+	u, err := uc.g.CurrentUser()
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not get current Github user")
+	}
+
+	return u.Login, src, nil
+}
+
+// download returns the files at a branch or commit of the task's source
+// repo. An empty ref means the repo's default branch.
+func (uc *UseCase) download(t *domain.Task, ref string) ([]github.File, error) {
+	owner, name, err := uc.source(t)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := uc.g.DownloadRepo(owner, name, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download %s/%s", owner, name)
+	}
+
+	files, err := github.ExtractZipball(res.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not extract %s/%s", owner, name)
+	}
+
+	return files, nil
+}
+
+// diffFiles returns the files that were added or changed in head
+// compared to base, plus files removed from head with nil content.
+func diffFiles(base, head []github.File) []github.File {
+	old := make(map[string][]byte, len(base))
+	for _, f := range base {
+		old[f.Path] = f.Content
+	}
+
+	var changes []github.File
+
+	for _, f := range head {
+		content, found := old[f.Path]
+		delete(old, f.Path)
+		if found && bytes.Equal(content, f.Content) {
+			continue
+		}
+		if f.Content == nil {
+			f.Content = []byte{}
+		}
+		changes = append(changes, f)
+	}
+
+	for _, f := range base {
+		if _, removed := old[f.Path]; removed {
+			changes = append(changes, github.File{Path: f.Path})
+		}
+	}
+
+	return changes
+}
+
+// ArchiveRepo revokes the candidate's access to a task repo and saves
+// a snapshot of its final state, once the task's deadline has passed or
+// the challenge has ended. The repo is deleted from Github later on if
+// a retention period has been configured.
+func (uc *UseCase) ArchiveRepo(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.ArchiveRepoPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if tp.Repo == nil || tp.Repo.Name == "" {
+		zap.S().Infow("no repo to archive", "challenge", c.ID, "task", p.TaskID)
+		return nil
+	}
+
+	// The task's deadline may have been extended since the job was
+	// scheduled, in which case another job archives the repo later on.
+	if !c.IsFinal() && tp.DeadlineAt != nil && time.Now().Before(*tp.DeadlineAt) {
+		zap.S().Infow("skipping archiving of repo before task deadline", "challenge", c.ID, "task", p.TaskID, "deadline", tp.DeadlineAt)
+		return nil
+	}
+
+	repo := *tp.Repo
+
+	switch {
+	case repo.Status == domain.RepoStatusArchived || repo.Status == domain.RepoStatusDeleted:
+		return nil
+	case repo.Status != domain.RepoStatusReady && repo.FailedAt == nil:
+		return errors.Errorf("repo %s/%s is still being provisioned", repo.Owner, repo.Name)
+	}
+
+	cand, err := uc.u.Get(ctx, c.AccountID, c.ForUserID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find candidate %s.%s", c.AccountID, c.ForUserID)
+	}
+
+	if login := cand.Profile.GithubLogin; login != "" {
+		err = uc.g.RemoveCollaborator(repo.Owner, repo.Name, login)
+		if err != nil {
+			return errors.Wrapf(err, "could not revoke %s's access to repo", login)
+		}
+	}
+
+	_, sha, err := uc.g.HeadCommit(repo.Owner, repo.Name)
+	if err != nil {
+		return errors.Wrapf(err, "could not get head commit of repo %s/%s", repo.Owner, repo.Name)
+	}
+
+	// Empty repos have nothing to snapshot.
+	var key string
+	if sha != "" {
+		res, err := uc.g.DownloadRepo(repo.Owner, repo.Name, sha)
+		if err != nil {
+			return errors.Wrapf(err, "could not download repo %s/%s at %s", repo.Owner, repo.Name, sha)
+		}
+
+		key = fmt.Sprintf("snapshots/%s/%s/%s/%s.zip", p.AccountID, p.ChallengeID, p.TaskID, sha)
+
+		err = uc.s.Put(key, res.Body)
+		if err != nil {
+			return errors.Wrapf(err, "could not save snapshot of repo %s/%s", repo.Owner, repo.Name)
+		}
+	}
+
+	now := time.Now()
+	repo.Status = domain.RepoStatusArchived
+	repo.HeadCommit = sha
+	repo.SnapshotKey = key
+	repo.ArchivedAt = &now
+
+	// The jobs that follow archiving are saved along with the archived
+	// repo, since a retried job stops at an archived repo.
+	c, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		r := repo
+		tp.Repo = &r
+
+		// Grade the work of candidates who never submitted the task,
+		// now that it can no longer change.
+		if sha != "" {
+			for _, a := range domain.GradingJobs(p.TaskRef, t, tp, true) {
+				c.AddPendingJob(a.Payload.JobType(), p.TaskID, a.RunAt)
+			}
+		}
+
+		if uc.cfg.RepoRetention > 0 {
+			deleteAt := now.Add(uc.cfg.RepoRetention)
+			c.AddPendingJob(domain.JobTypeDeleteRepo, p.TaskID, &deleteAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save repo for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	uc.enqueuePending(ctx, c)
+
+	zap.S().Infow(
+		"archived repo",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"repo", repo.Owner+"/"+repo.Name,
+		"head_commit", sha,
+		"snapshot", key,
+	)
+
+	return nil
+}
+
+// DeleteRepo deletes an archived task repo from Github.
+func (uc *UseCase) DeleteRepo(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.DeleteRepoPayload)
+
+	_, tp, _, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if tp.Repo == nil || tp.Repo.Status == domain.RepoStatusDeleted {
+		return nil
+	}
+
+	repo := *tp.Repo
+
+	if repo.Status != domain.RepoStatusArchived {
+		return domain.Permanent(errors.Errorf("cannot delete repo %s/%s (status: %s) before it's archived", repo.Owner, repo.Name, repo.Status))
+	}
+
+	_, err = uc.g.DeleteRepo(repo.Owner, repo.Name)
+	if err != nil && !github.IsNotFound(err) {
+		return errors.Wrapf(err, "could not delete repo %s/%s", repo.Owner, repo.Name)
+	}
+
+	now := time.Now()
+	repo.Status = domain.RepoStatusDeleted
+	repo.DeletedAt = &now
+
+	err = uc.save(ctx, p.TaskRef, &repo)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Infow("deleted repo", "challenge", p.ChallengeID, "task", p.TaskID, "repo", repo.Owner+"/"+repo.Name)
+
+	return nil
+}
+
+// CollectReview fetches the candidate's reviews and line comments on
+// the pull request in a code review task and saves them on the task's
+// progress, scored against the task's expected findings. Collecting
+// again replaces the previously collected review.
+func (uc *UseCase) CollectReview(ctx context.Context, j *domain.Job, pl domain.JobPayload) error {
+	p := pl.(*domain.CollectReviewPayload)
+
+	c, tp, t, err := uc.task(ctx, p.TaskRef)
+	if err != nil {
+		return err
+	}
+
+	if tp.Repo == nil || tp.Repo.PullNumber == 0 {
+		return domain.Permanent(errors.Errorf("task %s in challenge %s has no pull request to collect a review from", p.TaskID, c.ID))
+	}
+
+	repo := tp.Repo
+	if repo.Status == domain.RepoStatusDeleted {
+		return domain.Permanent(errors.Errorf("repo %s/%s has been deleted", repo.Owner, repo.Name))
+	}
+
+	cand, err := uc.u.Get(ctx, c.AccountID, c.ForUserID)
+	if err != nil {
+		return errors.Wrapf(err, "could not find candidate %s.%s", c.AccountID, c.ForUserID)
+	}
+
+	login := cand.Profile.GithubLogin
+
+	r := &domain.CodeReview{
+		PullNumber:  repo.PullNumber,
+		PullURL:     repo.PullURL,
+		Reviews:     []*domain.PullReview{},
+		Comments:    []*domain.ReviewComment{},
+		CollectedAt: time.Now(),
+	}
+
+	var next string
+	for {
+		rs, res, err := uc.g.ListReviews(repo.Owner, repo.Name, repo.PullNumber, next)
+		if err != nil {
+			return errors.Wrapf(err, "could not list reviews of %s/%s#%d", repo.Owner, repo.Name, repo.PullNumber)
+		}
+
+		for _, gr := range rs {
+			if !strings.EqualFold(gr.User.Login, login) {
+				continue
+			}
+			r.Reviews = append(r.Reviews, &domain.PullReview{
+				ID:          gr.ID,
+				State:       gr.State,
+				Body:        gr.Body,
+				CommitID:    gr.CommitID,
+				SubmittedAt: parseTime(gr.SubmittedAt),
+			})
+		}
+
+		if !res.HasNext() {
+			break
+		}
+		next = res.Next
+	}
+
+	next = ""
+	for {
+		cs, res, err := uc.g.ListReviewComments(repo.Owner, repo.Name, repo.PullNumber, next)
+		if err != nil {
+			return errors.Wrapf(err, "could not list review comments on %s/%s#%d", repo.Owner, repo.Name, repo.PullNumber)
+		}
+
+		for _, gc := range cs {
+			if !strings.EqualFold(gc.User.Login, login) {
+				continue
+			}
+			rc := &domain.ReviewComment{
+				ID:          gc.ID,
+				ReviewID:    gc.PullRequestReviewID,
+				InReplyToID: gc.InReplyToID,
+				Path:        gc.Path,
+				Line:        gc.Line,
+				StartLine:   gc.StartLine,
+				Side:        gc.Side,
+				DiffHunk:    gc.DiffHunk,
+				CommitID:    gc.CommitID,
+				Body:        gc.Body,
+			}
+			if t := parseTime(gc.CreatedAt); t != nil {
+				rc.CreatedAt = *t
+			}
+			r.Comments = append(r.Comments, rc)
+		}
+
+		if !res.HasNext() {
+			break
+		}
+		next = res.Next
+	}
+
+	r.Score = domain.ScoreReview(t.Details.ExpectedFindings, r, r.CollectedAt)
+
+	_, err = uc.c.Modify(ctx, p.AccountID, p.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(p.TaskID)
+		if err != nil {
+			return err
+		}
+		tp.Review = r
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save review for challenge %s.%s task %s", p.AccountID, p.ChallengeID, p.TaskID)
+	}
+
+	zap.S().Infow(
+		"collected review",
+		"challenge", c.ID,
+		"task", p.TaskID,
+		"pull", fmt.Sprintf("%s/%s#%d", repo.Owner, repo.Name, repo.PullNumber),
+		"reviews", len(r.Reviews),
+		"comments", len(r.Comments),
+		"scored", r.Score != nil,
+	)
+
+	return nil
+}
+
+// task returns a challenge along with the progress and snapshot of one
+// of its tasks.
+func (uc *UseCase) task(ctx context.Context, ref domain.TaskRef) (*domain.Challenge, *domain.TaskProgress, *domain.Task, error) {
+	c, err := uc.c.Get(ctx, ref.AccountID, ref.ChallengeID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "could not find challenge %s.%s", ref.AccountID, ref.ChallengeID)
+	}
+
+	tp, err := c.TaskProgress(ref.TaskID)
+	if err != nil {
+		return nil, nil, nil, domain.Permanent(err)
+	}
+
+	for _, t := range c.Details.Tasks {
+		if t.ID == ref.TaskID {
+			return c, tp, t, nil
+		}
+	}
+
+	return nil, nil, nil, domain.Permanent(errors.Errorf("could not find task %s in challenge %s", ref.TaskID, c.ID))
+}
+
+// save records the repo on the challenge task's progress.
+func (uc *UseCase) save(ctx context.Context, ref domain.TaskRef, repo *domain.TaskRepo) error {
+	_, err := uc.c.Modify(ctx, ref.AccountID, ref.ChallengeID, func(c *domain.Challenge) error {
+		tp, err := c.TaskProgress(ref.TaskID)
+		if err != nil {
+			return err
+		}
+		r := *repo
+		tp.Repo = &r
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not save repo for challenge %s.%s task %s", ref.AccountID, ref.ChallengeID, ref.TaskID)
+	}
+
+	return nil
+}
+
+// enqueuePending enqueues a challenge's pending jobs and removes them
+// from the challenge. Jobs that can't be enqueued are left for the
+// challenge use case's EnqueuePendingJobs to retry.
+func (uc *UseCase) enqueuePending(ctx context.Context, c *domain.Challenge) {
+	done := make(map[domain.ID]bool)
+
+	for _, pj := range c.Details.PendingJobs {
+		a, err := c.PendingJobArgs(pj)
+		if err == nil {
+			_, err = uc.j.Enqueue(ctx, a)
+		}
+		if err != nil {
+			zap.S().Warnw("could not enqueue pending job", "account", c.AccountID, "challenge", c.ID, "job", pj.ID, "type", pj.Type, "error", err.Error())
+			continue
+		}
+		done[pj.ID] = true
+	}
+
+	if len(done) == 0 {
+		return
+	}
+
+	_, err := uc.c.Modify(ctx, c.AccountID, c.ID, func(c *domain.Challenge) error {
+		c.RemovePendingJobs(done)
+		return nil
+	})
+	if err != nil {
+		// The jobs are enqueued again, which is a no-op, on the next sweep.
+		zap.S().Warnw("could not remove enqueued jobs from challenge", "account", c.AccountID, "challenge", c.ID, "error", err.Error())
+	}
+}
+
+// fail records the error on the repo and returns it. Provisioning is
+// marked as failed once the job won't be retried.
+func (uc *UseCase) fail(ctx context.Context, j *domain.Job, ref domain.TaskRef, repo *domain.TaskRepo, err error) error {
+	repo.Error = err.Error()
+	if domain.IsPermanent(err) || j.Attempts >= j.MaxAttempts {
+		now := time.Now()
+		repo.FailedAt = &now
+	}
+
+	if serr := uc.save(ctx, ref, repo); serr != nil {
+		zap.S().Infow("could not record repo provisioning error", "challenge", ref.ChallengeID, "task", ref.TaskID, "error", serr.Error())
+	}
+
+	return err
+}
+
+// parseTime parses a Github timestamp, returning nil if it's empty or
+// invalid.
+func parseTime(s string) *time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// repoName returns a repo name based on a task name.
+func repoName(taskName string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(taskName) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+
+	n := strings.TrimRight(b.String(), "-")
+	if len(n) > 40 {
+		n = strings.TrimRight(n[:40], "-")
+	}
+	if n == "" {
+		n = "task"
+	}
+
+	return fmt.Sprintf("codecoach-%s", n)
+}
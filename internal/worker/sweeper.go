@@ -0,0 +1,28 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RunSweeper calls the given sweep func in the background at the given
+// interval, e.g. to move overdue records to a new state. The sweep func
+// returns the number of records swept.
+func RunSweeper(name string, interval time.Duration, sweep func(ctx context.Context) (int, error)) {
+	// Sweep in a loop.
+	t := time.NewTicker(interval)
+
+	go func() {
+		for {
+			<-t.C
+			n, err := sweep(context.Background())
+			if err != nil {
+				zap.S().Infow("sweep failed", "sweeper", name, "swept", n, "error", err.Error())
+			} else if n > 0 {
+				zap.S().Infow("sweep done", "sweeper", name, "swept", n)
+			}
+		}
+	}()
+}
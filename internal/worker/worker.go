@@ -0,0 +1,265 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anrid/codecoach/internal/domain"
+	"github.com/anrid/codecoach/internal/pkg/token"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Options ...
+type Options struct {
+	// Concurrency is the max number of jobs run at the same time.
+	Concurrency int
+	// PollInterval is how often the pool looks for due jobs.
+	PollInterval time.Duration
+	// LockFor is how long a job stays locked by the worker that
+	// claimed it. It's also the max time a job is allowed to run.
+	LockFor time.Duration
+	// BackoffBase is how long to wait before retrying a job that failed
+	// on its first attempt. The wait doubles with every attempt.
+	BackoffBase time.Duration
+	// BackoffMax caps the wait between retries.
+	BackoffMax time.Duration
+}
+
+// Pool runs due jobs using a bounded number of goroutines. Several
+// pools, e.g. one per server instance, can safely share the same
+// jobs table since each job is claimed by exactly one pool. A pool
+// only claims jobs of the types it has registered handlers for.
+type Pool struct {
+	id       string
+	j        domain.JobDAO
+	o        Options
+	handlers map[domain.JobType]registration
+	slots    chan struct{}
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// New ...
+func New(j domain.JobDAO, o Options) *Pool {
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.LockFor <= 0 {
+		o.LockFor = 5 * time.Minute
+	}
+	if o.BackoffBase <= 0 {
+		o.BackoffBase = 10 * time.Second
+	}
+	if o.BackoffMax < o.BackoffBase {
+		o.BackoffMax = o.BackoffBase
+	}
+
+	host, _ := os.Hostname()
+
+	return &Pool{
+		id:       fmt.Sprintf("%s-%d-%s", host, os.Getpid(), token.NewCode(8)),
+		j:        j,
+		o:        o,
+		handlers: make(map[domain.JobType]registration),
+		slots:    make(chan struct{}, o.Concurrency),
+		quit:     make(chan struct{}),
+	}
+}
+
+// registration ...
+type registration struct {
+	newPayload func() domain.JobPayload
+	h          domain.JobHandler
+}
+
+// Register sets the handler for a job type. newPayload returns an empty
+// payload, e.g. a pointer to a new struct, that a job's details are
+// decoded into before calling the handler. Handlers must be registered
+// before the pool is started.
+func (p *Pool) Register(t domain.JobType, newPayload func() domain.JobPayload, h domain.JobHandler) {
+	p.handlers[t] = registration{newPayload, h}
+}
+
+// Start polls for due jobs in the background until Stop is called.
+func (p *Pool) Start() {
+	zap.S().Infow("starting worker pool", "worker", p.id, "concurrency", p.o.Concurrency)
+
+	t := time.NewTicker(p.o.PollInterval)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer t.Stop()
+
+		for {
+			select {
+			case <-p.quit:
+				return
+			case <-t.C:
+				p.poll()
+			}
+		}
+	}()
+}
+
+// Stop stops polling for jobs and waits for all running jobs to finish.
+func (p *Pool) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+
+	zap.S().Infow("stopped worker pool", "worker", p.id)
+}
+
+// poll claims as many due jobs as there are free slots and runs them.
+func (p *Pool) poll() {
+	free := cap(p.slots) - len(p.slots)
+	if free == 0 {
+		return
+	}
+
+	js, err := p.j.Claim(context.Background(), p.id, p.types(), free, p.o.LockFor)
+	if err != nil {
+		zap.S().Infow("could not claim jobs", "worker", p.id, "error", err.Error())
+		return
+	}
+
+	for _, j := range js {
+		p.slots <- struct{}{}
+		p.wg.Add(1)
+
+		go func(j *domain.Job) {
+			defer func() {
+				<-p.slots
+				p.wg.Done()
+			}()
+			p.run(j)
+		}(j)
+	}
+}
+
+// types returns all job types that the pool has handlers for.
+func (p *Pool) types() []domain.JobType {
+	var ts []domain.JobType
+	for t := range p.handlers {
+		ts = append(ts, t)
+	}
+	return ts
+}
+
+// run runs a single job and records the outcome. A failed job is
+// retried with exponential backoff until it runs out of attempts, at
+// which point it's moved to the dead-letter state.
+func (p *Pool) run(j *domain.Job) {
+	max := j.MaxAttempts
+	if max < 1 {
+		max = domain.DefaultJobMaxAttempts
+	}
+
+	// Jobs claimed again after their worker died have already used up
+	// an attempt without recording a failure.
+	if j.Attempts > max {
+		p.release(j, p.failed(j, max, errors.Errorf("job exceeded max attempts (%d)", max)))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.o.LockFor)
+	defer cancel()
+
+	start := time.Now()
+
+	err := p.call(ctx, j)
+
+	now := time.Now()
+
+	if err != nil {
+		zap.S().Infow("job failed", "worker", p.id, "job", j.ID, "type", j.Type, "attempt", j.Attempts, "duration", now.Sub(start), "error", err.Error())
+
+		p.release(j, p.failed(j, max, err))
+		return
+	}
+
+	zap.S().Infow("job completed", "worker", p.id, "job", j.ID, "type", j.Type, "attempt", j.Attempts, "duration", now.Sub(start))
+
+	p.release(j, []domain.Field{
+		{Name: "status", Value: domain.StatusCompleted},
+		{Name: "completed_at", Value: now},
+	})
+}
+
+// failed returns the updates for a failed job, either rescheduling it
+// or moving it to the dead-letter state.
+func (p *Pool) failed(j *domain.Job, max int, err error) []domain.Field {
+	now := time.Now()
+
+	updates := []domain.Field{
+		{Name: "last_error", Value: err.Error()},
+		{Name: "failed_at", Value: now},
+	}
+
+	if j.Attempts >= max || domain.IsPermanent(err) {
+		zap.S().Infow("job is dead", "worker", p.id, "job", j.ID, "type", j.Type, "attempts", j.Attempts)
+
+		return append(updates, domain.Field{Name: "status", Value: domain.StatusDead})
+	}
+
+	return append(updates,
+		domain.Field{Name: "status", Value: domain.StatusScheduled},
+		domain.Field{Name: "run_at", Value: now.Add(p.backoff(j.Attempts))},
+	)
+}
+
+// backoff returns how long to wait before the next attempt. The wait
+// is randomized between half and the full exponential delay so that
+// jobs that failed together aren't all retried at the same time.
+func (p *Pool) backoff(attempt int) time.Duration {
+	d := p.o.BackoffBase
+	for i := 1; i < attempt && d < p.o.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > p.o.BackoffMax {
+		d = p.o.BackoffMax
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func (p *Pool) release(j *domain.Job, updates []domain.Field) {
+	_, err := p.j.Release(context.Background(), j.ID, p.id, updates)
+	if err != nil {
+		zap.S().Infow("could not release job", "worker", p.id, "job", j.ID, "error", err.Error())
+	}
+}
+
+// call decodes the job's payload and runs its handler, turning panics
+// into errors. Jobs with unknown types or payloads that can't be decoded
+// fail permanently since retrying them won't help.
+func (p *Pool) call(ctx context.Context, j *domain.Job) (err error) {
+	reg, found := p.handlers[j.Type]
+	if !found {
+		return domain.Permanent(errors.Errorf("no handler registered for job type '%s'", j.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	pl := reg.newPayload()
+
+	err = j.Decode(pl)
+	if err != nil {
+		return domain.Permanent(err)
+	}
+
+	return reg.h(ctx, j, pl)
+}